@@ -2,6 +2,7 @@ package ast
 
 import (
 	"monkey/token"
+	"strings"
 	"testing"
 )
 
@@ -26,3 +27,94 @@ func TestString(t *testing.T) {
 		t.Errorf("program.String() wrong. got=%q", program.String())
 	}
 }
+
+func TestDump(t *testing.T) {
+	key := &StringLiteral{Value: "k"}
+	program := &Program{
+		Statements: []Statement{
+			&LetStatement{
+				Name: &Identifier{Value: "myVar"},
+				Value: &InfixExpression{
+					Left:     &IntegerLiteral{Value: 1},
+					Operator: "+",
+					Right: &HashLiteral{
+						Pairs: map[Expression]Expression{key: &Boolean{Value: true}},
+						Keys:  []Expression{key},
+					},
+				},
+			},
+			&ExpressionStatement{
+				Expression: &FunctionLiteral{
+					Parameters: []*Identifier{{Value: "a"}},
+					Defaults:   map[string]Expression{"a": &Null{}},
+					Body:       &BlockStatement{},
+				},
+			},
+		},
+	}
+
+	expected := `Program
+  Statements[0]: LetStatement
+    Name: Identifier Value=myVar
+    Value: InfixExpression Operator=+
+      Left: IntegerLiteral Value=1
+      Right: HashLiteral
+        Keys[0]: StringLiteral Value=k
+        Values[0]: Boolean Value=true
+  Statements[1]: ExpressionStatement
+    Expression: FunctionLiteral
+      Parameters[0]: Identifier Value=a
+      Defaults[a]: Null
+      Body: BlockStatement
+`
+
+	if Dump(program) != expected {
+		t.Errorf("Dump(program) wrong.\nexpected=%q\ngot=     %q", expected, Dump(program))
+	}
+}
+
+func TestInspect(t *testing.T) {
+	key := &StringLiteral{Value: "k"}
+	program := &Program{
+		Statements: []Statement{
+			&LetStatement{
+				Name: &Identifier{Value: "a"},
+				Value: &HashLiteral{
+					Pairs: map[Expression]Expression{key: &Identifier{Value: "b"}},
+					Keys:  []Expression{key},
+				},
+			},
+			&ExpressionStatement{
+				Expression: &FunctionLiteral{
+					Parameters: []*Identifier{{Value: "c"}},
+					Defaults:   map[string]Expression{"c": &Identifier{Value: "d"}},
+					Rest:       &Identifier{Value: "e"},
+					Body: &BlockStatement{Statements: []Statement{
+						&ExpressionStatement{Expression: &Identifier{Value: "f"}},
+					}},
+				},
+			},
+			&ExpressionStatement{
+				Expression: &IfExpression{
+					Condition:   &Identifier{Value: "g"},
+					Consequence: &BlockStatement{},
+				},
+			},
+		},
+	}
+
+	names := []string{}
+	Inspect(program, func(node Node) bool {
+		if ident, ok := node.(*Identifier); ok {
+			names = append(names, ident.Value)
+		}
+		// skip blocks
+		_, isBlock := node.(*BlockStatement)
+		return !isBlock
+	})
+
+	expected := "a b c d e g"
+	if strings.Join(names, " ") != expected {
+		t.Errorf("wrong identifiers visited. expected=%q, got=%q", expected, strings.Join(names, " "))
+	}
+}
@@ -0,0 +1,137 @@
+package ast
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Dump renders node as an indented tree, one node per line with its type
+// and the fields that are not themselves nodes, and its children indented
+// below it under their field names.
+func Dump(node Node) string {
+	var out bytes.Buffer
+	dumpNode(&out, "", node, 0)
+	return out.String()
+}
+
+var nodeType = reflect.TypeOf((*Node)(nil)).Elem()
+
+// isChild reports whether fields of type t are dumped as children: nodes,
+// and parts of nodes like match arms that are not nodes themselves.
+func isChild(t reflect.Type) bool {
+	return t.Implements(nodeType) ||
+		(t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct &&
+			t.Elem().PkgPath() == nodeType.PkgPath())
+}
+
+func dumpNode(out *bytes.Buffer, label string, node interface{}, depth int) {
+	indent := strings.Repeat("  ", depth)
+
+	value := reflect.ValueOf(node)
+	if node == nil || (value.Kind() == reflect.Ptr && value.IsNil()) {
+		fmt.Fprintf(out, "%s%s<nil>\n", indent, label)
+		return
+	}
+
+	elem := value.Elem()
+	typ := elem.Type()
+
+	attributes := []string{}
+	children := []func(){}
+
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		fieldValue := elem.Field(i)
+
+		if _, ok := node.(*HashLiteral); ok && field.Name == "Keys" {
+			continue // dumped with Pairs
+		}
+
+		switch {
+		case field.Name == "Token":
+			continue
+
+		case isChild(field.Type):
+			if fieldValue.IsNil() {
+				continue
+			}
+			child := fieldValue.Interface()
+			name := field.Name
+			children = append(children, func() {
+				dumpNode(out, name+": ", child, depth+1)
+			})
+
+		case field.Type.Kind() == reflect.Slice && isChild(field.Type.Elem()):
+			name := field.Name
+			for j := 0; j < fieldValue.Len(); j++ {
+				if fieldValue.Index(j).IsNil() {
+					continue
+				}
+				child := fieldValue.Index(j).Interface()
+				childLabel := fmt.Sprintf("%s[%d]: ", name, j)
+				children = append(children, func() {
+					dumpNode(out, childLabel, child, depth+1)
+				})
+			}
+
+		case field.Type.Kind() == reflect.Map:
+			if node, ok := node.(*HashLiteral); ok && field.Name == "Pairs" {
+				for j, key := range node.Keys {
+					key, value := key, node.Pairs[key]
+					keyLabel := fmt.Sprintf("Keys[%d]: ", j)
+					valueLabel := fmt.Sprintf("Values[%d]: ", j)
+					children = append(children, func() {
+						dumpNode(out, keyLabel, key, depth+1)
+						dumpNode(out, valueLabel, value, depth+1)
+					})
+				}
+				continue
+			}
+			children = append(children, dumpMap(out, field.Name, fieldValue, depth+1)...)
+
+		default:
+			if fieldValue.Kind() == reflect.Ptr && fieldValue.IsNil() {
+				continue
+			}
+			attributes = append(attributes,
+				fmt.Sprintf("%s=%v", field.Name, fieldValue.Interface()))
+		}
+	}
+
+	fmt.Fprintf(out, "%s%s%s", indent, label, typ.Name())
+	if len(attributes) > 0 {
+		fmt.Fprintf(out, " %s", strings.Join(attributes, " "))
+	}
+	out.WriteString("\n")
+
+	for _, child := range children {
+		child()
+	}
+}
+
+// dumpMap returns the children for a map of names to nodes, in the order of
+// the names.
+func dumpMap(out *bytes.Buffer, name string, m reflect.Value, depth int) []func() {
+	if m.Type().Key().Kind() != reflect.String || !isChild(m.Type().Elem()) {
+		return nil
+	}
+
+	keys := []string{}
+	for _, key := range m.MapKeys() {
+		keys = append(keys, key.String())
+	}
+	sort.Strings(keys)
+
+	children := []func(){}
+	for _, key := range keys {
+		child := m.MapIndex(reflect.ValueOf(key)).Interface()
+		label := fmt.Sprintf("%s[%s]: ", name, key)
+		children = append(children, func() {
+			dumpNode(out, label, child, depth)
+		})
+	}
+	return children
+}
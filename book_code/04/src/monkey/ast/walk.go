@@ -0,0 +1,152 @@
+package ast
+
+// Inspect traverses the tree under node in source order, calling f for each
+// node. If f returns false, the children of that node are skipped.
+//
+// The parts of a match arm and a struct method, which are not nodes
+// themselves, are visited as children of the match expression and struct
+// statement.
+func Inspect(node Node, f func(Node) bool) {
+	if node == nil || !f(node) {
+		return
+	}
+
+	switch node := node.(type) {
+	case *Program:
+		for _, stmt := range node.Statements {
+			Inspect(stmt, f)
+		}
+
+	case *LetStatement:
+		if node.Pattern != nil {
+			Inspect(node.Pattern, f)
+		} else {
+			Inspect(node.Name, f)
+		}
+		inspectExpression(node.Value, f)
+
+	case *ReturnStatement:
+		inspectExpression(node.ReturnValue, f)
+
+	case *StructStatement:
+		Inspect(node.Name, f)
+		for _, field := range node.Fields {
+			Inspect(field, f)
+		}
+		for _, method := range node.Methods {
+			Inspect(method.Name, f)
+			Inspect(method.Function, f)
+		}
+
+	case *ExpressionStatement:
+		inspectExpression(node.Expression, f)
+
+	case *BlockStatement:
+		for _, stmt := range node.Statements {
+			Inspect(stmt, f)
+		}
+
+	case *PrefixExpression:
+		inspectExpression(node.Right, f)
+
+	case *InfixExpression:
+		inspectExpression(node.Left, f)
+		inspectExpression(node.Right, f)
+
+	case *IfExpression:
+		inspectExpression(node.Condition, f)
+		Inspect(node.Consequence, f)
+		if node.Alternative != nil {
+			Inspect(node.Alternative, f)
+		}
+
+	case *FunctionLiteral:
+		for _, param := range node.Parameters {
+			Inspect(param, f)
+			inspectExpression(node.Defaults[param.Value], f)
+		}
+		if node.Rest != nil {
+			Inspect(node.Rest, f)
+		}
+		Inspect(node.Body, f)
+
+	case *SpreadExpression:
+		inspectExpression(node.Value, f)
+
+	case *CallExpression:
+		inspectExpression(node.Function, f)
+		for _, arg := range node.Arguments {
+			inspectExpression(arg, f)
+		}
+
+	case *TemplateLiteral:
+		for _, part := range node.Parts {
+			inspectExpression(part, f)
+		}
+
+	case *ArrayLiteral:
+		for _, el := range node.Elements {
+			inspectExpression(el, f)
+		}
+
+	case *SetLiteral:
+		for _, el := range node.Elements {
+			inspectExpression(el, f)
+		}
+
+	case *HashLiteral:
+		for _, key := range node.Keys {
+			inspectExpression(key, f)
+			inspectExpression(node.Pairs[key], f)
+		}
+
+	case *IndexExpression:
+		inspectExpression(node.Left, f)
+		inspectExpression(node.Index, f)
+
+	case *SliceExpression:
+		inspectExpression(node.Left, f)
+		inspectExpression(node.Start, f)
+		inspectExpression(node.End, f)
+
+	case *MemberExpression:
+		inspectExpression(node.Object, f)
+		Inspect(node.Property, f)
+
+	case *MatchExpression:
+		inspectExpression(node.Subject, f)
+		for _, arm := range node.Arms {
+			Inspect(arm.Pattern, f)
+			inspectExpression(arm.Guard, f)
+			inspectExpression(arm.Body, f)
+		}
+
+	case *BindingPattern:
+		Inspect(node.Name, f)
+
+	case *LiteralPattern:
+		inspectExpression(node.Value, f)
+
+	case *ArrayPattern:
+		for _, el := range node.Elements {
+			Inspect(el, f)
+		}
+		if node.Rest != nil {
+			Inspect(node.Rest, f)
+		}
+
+	case *HashPattern:
+		for i, key := range node.Keys {
+			inspectExpression(key, f)
+			Inspect(node.Values[i], f)
+		}
+	}
+}
+
+// inspectExpression inspects e unless it is nil. An Expression holding a nil
+// pointer is not a nil Node, so optional fields are checked here.
+func inspectExpression(e Expression, f func(Node) bool) {
+	if e != nil {
+		Inspect(e, f)
+	}
+}
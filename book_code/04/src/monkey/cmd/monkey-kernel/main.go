@@ -0,0 +1,39 @@
+// Command monkey-kernel is a Jupyter kernel for Monkey. Install the kernel
+// spec in kernelspec/ with
+//
+//	jupyter kernelspec install --user --name monkey kernelspec
+//
+// and put monkey-kernel on the PATH.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"monkey/kernel"
+	"os"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s CONNECTION_FILE\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	info, err := kernel.ReadConnectionInfo(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	k, err := kernel.New(info)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	k.Run()
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"monkey/dap"
+	"os"
+)
+
+// runDAP runs the dap subcommand with args, the arguments after "dap",
+// serving the Debug Adapter Protocol on standard input and output for one
+// debugging session.
+func runDAP(args []string) int {
+	flags := flag.NewFlagSet("dap", flag.ContinueOnError)
+	ctx := evaluatorFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: monkey dap [flags]\n\n"+
+			"Serves the Debug Adapter Protocol on standard input and output. The flags\n"+
+			"apply to the script the client launches.\n\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if err := dap.NewServer(os.Stdin, os.Stdout, ctx).Run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,140 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"sync"
+)
+
+// The kinds of message.
+const (
+	REQUEST  = "request"
+	RESPONSE = "response"
+	EVENT    = "event"
+)
+
+// Message is a Debug Adapter Protocol request, response or event. Requests
+// have a Command and Arguments, responses the Command and RequestSeq of the
+// request they answer and a Body or an error Message, and events an Event
+// and a Body.
+type Message struct {
+	Seq        int             `json:"seq"`
+	Type       string          `json:"type"`
+	Command    string          `json:"command,omitempty"`
+	Arguments  json.RawMessage `json:"arguments,omitempty"`
+	RequestSeq int             `json:"request_seq,omitempty"`
+	Success    *bool           `json:"success,omitempty"`
+	Message    string          `json:"message,omitempty"`
+	Event      string          `json:"event,omitempty"`
+	Body       json.RawMessage `json:"body,omitempty"`
+}
+
+// Conn reads and writes messages, each preceded by a header giving its
+// length, and numbers the messages it writes.
+type Conn struct {
+	reader *textproto.Reader
+
+	mu     sync.Mutex // guards the fields below
+	writer io.Writer
+	seq    int
+}
+
+func NewConn(r io.Reader, w io.Writer) *Conn {
+	return &Conn{reader: textproto.NewReader(bufio.NewReader(r)), writer: w}
+}
+
+// Read reads the next message.
+func (c *Conn) Read() (*Message, error) {
+	header, err := c.reader.ReadMIMEHeader()
+	if err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil || length < 0 {
+		return nil, errors.New("missing or invalid Content-Length header")
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(c.reader.R, body); err != nil {
+		return nil, err
+	}
+
+	msg := &Message{}
+	if err := json.Unmarshal(body, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// Write numbers msg and writes it, returning its number. It is safe to call
+// from several goroutines.
+func (c *Conn) Write(msg *Message) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.seq += 1
+	msg.Seq = c.seq
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := fmt.Fprintf(c.writer, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return 0, err
+	}
+	_, err = c.writer.Write(body)
+	return msg.Seq, err
+}
+
+// Request writes a request, returning its number.
+func (c *Conn) Request(command string, arguments interface{}) (int, error) {
+	raw, err := json.Marshal(arguments)
+	if err != nil {
+		return 0, err
+	}
+	return c.Write(&Message{Type: REQUEST, Command: command, Arguments: raw})
+}
+
+// Respond writes the response to req: body if err is nil, and the error
+// otherwise.
+func (c *Conn) Respond(req *Message, body interface{}, err error) error {
+	success := err == nil
+	msg := &Message{Type: RESPONSE, Command: req.Command, RequestSeq: req.Seq, Success: &success}
+
+	if err != nil {
+		msg.Message = err.Error()
+	} else if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		msg.Body = raw
+	}
+
+	_, err = c.Write(msg)
+	return err
+}
+
+// Event writes an event.
+func (c *Conn) Event(event string, body interface{}) error {
+	msg := &Message{Type: EVENT, Event: event}
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		msg.Body = raw
+	}
+
+	_, err := c.Write(msg)
+	return err
+}
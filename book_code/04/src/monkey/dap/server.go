@@ -0,0 +1,520 @@
+// Package dap is a Debug Adapter Protocol server, so editors such as VS Code
+// can run Monkey scripts under the debugger, set breakpoints in them, step
+// through them and inspect their stacks and variables.
+package dap
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"monkey/ast"
+	"monkey/debugger"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Server debugs the one program a client launches.
+type Server struct {
+	conn    *Conn
+	d       *debugger.Debugger
+	context *evaluator.Context // what the program is evaluated with
+
+	mu          sync.Mutex // guards the fields below
+	program     *ast.Program
+	path        string
+	stopOnEntry bool
+	noDebug     bool
+	launched    bool
+	configured  bool
+	started     bool
+	lineBase    int // what the client counts lines and columns from
+	columnBase  int
+	references  []interface{} // what each variablesReference, less one, is to
+}
+
+// NewServer returns a server talking to its client over r and w, which runs
+// the program the client launches with ctx.
+func NewServer(r io.Reader, w io.Writer, ctx *evaluator.Context) *Server {
+	return &Server{conn: NewConn(r, w), d: debugger.New(), context: ctx, lineBase: 1, columnBase: 1}
+}
+
+// handler answers a request, whose arguments are decoded into a new value of
+// the type the handler takes.
+type handler struct {
+	arguments func() interface{}
+	handle    func(s *Server, arguments interface{}) (interface{}, error)
+}
+
+var handlers = map[string]handler{}
+
+func init() {
+	handlers["initialize"] = handler{
+		func() interface{} { return &InitializeArguments{} },
+		(*Server).initialize,
+	}
+	handlers["launch"] = handler{
+		func() interface{} { return &LaunchArguments{} },
+		(*Server).launch,
+	}
+	handlers["setBreakpoints"] = handler{
+		func() interface{} { return &SetBreakpointsArguments{} },
+		(*Server).setBreakpoints,
+	}
+	handlers["configurationDone"] = handler{
+		func() interface{} { return &struct{}{} },
+		(*Server).configurationDone,
+	}
+	handlers["threads"] = handler{
+		func() interface{} { return &struct{}{} },
+		(*Server).threads,
+	}
+	handlers["stackTrace"] = handler{
+		func() interface{} { return &StackTraceArguments{} },
+		(*Server).stackTrace,
+	}
+	handlers["scopes"] = handler{
+		func() interface{} { return &ScopesArguments{} },
+		(*Server).scopes,
+	}
+	handlers["variables"] = handler{
+		func() interface{} { return &VariablesArguments{} },
+		(*Server).variables,
+	}
+	handlers["evaluate"] = handler{
+		func() interface{} { return &EvaluateArguments{} },
+		(*Server).evaluate,
+	}
+	handlers["continue"] = handler{
+		func() interface{} { return &struct{}{} },
+		resume((*debugger.Debugger).Continue),
+	}
+	handlers["next"] = handler{
+		func() interface{} { return &struct{}{} },
+		resume((*debugger.Debugger).StepOver),
+	}
+	handlers["stepIn"] = handler{
+		func() interface{} { return &struct{}{} },
+		resume((*debugger.Debugger).StepIn),
+	}
+	handlers["stepOut"] = handler{
+		func() interface{} { return &struct{}{} },
+		resume((*debugger.Debugger).StepOut),
+	}
+	handlers["pause"] = handler{
+		func() interface{} { return &struct{}{} },
+		(*Server).pause,
+	}
+}
+
+// Run serves requests until the client disconnects, terminates the program
+// or closes the connection.
+func (s *Server) Run() error {
+	for {
+		msg, err := s.conn.Read()
+		if err != nil {
+			return err
+		}
+		if msg.Type != REQUEST {
+			continue
+		}
+
+		switch msg.Command {
+		case "disconnect", "terminate":
+			// the program cannot be stopped midway, so it is let finish
+			// without pausing while the server goes
+			s.d.Detach()
+			s.conn.Respond(msg, nil, nil)
+			if msg.Command == "terminate" {
+				s.conn.Event("terminated", nil)
+			}
+			return nil
+		}
+
+		s.serve(msg)
+	}
+}
+
+func (s *Server) serve(msg *Message) {
+	h, ok := handlers[msg.Command]
+	if !ok {
+		s.conn.Respond(msg, nil, fmt.Errorf("unsupported request %s", msg.Command))
+		return
+	}
+
+	arguments := h.arguments()
+	if len(msg.Arguments) > 0 {
+		if err := json.Unmarshal(msg.Arguments, arguments); err != nil {
+			s.conn.Respond(msg, nil, err)
+			return
+		}
+	}
+
+	body, err := h.handle(s, arguments)
+	s.conn.Respond(msg, body, err)
+
+	if err != nil {
+		return
+	}
+	switch msg.Command {
+	case "initialize":
+		// the client sends its configuration once it knows the server
+		// is ready for it
+		s.conn.Event("initialized", nil)
+	case "launch", "configurationDone":
+		s.startIfReady()
+	}
+}
+
+func (s *Server) initialize(arguments interface{}) (interface{}, error) {
+	args := arguments.(*InitializeArguments)
+
+	s.mu.Lock()
+	if args.LinesStartAt1 != nil && !*args.LinesStartAt1 {
+		s.lineBase = 0
+	}
+	if args.ColumnsStartAt1 != nil && !*args.ColumnsStartAt1 {
+		s.columnBase = 0
+	}
+	s.mu.Unlock()
+
+	return Capabilities{
+		SupportsConfigurationDoneRequest: true,
+		SupportsConditionalBreakpoints:   true,
+		SupportsEvaluateForHovers:        true,
+		SupportsTerminateRequest:         true,
+	}, nil
+}
+
+func (s *Server) launch(arguments interface{}) (interface{}, error) {
+	args := arguments.(*LaunchArguments)
+
+	source, err := ioutil.ReadFile(args.Program)
+	if err != nil {
+		return nil, err
+	}
+	p := parser.New(lexer.New(string(source)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, errors.New(args.Program + ": " + strings.Join(p.Errors(), "\n"))
+	}
+
+	s.mu.Lock()
+	s.program, s.path = program, args.Program
+	s.stopOnEntry, s.noDebug = args.StopOnEntry, args.NoDebug
+	s.launched = true
+	s.mu.Unlock()
+
+	return nil, nil
+}
+
+func (s *Server) configurationDone(interface{}) (interface{}, error) {
+	s.mu.Lock()
+	s.configured = true
+	s.mu.Unlock()
+
+	return nil, nil
+}
+
+// startIfReady starts the program once it is launched and the client has
+// set its breakpoints, whichever comes last.
+func (s *Server) startIfReady() {
+	s.mu.Lock()
+	ready := s.launched && s.configured && !s.started
+	s.started = s.started || ready
+	program, stopOnEntry, noDebug := s.program, s.stopOnEntry, s.noDebug
+	s.mu.Unlock()
+
+	if !ready {
+		return
+	}
+
+	// the program gets its own copy of the context, with puts writing
+	// output events to this client
+	ctx := evaluator.Context{}
+	if s.context != nil {
+		ctx = *s.context
+	}
+	ctx.Stdout = &output{conn: s.conn, category: "stdout"}
+	s.d.Start(program, &ctx, stopOnEntry && !noDebug)
+	if noDebug {
+		s.d.Detach()
+	}
+	go s.forwardStops()
+}
+
+// forwardStops tells the client each time the program pauses, and when it
+// ends.
+func (s *Server) forwardStops() {
+	for stop := range s.d.Stops() {
+		s.mu.Lock()
+		s.references = nil
+		s.mu.Unlock()
+
+		if stop.Done {
+			exitCode := 0
+			if errObj, ok := stop.Result.(*object.Error); ok {
+				s.conn.Event("output", OutputEventBody{Category: "stderr", Output: errObj.Message + "\n"})
+				exitCode = 1
+			}
+			s.conn.Event("exited", ExitedEventBody{ExitCode: exitCode})
+			s.conn.Event("terminated", nil)
+			return
+		}
+
+		s.conn.Event("stopped", StoppedEventBody{
+			Reason:            string(stop.Reason),
+			Text:              stop.Message,
+			ThreadID:          THREAD_ID,
+			AllThreadsStopped: true,
+		})
+	}
+}
+
+// output sends what the program prints to the client's console.
+type output struct {
+	conn     *Conn
+	category string
+}
+
+func (o *output) Write(p []byte) (int, error) {
+	if err := o.conn.Event("output", OutputEventBody{Category: o.category, Output: string(p)}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (s *Server) setBreakpoints(arguments interface{}) (interface{}, error) {
+	args := arguments.(*SetBreakpointsArguments)
+
+	s.mu.Lock()
+	lineBase := s.lineBase
+	s.mu.Unlock()
+	program := s.parsed(args.Source.Path)
+
+	// a script is one file, so its breakpoints are all of them
+	s.d.ClearBreakpoints()
+	body := SetBreakpointsResponseBody{Breakpoints: []Breakpoint{}}
+	for _, sb := range args.Breakpoints {
+		bp := Breakpoint{Verified: true, Line: sb.Line}
+		line := sb.Line + 1 - lineBase
+
+		// a breakpoint between statements moves to the next one, where the
+		// program can pause
+		if program != nil {
+			next, ok := debugger.StatementLine(program, line)
+			if !ok {
+				bp.Verified, bp.Message = false, fmt.Sprintf("no statement on or after line %d", sb.Line)
+				body.Breakpoints = append(body.Breakpoints, bp)
+				continue
+			}
+			line, bp.Line = next, next-1+lineBase
+		}
+
+		if _, err := s.d.SetBreakpoint(line, sb.Condition); err != nil {
+			bp.Verified, bp.Message = false, "invalid condition: "+err.Error()
+		}
+		body.Breakpoints = append(body.Breakpoints, bp)
+	}
+	return body, nil
+}
+
+// parsed returns the program at path: the launched one, or else the file
+// parsed afresh, since a client may set breakpoints before launching. It
+// returns nil when the file cannot be read or parsed.
+func (s *Server) parsed(path string) *ast.Program {
+	s.mu.Lock()
+	program, launched := s.program, s.path
+	s.mu.Unlock()
+	if program != nil && launched == path {
+		return program
+	}
+
+	source, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	p := parser.New(lexer.New(string(source)))
+	program = p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil
+	}
+	return program
+}
+
+func (s *Server) threads(interface{}) (interface{}, error) {
+	return ThreadsResponseBody{Threads: []Thread{{ID: THREAD_ID, Name: "main"}}}, nil
+}
+
+// Frames are numbered from 1 for the innermost, which stays true for as
+// long as the program is paused.
+func (s *Server) stackTrace(arguments interface{}) (interface{}, error) {
+	args := arguments.(*StackTraceArguments)
+
+	frames, err := s.d.Stack()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	source := &Source{Name: filepath.Base(s.path), Path: s.path}
+	lineBase, columnBase := s.lineBase, s.columnBase
+	s.mu.Unlock()
+
+	body := StackTraceResponseBody{StackFrames: []StackFrame{}, TotalFrames: len(frames)}
+	for i, frame := range frames {
+		if i < args.StartFrame || args.Levels > 0 && i >= args.StartFrame+args.Levels {
+			continue
+		}
+		body.StackFrames = append(body.StackFrames, StackFrame{
+			ID:     i + 1,
+			Name:   frame.Name,
+			Source: source,
+			Line:   frame.Line - 1 + lineBase,
+			Column: frame.Column - 1 + columnBase,
+		})
+	}
+	return body, nil
+}
+
+func (s *Server) frame(id int) (debugger.Frame, error) {
+	frames, err := s.d.Stack()
+	if err != nil {
+		return debugger.Frame{}, err
+	}
+	if id < 1 || id > len(frames) {
+		return debugger.Frame{}, debugger.ErrNoFrame
+	}
+	return frames[id-1], nil
+}
+
+func (s *Server) scopes(arguments interface{}) (interface{}, error) {
+	frame, err := s.frame(arguments.(*ScopesArguments).FrameID)
+	if err != nil {
+		return nil, err
+	}
+
+	body := ScopesResponseBody{Scopes: []Scope{}}
+	for _, scope := range debugger.Scopes(frame.Env) {
+		body.Scopes = append(body.Scopes, Scope{
+			Name:               strings.Title(scope.Name),
+			VariablesReference: s.reference(scope.Env),
+		})
+	}
+	return body, nil
+}
+
+// reference returns a variablesReference for an environment or a value
+// with parts. They last until the program resumes.
+func (s *Server) reference(v interface{}) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.references = append(s.references, v)
+	return len(s.references)
+}
+
+func (s *Server) variables(arguments interface{}) (interface{}, error) {
+	ref := arguments.(*VariablesArguments).VariablesReference
+
+	s.mu.Lock()
+	if ref < 1 || ref > len(s.references) {
+		s.mu.Unlock()
+		return nil, errors.New("unknown variables reference " + strconv.Itoa(ref))
+	}
+	v := s.references[ref-1]
+	s.mu.Unlock()
+
+	variables := []Variable{}
+	switch v := v.(type) {
+	case *object.Environment:
+		for _, name := range v.LocalNames() {
+			value, _ := v.Get(name)
+			variables = append(variables, s.variable(name, value))
+		}
+	case *object.Array:
+		for i, element := range v.Elements {
+			variables = append(variables, s.variable(strconv.Itoa(i), element))
+		}
+	case *object.Hash:
+		for _, pair := range v.Pairs() {
+			variables = append(variables, s.variable(pair.Key.Inspect(), pair.Value))
+		}
+	case *object.Instance:
+		for i, field := range v.Struct.Fields {
+			variables = append(variables, s.variable(field, v.Values[i]))
+		}
+	}
+	return VariablesResponseBody{Variables: variables}, nil
+}
+
+func (s *Server) variable(name string, value object.Object) Variable {
+	result, ref := s.describe(value)
+	return Variable{Name: name, Value: result, Type: string(value.Type()), VariablesReference: ref}
+}
+
+// describe returns how value is shown, and a reference to its parts if it
+// has any.
+func (s *Server) describe(value object.Object) (string, int) {
+	switch value := value.(type) {
+	case *object.Array:
+		if len(value.Elements) > 0 {
+			return value.Inspect(), s.reference(value)
+		}
+	case *object.Hash:
+		if value.Len() > 0 {
+			return value.Inspect(), s.reference(value)
+		}
+	case *object.Instance:
+		if len(value.Values) > 0 {
+			return value.Inspect(), s.reference(value)
+		}
+	}
+	return debugger.Describe(value), 0
+}
+
+func (s *Server) evaluate(arguments interface{}) (interface{}, error) {
+	args := arguments.(*EvaluateArguments)
+
+	// without a frame, expressions are evaluated in the innermost one
+	frame := 0
+	if args.FrameID > 0 {
+		frame = args.FrameID - 1
+	}
+
+	result, err := s.d.Evaluate(frame, args.Expression)
+	if err != nil {
+		return nil, err
+	}
+	if errObj, ok := result.(*object.Error); ok {
+		return nil, errors.New(errObj.Message)
+	}
+	if result == nil {
+		return EvaluateResponseBody{Result: ""}, nil
+	}
+
+	text, ref := s.describe(result)
+	return EvaluateResponseBody{Result: text, Type: string(result.Type()), VariablesReference: ref}, nil
+}
+
+// resume makes a handler of a Debugger method that resumes the program.
+func resume(method func(*debugger.Debugger) error) func(*Server, interface{}) (interface{}, error) {
+	return func(s *Server, arguments interface{}) (interface{}, error) {
+		if err := method(s.d); err != nil {
+			return nil, err
+		}
+		return ContinueResponseBody{AllThreadsContinued: true}, nil
+	}
+}
+
+func (s *Server) pause(interface{}) (interface{}, error) {
+	s.d.Pause()
+	return nil, nil
+}
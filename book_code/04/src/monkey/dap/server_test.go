@@ -0,0 +1,370 @@
+package dap
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"monkey/evaluator"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const program = `let add = fn(a, b) {
+  let sum = a + b;
+  sum
+};
+let point = {"x": add(1, 2), "y": [4, 5]};
+puts(point["x"]);
+add(point["x"], 10)`
+
+// client talks to a Server running in the same process over pipes.
+type client struct {
+	t    *testing.T
+	conn *Conn
+
+	responses chan *Message
+	events    chan *Message
+	output    strings.Builder // what the program printed
+	done      chan error      // the result of the server's Run
+}
+
+func newClient(t *testing.T) *client {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+
+	c := &client{
+		t:         t,
+		conn:      NewConn(clientIn, clientOut),
+		responses: make(chan *Message, 16),
+		events:    make(chan *Message, 16),
+		done:      make(chan error, 1),
+	}
+
+	go func() {
+		c.done <- NewServer(serverIn, serverOut, &evaluator.Context{}).Run()
+	}()
+	go func() {
+		for {
+			msg, err := c.conn.Read()
+			if err != nil {
+				return
+			}
+			if msg.Type == RESPONSE {
+				c.responses <- msg
+			} else {
+				c.events <- msg
+			}
+		}
+	}()
+
+	return c
+}
+
+// request sends a request and decodes the body of its response into body,
+// returning the response.
+func (c *client) request(command string, arguments interface{}, body interface{}) *Message {
+	c.t.Helper()
+
+	seq, err := c.conn.Request(command, arguments)
+	if err != nil {
+		c.t.Fatalf("sending %s failed: %s", command, err)
+	}
+
+	select {
+	case msg := <-c.responses:
+		if msg.RequestSeq != seq || msg.Command != command || msg.Success == nil {
+			c.t.Fatalf("wrong response to %s: %+v", command, msg)
+		}
+		if *msg.Success && body != nil {
+			if err := json.Unmarshal(msg.Body, body); err != nil {
+				c.t.Fatalf("malformed body of %s: %s (%s)", command, err, msg.Body)
+			}
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		c.t.Fatalf("no response to %s", command)
+		return nil
+	}
+}
+
+// call sends a request, failing the test if it does not succeed.
+func (c *client) call(command string, arguments interface{}, body interface{}) {
+	c.t.Helper()
+
+	if msg := c.request(command, arguments, body); !*msg.Success {
+		c.t.Fatalf("%s failed: %s", command, msg.Message)
+	}
+}
+
+// event waits for the next event other than output and decodes its body.
+func (c *client) event(name string, body interface{}) {
+	c.t.Helper()
+
+	for {
+		select {
+		case msg := <-c.events:
+			if msg.Event == "output" {
+				output := OutputEventBody{}
+				json.Unmarshal(msg.Body, &output)
+				c.output.WriteString(output.Output)
+				continue
+			}
+			if msg.Event != name {
+				c.t.Fatalf("wrong event. expected=%s, got=%s", name, msg.Event)
+			}
+			if body != nil {
+				if err := json.Unmarshal(msg.Body, body); err != nil {
+					c.t.Fatalf("malformed body of %s: %s", name, err)
+				}
+			}
+			return
+		case <-time.After(5 * time.Second):
+			c.t.Fatalf("no %s event", name)
+		}
+	}
+}
+
+func (c *client) stopped(reason string) StoppedEventBody {
+	c.t.Helper()
+
+	body := StoppedEventBody{}
+	c.event("stopped", &body)
+	if body.Reason != reason || body.ThreadID != THREAD_ID {
+		c.t.Fatalf("wrong stop. expected=%s, got=%+v", reason, body)
+	}
+	return body
+}
+
+func (c *client) disconnect() {
+	c.t.Helper()
+
+	c.call("disconnect", nil, nil)
+	select {
+	case err := <-c.done:
+		if err != nil {
+			c.t.Errorf("server did not end cleanly: %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		c.t.Fatalf("server did not end")
+	}
+}
+
+// launch starts a session debugging source with breakpoints.
+func (c *client) launch(source string, stopOnEntry bool, breakpoints ...SourceBreakpoint) []Breakpoint {
+	c.t.Helper()
+
+	dir, err := ioutil.TempDir("", "dap")
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	c.t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "script.code")
+	if err := ioutil.WriteFile(path, []byte(source), 0644); err != nil {
+		c.t.Fatal(err)
+	}
+
+	caps := Capabilities{}
+	c.call("initialize", InitializeArguments{ClientID: "test"}, &caps)
+	if !caps.SupportsConfigurationDoneRequest || !caps.SupportsConditionalBreakpoints {
+		c.t.Errorf("missing capabilities: %+v", caps)
+	}
+	c.event("initialized", nil)
+
+	c.call("launch", LaunchArguments{Program: path, StopOnEntry: stopOnEntry}, nil)
+
+	set := SetBreakpointsResponseBody{}
+	c.call("setBreakpoints", SetBreakpointsArguments{
+		Source: Source{Path: path}, Breakpoints: breakpoints,
+	}, &set)
+	c.call("configurationDone", nil, nil)
+	return set.Breakpoints
+}
+
+func TestSession(t *testing.T) {
+	c := newClient(t)
+	breakpoints := c.launch(program, false,
+		SourceBreakpoint{Line: 2, Condition: "a > 1"},
+		SourceBreakpoint{Line: 3, Condition: "a >"},
+	)
+
+	if len(breakpoints) != 2 || !breakpoints[0].Verified || breakpoints[1].Verified ||
+		!strings.Contains(breakpoints[1].Message, "invalid condition") {
+		t.Errorf("wrong breakpoints: %+v", breakpoints)
+	}
+
+	c.stopped("breakpoint")
+
+	threads := ThreadsResponseBody{}
+	c.call("threads", nil, &threads)
+	if len(threads.Threads) != 1 || threads.Threads[0].ID != THREAD_ID {
+		t.Errorf("wrong threads: %+v", threads)
+	}
+
+	trace := StackTraceResponseBody{}
+	c.call("stackTrace", StackTraceArguments{ThreadID: THREAD_ID}, &trace)
+	if trace.TotalFrames != 2 || len(trace.StackFrames) != 2 {
+		t.Fatalf("wrong stack trace: %+v", trace)
+	}
+	top, bottom := trace.StackFrames[0], trace.StackFrames[1]
+	if top.ID != 1 || top.Name != "add" || top.Line != 2 || top.Column != 3 ||
+		top.Source == nil || top.Source.Name != "script.code" {
+		t.Errorf("wrong top frame: %+v", top)
+	}
+	if bottom.Name != "<program>" || bottom.Line != 7 {
+		t.Errorf("wrong bottom frame: %+v", bottom)
+	}
+
+	scopes := ScopesResponseBody{}
+	c.call("scopes", ScopesArguments{FrameID: 1}, &scopes)
+	if len(scopes.Scopes) != 2 || scopes.Scopes[0].Name != "Locals" || scopes.Scopes[1].Name != "Globals" {
+		t.Fatalf("wrong scopes: %+v", scopes)
+	}
+
+	locals := VariablesResponseBody{}
+	c.call("variables", VariablesArguments{scopes.Scopes[0].VariablesReference}, &locals)
+	if len(locals.Variables) != 2 || locals.Variables[0].Name != "a" || locals.Variables[0].Value != "3" ||
+		locals.Variables[0].Type != "INTEGER" || locals.Variables[1].Value != "10" {
+		t.Errorf("wrong locals: %+v", locals)
+	}
+
+	globals := VariablesResponseBody{}
+	c.call("variables", VariablesArguments{scopes.Scopes[1].VariablesReference}, &globals)
+	if len(globals.Variables) != 2 || globals.Variables[0].Value != "add(a, b)" ||
+		globals.Variables[1].Name != "point" || globals.Variables[1].VariablesReference == 0 {
+		t.Fatalf("wrong globals: %+v", globals)
+	}
+
+	point := VariablesResponseBody{}
+	c.call("variables", VariablesArguments{globals.Variables[1].VariablesReference}, &point)
+	if len(point.Variables) != 2 || point.Variables[0].Name != "x" || point.Variables[0].Value != "3" ||
+		point.Variables[1].Value != "[4, 5]" || point.Variables[1].VariablesReference == 0 {
+		t.Errorf("wrong members of point: %+v", point)
+	}
+
+	evaluated := EvaluateResponseBody{}
+	c.call("evaluate", EvaluateArguments{Expression: "a * b", FrameID: 1}, &evaluated)
+	if evaluated.Result != "30" || evaluated.Type != "INTEGER" {
+		t.Errorf("wrong evaluation: %+v", evaluated)
+	}
+	if msg := c.request("evaluate", EvaluateArguments{Expression: "nope", FrameID: 2}, nil); *msg.Success ||
+		msg.Message != "identifier not found: nope" {
+		t.Errorf("expected evaluating an unknown name to fail. got=%+v", msg)
+	}
+
+	c.call("next", nil, nil)
+	c.stopped("step")
+	c.call("stackTrace", StackTraceArguments{ThreadID: THREAD_ID}, &trace)
+	if trace.StackFrames[0].Line != 3 {
+		t.Errorf("wrong line after stepping: %+v", trace.StackFrames[0])
+	}
+
+	c.call("stepOut", nil, nil)
+	c.event("exited", nil)
+	c.event("terminated", nil)
+
+	if c.output.String() != "3\n" {
+		t.Errorf("wrong output. expected=%q, got=%q", "3\n", c.output.String())
+	}
+	c.disconnect()
+}
+
+func TestStopOnEntryAndErrors(t *testing.T) {
+	c := newClient(t)
+	c.launch("let x = 1;\nx + true", true)
+
+	c.stopped("entry")
+	if msg := c.request("scopes", ScopesArguments{FrameID: 5}, nil); *msg.Success {
+		t.Errorf("expected scopes of an unknown frame to fail")
+	}
+
+	c.call("continue", nil, nil)
+	exited := ExitedEventBody{}
+	c.event("exited", &exited)
+	if exited.ExitCode != 1 || !strings.Contains(c.output.String(), "type mismatch: INTEGER + BOOLEAN") {
+		t.Errorf("expected the error to be reported. got exit code %d and output %q",
+			exited.ExitCode, c.output.String())
+	}
+	c.disconnect()
+}
+
+func TestLaunchErrors(t *testing.T) {
+	c := newClient(t)
+
+	c.call("initialize", InitializeArguments{}, nil)
+	c.event("initialized", nil)
+
+	if msg := c.request("launch", LaunchArguments{Program: "/does/not/exist"}, nil); *msg.Success {
+		t.Errorf("expected launching a missing script to fail")
+	}
+	if msg := c.request("stackTrace", StackTraceArguments{}, nil); *msg.Success {
+		t.Errorf("expected a stack trace of a program not started to fail")
+	}
+	if msg := c.request("restartFrame", nil, nil); *msg.Success ||
+		msg.Message != "unsupported request restartFrame" {
+		t.Errorf("expected an unsupported request to fail. got=%+v", msg)
+	}
+	c.disconnect()
+}
+
+func TestZeroBasedLines(t *testing.T) {
+	c := newClient(t)
+
+	falseValue := false
+	c.call("initialize", InitializeArguments{LinesStartAt1: &falseValue, ColumnsStartAt1: &falseValue}, nil)
+	c.event("initialized", nil)
+
+	dir, _ := ioutil.TempDir("", "dap")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "script.code")
+	ioutil.WriteFile(path, []byte(program), 0644)
+
+	c.call("launch", LaunchArguments{Program: path}, nil)
+	c.call("setBreakpoints", SetBreakpointsArguments{
+		Source: Source{Path: path}, Breakpoints: []SourceBreakpoint{{Line: 1}},
+	}, nil)
+	c.call("configurationDone", nil, nil)
+
+	c.stopped("breakpoint")
+	trace := StackTraceResponseBody{}
+	c.call("stackTrace", StackTraceArguments{ThreadID: THREAD_ID, Levels: 1}, &trace)
+	if len(trace.StackFrames) != 1 || trace.StackFrames[0].Line != 1 || trace.StackFrames[0].Column != 2 {
+		t.Errorf("wrong zero-based frame: %+v", trace.StackFrames)
+	}
+
+	c.call("setBreakpoints", SetBreakpointsArguments{Source: Source{Path: path}}, nil)
+	c.call("continue", nil, nil)
+	c.event("exited", nil)
+	c.event("terminated", nil)
+	c.disconnect()
+}
+
+func TestBreakpointLines(t *testing.T) {
+	c := newClient(t)
+	breakpoints := c.launch(program, false,
+		SourceBreakpoint{Line: 4},
+		SourceBreakpoint{Line: 20},
+	)
+
+	if len(breakpoints) != 2 || !breakpoints[0].Verified || breakpoints[0].Line != 5 {
+		t.Errorf("expected the breakpoint after a function to move to line 5. got=%+v", breakpoints)
+	}
+	if len(breakpoints) == 2 && (breakpoints[1].Verified ||
+		breakpoints[1].Message != "no statement on or after line 20") {
+		t.Errorf("expected the breakpoint past the end to be unverified. got=%+v", breakpoints[1])
+	}
+
+	c.stopped("breakpoint")
+	trace := StackTraceResponseBody{}
+	c.call("stackTrace", StackTraceArguments{ThreadID: THREAD_ID, Levels: 1}, &trace)
+	if len(trace.StackFrames) != 1 || trace.StackFrames[0].Line != 5 {
+		t.Errorf("wrong frame: %+v", trace.StackFrames)
+	}
+
+	c.call("continue", nil, nil)
+	c.event("exited", nil)
+	c.event("terminated", nil)
+	c.disconnect()
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"monkey/debugger"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"os"
+)
+
+// runDebug runs the debug subcommand with args, the arguments after
+// "debug", running a script under the debugger's console on standard input
+// and output. The console reads standard input, so the script gets none. It
+// returns the exit status for the process.
+func runDebug(args []string) int {
+	flags := flag.NewFlagSet("debug", flag.ContinueOnError)
+	ctx := evaluatorFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: monkey debug [flags] script\n\n"+
+			"Runs the script under the debugger, pausing before its first statement.\n"+
+			"Type help at the prompt for the commands.\n\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	path := flags.Arg(0)
+	source, err := ioutil.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	p := parser.New(lexer.New(string(source)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		printParseErrors(path, p)
+		return 1
+	}
+
+	d := debugger.New()
+	d.Start(program, ctx, true)
+	result := debugger.Console(d, string(source), os.Stdin, os.Stdout)
+	if errObj, ok := result.(*object.Error); ok {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", path, errObj.Message)
+		return 1
+	}
+	return 0
+}
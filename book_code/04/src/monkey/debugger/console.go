@@ -0,0 +1,343 @@
+package debugger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"monkey/ast"
+	"monkey/object"
+	"strconv"
+	"strings"
+)
+
+// CONTEXT_LINES is how many lines list shows on each side of the line the
+// program is paused at.
+const CONTEXT_LINES = 3
+
+const CONSOLE_PROMPT = "(debug) "
+
+const consoleHelp = `commands:
+  break LINE [if COND]  pause at LINE, or only when COND holds there
+  clear [LINE]          remove the breakpoint at LINE, or all of them
+  breakpoints           list the breakpoints
+  continue, c           run until a breakpoint
+  step, s               run to the next statement, entering calls
+  next, n               run to the next statement, stepping over calls
+  out, o                run until the current function returns
+  stack, bt             show the call stack
+  frame N               select frame N of the stack
+  env                   show the environments of the selected frame
+  print, p EXPR         evaluate EXPR in the selected frame
+  list, l               show the source around the paused line
+  quit, q               stop debugging and let the program finish
+`
+
+// console drives a Debugger from commands typed a line at a time.
+type console struct {
+	d     *Debugger
+	lines []string
+	in    *bufio.Scanner
+	out   io.Writer
+
+	frame int // the selected frame, from the innermost
+	stop  Stop
+}
+
+// Console runs the program d has started under commands read from in,
+// showing where it pauses and what it is asked about on out. It returns
+// what the program evaluated to, or nil if in ends or the user quits first.
+func Console(d *Debugger, source string, in io.Reader, out io.Writer) object.Object {
+	c := &console{
+		d:     d,
+		lines: strings.Split(source, "\n"),
+		in:    bufio.NewScanner(in),
+		out:   out,
+	}
+
+	for {
+		c.stop = <-d.Stops()
+		if c.stop.Done {
+			return c.stop.Result
+		}
+
+		c.frame = 0
+		c.showStop()
+		if !c.prompt() {
+			d.Detach()
+			return nil
+		}
+	}
+}
+
+func (c *console) showStop() {
+	fmt.Fprintf(c.out, "paused at line %d (%s)\n", c.stop.Line, c.stop.Reason)
+	if c.stop.Message != "" {
+		fmt.Fprintln(c.out, c.stop.Message)
+	}
+	c.showLines(c.stop.Line, c.stop.Line)
+}
+
+// prompt reads commands until one resumes the program. It returns false if
+// the user quits or the input ends.
+func (c *console) prompt() bool {
+	for {
+		fmt.Fprint(c.out, CONSOLE_PROMPT)
+		if !c.in.Scan() {
+			fmt.Fprintln(c.out)
+			return false
+		}
+
+		fields := strings.Fields(c.in.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		name := fields[0]
+		args := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(c.in.Text()), name))
+
+		var err error
+		switch name {
+		case "continue", "c":
+			err = c.d.Continue()
+		case "step", "s":
+			err = c.d.StepIn()
+		case "next", "n":
+			err = c.d.StepOver()
+		case "out", "o":
+			err = c.d.StepOut()
+		case "quit", "q":
+			return false
+
+		case "break", "b":
+			c.setBreakpoint(args)
+			continue
+		case "clear":
+			c.clearBreakpoint(args)
+			continue
+		case "breakpoints":
+			c.showBreakpoints()
+			continue
+		case "stack", "bt":
+			c.showStack()
+			continue
+		case "frame":
+			c.selectFrame(args)
+			continue
+		case "env":
+			c.showEnv()
+			continue
+		case "print", "p":
+			c.print(args)
+			continue
+		case "list", "l":
+			c.list()
+			continue
+		case "help", "h":
+			fmt.Fprint(c.out, consoleHelp)
+			continue
+		default:
+			fmt.Fprintf(c.out, "unknown command %s, try help\n", name)
+			continue
+		}
+
+		if err != nil {
+			fmt.Fprintln(c.out, err)
+			continue
+		}
+		return true
+	}
+}
+
+func (c *console) setBreakpoint(args string) {
+	lineArg, condition := args, ""
+	if i := strings.Index(args, " if "); i >= 0 {
+		lineArg, condition = args[:i], args[i+len(" if "):]
+	}
+
+	line, err := strconv.Atoi(strings.TrimSpace(lineArg))
+	if err != nil || line < 1 {
+		fmt.Fprintln(c.out, "usage: break LINE [if COND]")
+		return
+	}
+
+	bp, err := c.d.SetBreakpoint(line, condition)
+	if err != nil {
+		fmt.Fprintf(c.out, "bad condition: %s\n", err)
+		return
+	}
+	fmt.Fprintf(c.out, "breakpoint at line %d%s\n", bp.Line, describeCondition(bp))
+}
+
+func describeCondition(bp *Breakpoint) string {
+	if bp.condition == nil {
+		return ""
+	}
+	return " if " + bp.Condition
+}
+
+func (c *console) clearBreakpoint(args string) {
+	if args == "" {
+		c.d.ClearBreakpoints()
+		fmt.Fprintln(c.out, "cleared all breakpoints")
+		return
+	}
+
+	line, err := strconv.Atoi(args)
+	if err != nil {
+		fmt.Fprintln(c.out, "usage: clear [LINE]")
+		return
+	}
+	c.d.ClearBreakpoint(line)
+	fmt.Fprintf(c.out, "cleared the breakpoint at line %d\n", line)
+}
+
+func (c *console) showBreakpoints() {
+	bps := c.d.Breakpoints()
+	if len(bps) == 0 {
+		fmt.Fprintln(c.out, "no breakpoints")
+	}
+	for _, bp := range bps {
+		fmt.Fprintf(c.out, "line %d%s\n", bp.Line, describeCondition(bp))
+	}
+}
+
+func (c *console) showStack() {
+	frames, err := c.d.Stack()
+	if err != nil {
+		fmt.Fprintln(c.out, err)
+		return
+	}
+
+	for i, frame := range frames {
+		marker := " "
+		if i == c.frame {
+			marker = "*"
+		}
+		fmt.Fprintf(c.out, "%s %d %s at line %d\n", marker, i, frame.Name, frame.Line)
+	}
+}
+
+func (c *console) selectFrame(args string) {
+	frames, err := c.d.Stack()
+	if err != nil {
+		fmt.Fprintln(c.out, err)
+		return
+	}
+
+	i, err := strconv.Atoi(args)
+	if err != nil || i < 0 || i >= len(frames) {
+		fmt.Fprintf(c.out, "usage: frame N, with N from 0 to %d\n", len(frames)-1)
+		return
+	}
+	c.frame = i
+	fmt.Fprintf(c.out, "%d %s at line %d\n", i, frames[i].Name, frames[i].Line)
+	c.showLines(frames[i].Line, frames[i].Line)
+}
+
+// showEnv shows the environment chain of the selected frame, innermost
+// first, each with the names bound in it.
+func (c *console) showEnv() {
+	frames, err := c.d.Stack()
+	if err != nil {
+		fmt.Fprintln(c.out, err)
+		return
+	}
+
+	for _, scope := range Scopes(frames[c.frame].Env) {
+		fmt.Fprintf(c.out, "%s:\n", scope.Name)
+		names := scope.Env.LocalNames()
+		if len(names) == 0 {
+			fmt.Fprintln(c.out, "  (nothing bound)")
+		}
+		for _, name := range names {
+			value, _ := scope.Env.Get(name)
+			fmt.Fprintf(c.out, "  %s = %s\n", name, Describe(value))
+		}
+	}
+}
+
+func (c *console) print(args string) {
+	if args == "" {
+		fmt.Fprintln(c.out, "usage: print EXPR")
+		return
+	}
+
+	result, err := c.d.Evaluate(c.frame, args)
+	switch {
+	case err != nil:
+		fmt.Fprintln(c.out, err)
+	case result != nil:
+		fmt.Fprintln(c.out, result.Inspect())
+	}
+}
+
+func (c *console) list() {
+	frames, err := c.d.Stack()
+	if err != nil {
+		fmt.Fprintln(c.out, err)
+		return
+	}
+	line := frames[c.frame].Line
+	c.showLines(line-CONTEXT_LINES, line+CONTEXT_LINES)
+}
+
+// showLines shows lines from to through, marking the one the selected
+// frame is at.
+func (c *console) showLines(from, through int) {
+	frames, _ := c.d.Stack()
+	current := c.stop.Line
+	if c.frame < len(frames) {
+		current = frames[c.frame].Line
+	}
+
+	for line := from; line <= through; line++ {
+		if line < 1 || line > len(c.lines) {
+			continue
+		}
+		marker := " "
+		if line == current {
+			marker = ">"
+		}
+		fmt.Fprintf(c.out, "%s %4d | %s\n", marker, line, c.lines[line-1])
+	}
+}
+
+// Scope is one environment of a frame's chain.
+type Scope struct {
+	Name string
+	Env  *object.Environment
+}
+
+// Scopes returns env and the environments enclosing it, innermost first,
+// named locals, closure for those between, and globals for the outermost.
+func Scopes(env *object.Environment) []Scope {
+	scopes := []Scope{}
+	for e := env; e != nil; e = e.Outer() {
+		name := "closure"
+		switch {
+		case e.Outer() == nil:
+			name = "globals"
+		case e == env:
+			name = "locals"
+		}
+		scopes = append(scopes, Scope{Name: name, Env: e})
+	}
+	return scopes
+}
+
+// Describe shows obj on one line: functions by their parameters rather than
+// their whole bodies.
+func Describe(obj object.Object) string {
+	switch obj := obj.(type) {
+	case *object.Function:
+		name := obj.Name
+		if name == "" {
+			name = "fn"
+		}
+		return name + "(" + ast.ParametersString(obj.Parameters, obj.Defaults, obj.Rest) + ")"
+	case *object.BoundMethod:
+		return "method of " + obj.Receiver.Inspect()
+	case nil:
+		return "null"
+	}
+	return obj.Inspect()
+}
@@ -0,0 +1,442 @@
+// Package debugger runs a Monkey program so that it can be paused at
+// breakpoints and between steps, and its call stack and environments
+// inspected while it is paused.
+package debugger
+
+import (
+	"errors"
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// StopReason is why the program paused.
+type StopReason string
+
+const (
+	ENTRY      StopReason = "entry"
+	BREAKPOINT StopReason = "breakpoint"
+	STEP       StopReason = "step"
+	PAUSE      StopReason = "pause"
+)
+
+// Stop tells the frontend the program has paused before the statement at
+// Line and Column, or that it has finished if Done is set.
+type Stop struct {
+	Reason  StopReason
+	Line    int
+	Column  int
+	Message string // why a breakpoint's condition could not be evaluated
+
+	Done   bool
+	Result object.Object // what the program evaluated to, once Done
+}
+
+// Frame is a call in progress. The outermost frame is the program itself.
+type Frame struct {
+	Name     string
+	Function *object.Function // nil for the program
+	Line     int              // of the statement the frame is at
+	Column   int
+	Env      *object.Environment // the innermost environment of that statement
+}
+
+const (
+	PROGRAM_FRAME   = "<program>"
+	ANONYMOUS_FRAME = "<anonymous>"
+)
+
+// Breakpoint pauses the program before the first statement on Line, if its
+// Condition is empty or evaluates to something truthy there.
+type Breakpoint struct {
+	Line      int
+	Condition string
+
+	condition *ast.Program
+}
+
+type stepKind int
+
+const (
+	CONTINUE stepKind = iota
+	STEP_IN
+	STEP_OVER
+	STEP_OUT
+)
+
+// command resumes the paused program, or evaluates source in one of its
+// frames if reply is set.
+type command struct {
+	step stepKind
+
+	source string
+	frame  int
+	reply  chan evaluation
+}
+
+type evaluation struct {
+	result object.Object
+	err    error
+}
+
+var (
+	ErrNotPaused = errors.New("the program is not paused")
+	ErrNoFrame   = errors.New("no such frame")
+)
+
+// Debugger runs one program as the Tracer of its evaluation.
+type Debugger struct {
+	stops    chan Stop
+	commands chan command
+
+	mu          sync.Mutex // guards the fields below
+	breakpoints map[int]*Breakpoint
+	stack       []*Frame
+	paused      bool
+	pausing     bool // a pause was asked for while running
+	detached    bool
+
+	// only used by the goroutine evaluating the program
+	step       stepKind
+	stepDepth  int
+	evaluating bool // evaluating a condition or an expression for the frontend
+}
+
+func New() *Debugger {
+	return &Debugger{
+		stops:       make(chan Stop, 1),
+		commands:    make(chan command),
+		breakpoints: map[int]*Breakpoint{},
+	}
+}
+
+// SetBreakpoint sets a breakpoint on line, replacing any there already. It
+// fails if condition is not a valid expression.
+func (d *Debugger) SetBreakpoint(line int, condition string) (*Breakpoint, error) {
+	bp := &Breakpoint{Line: line, Condition: condition}
+	if strings.TrimSpace(condition) != "" {
+		program, err := parse(condition)
+		if err != nil {
+			return nil, err
+		}
+		bp.condition = program
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.breakpoints[line] = bp
+	return bp, nil
+}
+
+func (d *Debugger) ClearBreakpoint(line int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.breakpoints, line)
+}
+
+func (d *Debugger) ClearBreakpoints() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.breakpoints = map[int]*Breakpoint{}
+}
+
+// Breakpoints returns the breakpoints, sorted by line.
+func (d *Debugger) Breakpoints() []*Breakpoint {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	bps := []*Breakpoint{}
+	for _, bp := range d.breakpoints {
+		bps = append(bps, bp)
+	}
+	sort.Slice(bps, func(i, j int) bool { return bps[i].Line < bps[j].Line })
+	return bps
+}
+
+// Start evaluates program in a new goroutine with a copy of ctx that has d
+// as its Tracer, pausing before its first statement if stopOnEntry is set.
+// Every pause and the end of the program are sent on Stops.
+func (d *Debugger) Start(program *ast.Program, ctx *evaluator.Context, stopOnEntry bool) {
+	traced := *ctx
+	traced.Tracer = d
+	env := evaluator.NewEnvironment(&traced)
+
+	d.stack = []*Frame{{Name: PROGRAM_FRAME, Env: env}}
+	if stopOnEntry {
+		d.step = STEP_IN
+	}
+
+	go func() {
+		result := evaluator.Eval(program, env)
+		d.stops <- Stop{Done: true, Result: result}
+	}()
+}
+
+// Stops delivers a Stop each time the program pauses, and a last one when
+// it is done.
+func (d *Debugger) Stops() <-chan Stop {
+	return d.stops
+}
+
+// Continue runs the paused program until a breakpoint.
+func (d *Debugger) Continue() error { return d.resume(CONTINUE) }
+
+// StepIn runs the paused program until the next statement, in whichever
+// function that is.
+func (d *Debugger) StepIn() error { return d.resume(STEP_IN) }
+
+// StepOver runs the paused program until the next statement of the
+// current function, or of a function it returns to.
+func (d *Debugger) StepOver() error { return d.resume(STEP_OVER) }
+
+// StepOut runs the paused program until the current function has returned,
+// pausing at the next statement of its caller.
+func (d *Debugger) StepOut() error { return d.resume(STEP_OUT) }
+
+func (d *Debugger) resume(step stepKind) error {
+	if !d.isPaused() {
+		return ErrNotPaused
+	}
+	d.commands <- command{step: step}
+	return nil
+}
+
+// Pause asks the running program to pause before its next statement.
+func (d *Debugger) Pause() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.pausing = true
+}
+
+// Detach clears the breakpoints and lets the program run to its end without
+// pausing again.
+func (d *Debugger) Detach() {
+	d.mu.Lock()
+	d.detached = true
+	d.breakpoints = map[int]*Breakpoint{}
+	paused := d.paused
+	d.mu.Unlock()
+
+	if paused {
+		d.commands <- command{step: CONTINUE}
+	}
+}
+
+func (d *Debugger) isPaused() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.paused
+}
+
+// Stack returns the frames of the paused program, innermost first.
+func (d *Debugger) Stack() ([]Frame, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.paused {
+		return nil, ErrNotPaused
+	}
+	frames := []Frame{}
+	for i := len(d.stack) - 1; i >= 0; i-- {
+		frames = append(frames, *d.stack[i])
+	}
+	return frames, nil
+}
+
+// Evaluate evaluates source in the environment of a frame of the paused
+// program, numbered from the innermost. Breakpoints do not pause it. It
+// fails if source does not parse.
+func (d *Debugger) Evaluate(frame int, source string) (object.Object, error) {
+	if !d.isPaused() {
+		return nil, ErrNotPaused
+	}
+	reply := make(chan evaluation)
+	d.commands <- command{source: source, frame: frame, reply: reply}
+
+	e := <-reply
+	return e.result, e.err
+}
+
+func parse(source string) (*ast.Program, error) {
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, errors.New(strings.Join(p.Errors(), "; "))
+	}
+	return program, nil
+}
+
+// Statement pauses the program before stmt if it is at a breakpoint, a step
+// has ended or a pause was asked for.
+func (d *Debugger) Statement(stmt ast.Statement, env *object.Environment) {
+	if d.evaluating {
+		return
+	}
+
+	tok := ast.StatementToken(stmt)
+
+	d.mu.Lock()
+	frame := d.stack[len(d.stack)-1]
+	moved := frame.Line != tok.Line
+	frame.Line, frame.Column, frame.Env = tok.Line, tok.Column, env
+	depth := len(d.stack)
+	bp := d.breakpoints[tok.Line]
+	pausing := d.pausing && !d.detached
+	d.pausing = false
+	d.mu.Unlock()
+
+	stop := Stop{Line: tok.Line, Column: tok.Column}
+	switch {
+	case pausing:
+		stop.Reason = PAUSE
+	case d.stepEnded(depth, moved):
+		stop.Reason = STEP
+		if d.stepDepth == 0 {
+			stop.Reason = ENTRY
+		}
+	case bp != nil && moved:
+		hit, message := d.check(bp, env)
+		if !hit {
+			return
+		}
+		stop.Reason = BREAKPOINT
+		stop.Message = message
+	default:
+		return
+	}
+
+	d.wait(stop, depth)
+}
+
+// stepEnded reports whether a statement at depth ends the step being
+// taken. Steps go at least to the next line of the frame they started in.
+func (d *Debugger) stepEnded(depth int, moved bool) bool {
+	switch d.step {
+	case STEP_IN:
+		return depth != d.stepDepth || moved
+	case STEP_OVER:
+		return depth < d.stepDepth || depth == d.stepDepth && moved
+	case STEP_OUT:
+		return depth < d.stepDepth
+	}
+	return false
+}
+
+// check evaluates the condition of bp in env, reporting whether the program
+// should pause there. A condition that fails pauses it with the reason.
+func (d *Debugger) check(bp *Breakpoint, env *object.Environment) (bool, string) {
+	if bp.condition == nil {
+		return true, ""
+	}
+
+	d.evaluating = true
+	result := evaluator.Eval(bp.condition, env)
+	d.evaluating = false
+
+	if errObj, ok := result.(*object.Error); ok {
+		return true, "condition " + bp.Condition + " failed: " + errObj.Message
+	}
+	return result != nil && result != evaluator.NULL && result != evaluator.FALSE, ""
+}
+
+// wait sends stop and serves the frontend until it resumes the program.
+func (d *Debugger) wait(stop Stop, depth int) {
+	d.mu.Lock()
+	if d.detached {
+		d.mu.Unlock()
+		return
+	}
+	d.paused = true
+	d.mu.Unlock()
+
+	d.stops <- stop
+	for cmd := range d.commands {
+		if cmd.reply != nil {
+			cmd.reply <- d.evaluate(cmd.frame, cmd.source)
+			continue
+		}
+
+		d.mu.Lock()
+		d.paused = false
+		d.mu.Unlock()
+
+		d.step, d.stepDepth = cmd.step, depth
+		return
+	}
+}
+
+func (d *Debugger) evaluate(frame int, source string) evaluation {
+	d.mu.Lock()
+	if frame < 0 || frame >= len(d.stack) {
+		d.mu.Unlock()
+		return evaluation{err: ErrNoFrame}
+	}
+	env := d.stack[len(d.stack)-1-frame].Env
+	d.mu.Unlock()
+
+	program, err := parse(source)
+	if err != nil {
+		return evaluation{err: err}
+	}
+
+	d.evaluating = true
+	defer func() { d.evaluating = false }()
+	return evaluation{result: evaluator.Eval(program, env)}
+}
+
+// Call pushes a frame for fn.
+func (d *Debugger) Call(fn *object.Function, env *object.Environment) {
+	if d.evaluating {
+		return
+	}
+
+	name := fn.Name
+	if name == "" {
+		name = ANONYMOUS_FRAME
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.stack = append(d.stack, &Frame{Name: name, Function: fn, Env: env})
+}
+
+// Return pops the frame of fn.
+func (d *Debugger) Return(fn *object.Function, result object.Object) {
+	if d.evaluating {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.stack = d.stack[:len(d.stack)-1]
+}
+
+// StatementLine returns the first line at or after line on which a statement
+// of program starts, which is where a breakpoint at line can pause, and
+// false when there is none.
+func StatementLine(program *ast.Program, line int) (int, bool) {
+	found := 0
+	ast.Inspect(program, func(node ast.Node) bool {
+		if stmt, ok := node.(ast.Statement); ok {
+			// blocks have the zero token, and are never paused at
+			l := ast.StatementToken(stmt).Line
+			if l != 0 && l >= line && (found == 0 || l < found) {
+				found = l
+			}
+		}
+		return true
+	})
+	return found, found != 0
+}
@@ -0,0 +1,305 @@
+package debugger
+
+import (
+	"bytes"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"strings"
+	"testing"
+	"time"
+)
+
+const program = `let add = fn(a, b) {
+  let sum = a + b;
+  sum
+};
+let x = add(1, 2);
+let y = add(x, 10);
+y`
+
+func start(t *testing.T, d *Debugger, source string, stopOnEntry bool) {
+	p := parser.New(lexer.New(source))
+	prog := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	d.Start(prog, &evaluator.Context{}, stopOnEntry)
+}
+
+func nextStop(t *testing.T, d *Debugger) Stop {
+	t.Helper()
+
+	select {
+	case stop := <-d.Stops():
+		return stop
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the program did not stop")
+		return Stop{}
+	}
+}
+
+func expectStop(t *testing.T, d *Debugger, reason StopReason, line int) {
+	t.Helper()
+
+	stop := nextStop(t, d)
+	if stop.Done || stop.Reason != reason || stop.Line != line {
+		t.Fatalf("wrong stop. expected=%s at line %d, got=%+v", reason, line, stop)
+	}
+}
+
+func expectDone(t *testing.T, d *Debugger, expected int64) {
+	t.Helper()
+
+	stop := nextStop(t, d)
+	if !stop.Done {
+		t.Fatalf("expected the program to be done. got=%+v", stop)
+	}
+	result, ok := stop.Result.(*object.Integer)
+	if !ok || result.Value != expected {
+		t.Errorf("wrong result. expected=%d, got=%v", expected, stop.Result)
+	}
+}
+
+func expectValue(t *testing.T, d *Debugger, frame int, source, expected string) {
+	t.Helper()
+
+	result, err := d.Evaluate(frame, source)
+	if err != nil {
+		t.Fatalf("evaluating %s failed: %s", source, err)
+	}
+	if result.Inspect() != expected {
+		t.Errorf("%s in frame %d wrong. expected=%s, got=%s", source, frame, expected, result.Inspect())
+	}
+}
+
+func TestBreakpoints(t *testing.T) {
+	d := New()
+	if _, err := d.SetBreakpoint(2, ""); err != nil {
+		t.Fatal(err)
+	}
+	start(t, d, program, false)
+
+	expectStop(t, d, BREAKPOINT, 2)
+	frames, err := d.Stack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 2 || frames[0].Name != "add" || frames[0].Line != 2 ||
+		frames[1].Name != PROGRAM_FRAME || frames[1].Line != 5 {
+		t.Errorf("wrong stack: %+v", frames)
+	}
+	expectValue(t, d, 0, "a + b", "3")
+	expectValue(t, d, 1, "add(10, 20)", "30") // breakpoints do not pause it
+
+	if err := d.Continue(); err != nil {
+		t.Fatal(err)
+	}
+	expectStop(t, d, BREAKPOINT, 2)
+	expectValue(t, d, 0, "a", "3")
+
+	if err := d.Continue(); err != nil {
+		t.Fatal(err)
+	}
+	expectDone(t, d, 13)
+
+	if err := d.Continue(); err != ErrNotPaused {
+		t.Errorf("expected continuing a finished program to fail. got=%v", err)
+	}
+}
+
+func TestConditionalBreakpoints(t *testing.T) {
+	d := New()
+	if _, err := d.SetBreakpoint(2, "a > 1 && b == 10"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.SetBreakpoint(3, "let"); err == nil {
+		t.Errorf("expected an invalid condition to be refused")
+	}
+	start(t, d, program, false)
+
+	expectStop(t, d, BREAKPOINT, 2)
+	expectValue(t, d, 0, "a", "3")
+	d.Continue()
+	expectDone(t, d, 13)
+
+	d = New()
+	d.SetBreakpoint(3, "missing > 1")
+	start(t, d, program, false)
+
+	stop := nextStop(t, d)
+	if stop.Reason != BREAKPOINT || stop.Line != 3 ||
+		!strings.Contains(stop.Message, "identifier not found: missing") {
+		t.Errorf("expected a failing condition to pause with its error. got=%+v", stop)
+	}
+	d.ClearBreakpoints()
+	d.Continue()
+	expectDone(t, d, 13)
+}
+
+func TestStepping(t *testing.T) {
+	d := New()
+	start(t, d, program, true)
+
+	expectStop(t, d, ENTRY, 1)
+	d.StepOver()
+	expectStop(t, d, STEP, 5)
+	d.StepIn()
+	expectStop(t, d, STEP, 2)
+	d.StepOver()
+	expectStop(t, d, STEP, 3)
+	d.StepOver()
+	expectStop(t, d, STEP, 6)
+	d.StepIn()
+	expectStop(t, d, STEP, 2)
+	d.StepOut()
+	expectStop(t, d, STEP, 7)
+	d.StepOver()
+	expectDone(t, d, 13)
+}
+
+func TestSameLineStatements(t *testing.T) {
+	d := New()
+	d.SetBreakpoint(2, "")
+	start(t, d, "let f = fn(n) { n };\nlet a = if (true) { f(1) } else { 2 }; let b = f(2);\nb", false)
+
+	// the statements in the if and after it on the same line do not pause
+	// at the breakpoint again, but the statement in f does
+	expectStop(t, d, BREAKPOINT, 2)
+	d.StepOver()
+	expectStop(t, d, STEP, 3)
+	d.Continue()
+	expectDone(t, d, 2)
+
+	d = New()
+	d.SetBreakpoint(1, "")
+	start(t, d, "let f = fn(n) { if (n > 0) { f(n - 1) } else { n } };\nf(1)", false)
+
+	expectStop(t, d, BREAKPOINT, 1)
+	for i := 0; i < 2; i++ {
+		d.Continue()
+		expectStop(t, d, BREAKPOINT, 1)
+		frames, _ := d.Stack()
+		if len(frames) != i+2 {
+			t.Errorf("wrong depth of recursion. expected=%d, got=%d", i+2, len(frames))
+		}
+	}
+	d.Continue()
+	expectDone(t, d, 0)
+}
+
+func TestEnvironmentChain(t *testing.T) {
+	d := New()
+	d.SetBreakpoint(3, "")
+	start(t, d, `let make = fn(n) {
+  fn(x) {
+    x + n
+  }
+};
+let addTwo = make(2);
+addTwo(5)`, false)
+
+	expectStop(t, d, BREAKPOINT, 3)
+	frames, _ := d.Stack()
+	if len(frames) != 2 || frames[0].Name != ANONYMOUS_FRAME {
+		t.Fatalf("wrong stack: %+v", frames)
+	}
+
+	expected := []struct {
+		name  string
+		names string
+	}{
+		{"locals", "x"},
+		{"closure", "n"},
+		{"globals", "addTwo make"},
+	}
+
+	scopes := Scopes(frames[0].Env)
+	if len(scopes) != len(expected) {
+		t.Fatalf("wrong number of scopes. expected=%d, got=%d", len(expected), len(scopes))
+	}
+	for i, e := range expected {
+		names := strings.Join(scopes[i].Env.LocalNames(), " ")
+		if scopes[i].Name != e.name || names != e.names {
+			t.Errorf("scope %d wrong. expected=%s %q, got=%s %q", i, e.name, e.names, scopes[i].Name, names)
+		}
+	}
+
+	value, _ := scopes[2].Env.Get("make")
+	if Describe(value) != "make(n)" {
+		t.Errorf("wrong description of make: %s", Describe(value))
+	}
+
+	d.Continue()
+	expectDone(t, d, 7)
+}
+
+func TestPauseAndDetach(t *testing.T) {
+	d := New()
+	d.SetBreakpoint(2, "")
+	d.Pause()
+	start(t, d, program, false)
+
+	expectStop(t, d, PAUSE, 1)
+	d.Continue()
+	expectStop(t, d, BREAKPOINT, 2)
+
+	d.Detach()
+	expectDone(t, d, 13)
+}
+
+func TestConsole(t *testing.T) {
+	d := New()
+	start(t, d, program, true)
+
+	input := strings.Join([]string{
+		"break 2 if a == 3",
+		"breakpoints",
+		"c",
+		"bt",
+		"print a * 2",
+		"frame 1",
+		"env",
+		"p y",
+		"bogus",
+		"clear",
+		"c",
+	}, "\n")
+	out := &bytes.Buffer{}
+
+	result := Console(d, program, strings.NewReader(input), out)
+	if integer, ok := result.(*object.Integer); !ok || integer.Value != 13 {
+		t.Errorf("wrong result. got=%v", result)
+	}
+
+	expected := []string{
+		"paused at line 1 (entry)\n>    1 | let add = fn(a, b) {\n",
+		"breakpoint at line 2 if a == 3\n",
+		"line 2 if a == 3\n",
+		"paused at line 2 (breakpoint)\n",
+		"* 0 add at line 2\n  1 <program> at line 6\n",
+		"(debug) 6\n",
+		"1 <program> at line 6\n>    6 | let y = add(x, 10);\n",
+		"globals:\n  add = add(a, b)\n  x = 3\n",
+		"identifier not found: y",
+		"unknown command bogus",
+		"cleared all breakpoints\n",
+	}
+	for _, e := range expected {
+		if !strings.Contains(out.String(), e) {
+			t.Errorf("console output is missing %q:\n%s", e, out.String())
+		}
+	}
+}
+
+func TestConsoleQuit(t *testing.T) {
+	d := New()
+	start(t, d, program, true)
+
+	out := &bytes.Buffer{}
+	if result := Console(d, program, strings.NewReader("step\nquit\n"), out); result != nil {
+		t.Errorf("expected no result after quitting. got=%v", result)
+	}
+	expectDone(t, d, 13)
+}
@@ -0,0 +1,98 @@
+package evaluator
+
+import (
+	"math"
+	"math/big"
+	"monkey/object"
+)
+
+func isInteger(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.BIG_INTEGER_OBJ
+}
+
+func addInt64(a, b int64) (int64, bool) {
+	sum := a + b
+	return sum, (b >= 0) == (sum >= a)
+}
+
+func subInt64(a, b int64) (int64, bool) {
+	difference := a - b
+	return difference, (b >= 0) == (difference <= a)
+}
+
+func mulInt64(a, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	if (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return 0, false
+	}
+
+	product := a * b
+	return product, product/b == a
+}
+
+func integerOverflow(
+	operator string,
+	left, right object.Object,
+	env *object.Environment,
+) object.Object {
+	if contextOf(env).CheckedArithmetic {
+		return newError("integer overflow: %s %s %s",
+			left.Inspect(), operator, right.Inspect())
+	}
+
+	return evalBigIntegerInfixExpression(operator, left, right)
+}
+
+func toBigInt(obj object.Object) *big.Int {
+	switch obj := obj.(type) {
+	case *object.BigInteger:
+		return obj.Value
+	default:
+		return big.NewInt(obj.(*object.Integer).Value)
+	}
+}
+
+// normalizeInteger keeps values that fit in an int64 as plain integers, so
+// an integer only has one representation.
+func normalizeInteger(value *big.Int) object.Object {
+	if value.IsInt64() {
+		return &object.Integer{Value: value.Int64()}
+	}
+
+	return &object.BigInteger{Value: value}
+}
+
+func evalBigIntegerInfixExpression(
+	operator string,
+	left, right object.Object,
+) object.Object {
+	leftVal := toBigInt(left)
+	rightVal := toBigInt(right)
+
+	switch operator {
+	case "+":
+		return normalizeInteger(new(big.Int).Add(leftVal, rightVal))
+	case "-":
+		return normalizeInteger(new(big.Int).Sub(leftVal, rightVal))
+	case "*":
+		return normalizeInteger(new(big.Int).Mul(leftVal, rightVal))
+	case "/":
+		if rightVal.Sign() == 0 {
+			return newError("division by zero: %s / 0", leftVal.String())
+		}
+		return normalizeInteger(new(big.Int).Quo(leftVal, rightVal))
+	case "<":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) < 0)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) > 0)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) == 0)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) != 0)
+	default:
+		return newError("unknown operator: %s %s %s",
+			left.Type(), operator, right.Type())
+	}
+}
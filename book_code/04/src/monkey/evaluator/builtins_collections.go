@@ -0,0 +1,550 @@
+package evaluator
+
+import (
+	"bytes"
+	"monkey/object"
+	"sort"
+	"strings"
+)
+
+// The collection builtins call back into Monkey functions through
+// applyFunction, which itself looks up builtins, so they are registered in
+// init to avoid an initialization cycle.
+func init() {
+	builtins["map"] = &object.Builtin{Fn: builtinMap}
+	builtins["filter"] = &object.Builtin{Fn: builtinFilter}
+	builtins["reduce"] = &object.Builtin{Fn: builtinReduce}
+	builtins["range"] = &object.Builtin{Fn: builtinRange}
+	builtins["sort"] = &object.Builtin{Fn: builtinSort}
+	builtins["reverse"] = &object.Builtin{Fn: builtinReverse}
+	builtins["join"] = &object.Builtin{Fn: builtinJoin}
+	builtins["split"] = &object.Builtin{Fn: builtinSplit}
+	builtins["keys"] = &object.Builtin{Fn: builtinKeys}
+	builtins["values"] = &object.Builtin{Fn: builtinValues}
+	builtins["delete"] = &object.Builtin{Fn: builtinDelete}
+	builtins["freeze"] = &object.Builtin{Fn: builtinFreeze}
+	builtins["contains"] = &object.Builtin{Fn: builtinContains}
+	builtins["index_of"] = &object.Builtin{Fn: builtinIndexOf}
+	builtins["zip"] = &object.Builtin{Fn: builtinZip}
+	builtins["flatten"] = &object.Builtin{Fn: builtinFlatten}
+	builtins["slice"] = &object.Builtin{Fn: builtinSlice}
+}
+
+// MAX_RANGE is the most elements range produces, so that a mistaken bound
+// is reported instead of exhausting memory.
+const MAX_RANGE = 10000000
+
+func wrongArgumentCount(got int, want string) *object.Error {
+	return newError("wrong number of arguments. got=%d, want=%s", got, want)
+}
+
+func wrongArgumentType(name string, want object.ObjectType, got object.Object) *object.Error {
+	return newError("argument to `%s` must be %s, got %s", name, want, got.Type())
+}
+
+func builtinMap(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return wrongArgumentCount(len(args), "2")
+	}
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return wrongArgumentType("map", object.ARRAY_OBJ, args[0])
+	}
+
+	mapped := make([]object.Object, len(arr.Elements))
+	for i, el := range arr.Elements {
+		result := applyFunction(args[1], []object.Object{el}, env)
+		if isError(result) {
+			return result
+		}
+		mapped[i] = result
+	}
+
+	return &object.Array{Elements: mapped}
+}
+
+func builtinFilter(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return wrongArgumentCount(len(args), "2")
+	}
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return wrongArgumentType("filter", object.ARRAY_OBJ, args[0])
+	}
+
+	filtered := []object.Object{}
+	for _, el := range arr.Elements {
+		keep := applyFunction(args[1], []object.Object{el}, env)
+		if isError(keep) {
+			return keep
+		}
+		if isTruthy(keep) {
+			filtered = append(filtered, el)
+		}
+	}
+
+	return &object.Array{Elements: filtered}
+}
+
+// builtinReduce folds an array from the left. Without an initial value the
+// first element is used as the accumulator.
+func builtinReduce(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 2 && len(args) != 3 {
+		return wrongArgumentCount(len(args), "2 or 3")
+	}
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return wrongArgumentType("reduce", object.ARRAY_OBJ, args[0])
+	}
+
+	elements := arr.Elements
+	var acc object.Object
+	if len(args) == 3 {
+		acc = args[2]
+	} else if len(elements) > 0 {
+		acc = elements[0]
+		elements = elements[1:]
+	} else {
+		return newError("`reduce` of empty array with no initial value")
+	}
+
+	for _, el := range elements {
+		acc = applyFunction(args[1], []object.Object{acc, el}, env)
+		if isError(acc) {
+			return acc
+		}
+	}
+
+	return acc
+}
+
+// builtinRange accepts range(end), range(start, end) or
+// range(start, end, step) and excludes end, like slices do.
+func builtinRange(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) < 1 || len(args) > 3 {
+		return wrongArgumentCount(len(args), "1 to 3")
+	}
+
+	bounds := []int64{}
+	for _, arg := range args {
+		integer, ok := arg.(*object.Integer)
+		if !ok {
+			return wrongArgumentType("range", object.INTEGER_OBJ, arg)
+		}
+		bounds = append(bounds, integer.Value)
+	}
+
+	start, end, step := int64(0), bounds[0], int64(1)
+	if len(bounds) > 1 {
+		start, end = bounds[0], bounds[1]
+	}
+	if len(bounds) > 2 {
+		step = bounds[2]
+	}
+	if step == 0 {
+		return newError("`range` step must not be zero")
+	}
+
+	// The span and the step are taken as unsigned magnitudes, which cannot
+	// overflow, and the elements are counted before any is made.
+	var span, stride uint64
+	switch {
+	case step > 0 && start < end:
+		span, stride = uint64(end)-uint64(start), uint64(step)
+	case step < 0 && start > end:
+		span, stride = uint64(start)-uint64(end), 0-uint64(step)
+	}
+
+	count := uint64(0)
+	if span > 0 {
+		count = (span-1)/stride + 1
+	}
+	if count > MAX_RANGE {
+		return newError("`range` would produce %d elements, more than %d", count, MAX_RANGE)
+	}
+
+	elements := make([]object.Object, count)
+	for i := range elements {
+		elements[i] = &object.Integer{Value: int64(uint64(start) + uint64(i)*uint64(step))}
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+// builtinSort returns a sorted copy of an array. Without a comparator it
+// orders integers or strings; a comparator returns a negative integer when
+// its first argument sorts first.
+func builtinSort(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return wrongArgumentCount(len(args), "1 or 2")
+	}
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return wrongArgumentType("sort", object.ARRAY_OBJ, args[0])
+	}
+
+	sorted := make([]object.Object, len(arr.Elements))
+	copy(sorted, arr.Elements)
+
+	var sortErr object.Object
+	less := func(a, b object.Object) bool {
+		if sortErr != nil {
+			return false
+		}
+
+		if len(args) == 1 {
+			result, err := compareObjects(a, b)
+			if err != nil {
+				sortErr = err
+			}
+			return result < 0
+		}
+
+		result := applyFunction(args[1], []object.Object{a, b}, env)
+		integer, ok := result.(*object.Integer)
+		if !ok {
+			if isError(result) {
+				sortErr = result
+			} else {
+				sortErr = newError("`sort` comparator must return INTEGER, got %s",
+					result.Type())
+			}
+			return false
+		}
+		return integer.Value < 0
+	}
+
+	sort.SliceStable(sorted, func(i, j int) bool { return less(sorted[i], sorted[j]) })
+	if sortErr != nil {
+		return sortErr
+	}
+
+	return &object.Array{Elements: sorted}
+}
+
+func compareObjects(a, b object.Object) (int, *object.Error) {
+	if isInteger(a) && isInteger(b) {
+		return toBigInt(a).Cmp(toBigInt(b)), nil
+	}
+
+	switch a := a.(type) {
+	case *object.String:
+		if b, ok := b.(*object.String); ok {
+			return strings.Compare(a.Value, b.Value), nil
+		}
+	}
+
+	return 0, newError("cannot compare %s with %s", a.Type(), b.Type())
+}
+
+func builtinReverse(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return wrongArgumentCount(len(args), "1")
+	}
+
+	switch arg := args[0].(type) {
+	case *object.Array:
+		length := len(arg.Elements)
+		reversed := make([]object.Object, length)
+		for i, el := range arg.Elements {
+			reversed[length-1-i] = el
+		}
+		return &object.Array{Elements: reversed}
+	case *object.String:
+		runes := []rune(arg.Value)
+		for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+			runes[i], runes[j] = runes[j], runes[i]
+		}
+		return &object.String{Value: string(runes)}
+	default:
+		return newError("argument to `reverse` not supported, got %s", arg.Type())
+	}
+}
+
+func builtinJoin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return wrongArgumentCount(len(args), "1 or 2")
+	}
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return wrongArgumentType("join", object.ARRAY_OBJ, args[0])
+	}
+
+	separator := ""
+	if len(args) == 2 {
+		str, ok := args[1].(*object.String)
+		if !ok {
+			return wrongArgumentType("join", object.STRING_OBJ, args[1])
+		}
+		separator = str.Value
+	}
+
+	var out bytes.Buffer
+	for i, el := range arr.Elements {
+		if i > 0 {
+			out.WriteString(separator)
+		}
+		if str, ok := el.(*object.String); ok {
+			out.WriteString(str.Value)
+		} else {
+			out.WriteString(el.Inspect())
+		}
+	}
+
+	return &object.String{Value: out.String()}
+}
+
+// builtinSplit splits a string around a separator; an empty separator
+// splits it into characters.
+func builtinSplit(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return wrongArgumentCount(len(args), "2")
+	}
+	str, ok := args[0].(*object.String)
+	if !ok {
+		return wrongArgumentType("split", object.STRING_OBJ, args[0])
+	}
+	separator, ok := args[1].(*object.String)
+	if !ok {
+		return wrongArgumentType("split", object.STRING_OBJ, args[1])
+	}
+
+	parts := strings.Split(str.Value, separator.Value)
+	elements := make([]object.Object, len(parts))
+	for i, part := range parts {
+		elements[i] = &object.String{Value: part}
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+func builtinKeys(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return wrongArgumentCount(len(args), "1")
+	}
+	hash, ok := args[0].(*object.Hash)
+	if !ok {
+		return wrongArgumentType("keys", object.HASH_OBJ, args[0])
+	}
+
+	elements := []object.Object{}
+	for _, pair := range hash.Pairs() {
+		elements = append(elements, pair.Key)
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+func builtinValues(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return wrongArgumentCount(len(args), "1")
+	}
+	hash, ok := args[0].(*object.Hash)
+	if !ok {
+		return wrongArgumentType("values", object.HASH_OBJ, args[0])
+	}
+
+	elements := []object.Object{}
+	for _, pair := range hash.Pairs() {
+		elements = append(elements, pair.Value)
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+// builtinDelete removes a key from a hash and returns its value, or null if
+// the hash did not contain it.
+func builtinDelete(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return wrongArgumentCount(len(args), "2")
+	}
+	hash, ok := args[0].(*object.Hash)
+	if !ok {
+		return wrongArgumentType("delete", object.HASH_OBJ, args[0])
+	}
+	if hash.Frozen {
+		return newError("cannot delete from a frozen hash")
+	}
+	key, ok := object.AsHashable(args[1])
+	if !ok {
+		return unusableHashKey(args[1])
+	}
+
+	pair, ok := hash.Delete(key)
+	if !ok {
+		return NULL
+	}
+	return pair.Value
+}
+
+// builtinFreeze marks a hash as frozen. Frozen hashes cannot be changed and
+// can be used as hash keys.
+func builtinFreeze(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return wrongArgumentCount(len(args), "1")
+	}
+	hash, ok := args[0].(*object.Hash)
+	if !ok {
+		return wrongArgumentType("freeze", object.HASH_OBJ, args[0])
+	}
+
+	hash.Frozen = true
+	return hash
+}
+
+func builtinContains(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return wrongArgumentCount(len(args), "2")
+	}
+
+	switch container := args[0].(type) {
+	case *object.Array:
+		for _, el := range container.Elements {
+			if object.Equal(el, args[1]) {
+				return TRUE
+			}
+		}
+		return FALSE
+	case *object.String:
+		needle, ok := args[1].(*object.String)
+		if !ok {
+			return wrongArgumentType("contains", object.STRING_OBJ, args[1])
+		}
+		return nativeBoolToBooleanObject(strings.Contains(container.Value, needle.Value))
+	case *object.Hash:
+		key, ok := object.AsHashable(args[1])
+		if !ok {
+			return unusableHashKey(args[1])
+		}
+		_, ok = container.Get(key)
+		return nativeBoolToBooleanObject(ok)
+	case *object.Set:
+		element, ok := object.AsHashable(args[1])
+		if !ok {
+			return unusableSetElement(args[1])
+		}
+		return nativeBoolToBooleanObject(container.Contains(element))
+	default:
+		return newError("argument to `contains` not supported, got %s", container.Type())
+	}
+}
+
+// builtinIndexOf returns the position of the first match, counting string
+// positions in characters, or -1 if there is none.
+func builtinIndexOf(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return wrongArgumentCount(len(args), "2")
+	}
+
+	switch container := args[0].(type) {
+	case *object.Array:
+		for i, el := range container.Elements {
+			if object.Equal(el, args[1]) {
+				return &object.Integer{Value: int64(i)}
+			}
+		}
+		return &object.Integer{Value: -1}
+	case *object.String:
+		needle, ok := args[1].(*object.String)
+		if !ok {
+			return wrongArgumentType("index_of", object.STRING_OBJ, args[1])
+		}
+		idx := strings.Index(container.Value, needle.Value)
+		if idx < 0 {
+			return &object.Integer{Value: -1}
+		}
+		return &object.Integer{Value: int64(len([]rune(container.Value[:idx])))}
+	default:
+		return newError("argument to `index_of` not supported, got %s", container.Type())
+	}
+}
+
+// builtinZip pairs up the elements of its arrays, stopping at the end of
+// the shortest one.
+func builtinZip(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) < 2 {
+		return wrongArgumentCount(len(args), "at least 2")
+	}
+
+	arrays := make([]*object.Array, len(args))
+	length := -1
+	for i, arg := range args {
+		arr, ok := arg.(*object.Array)
+		if !ok {
+			return wrongArgumentType("zip", object.ARRAY_OBJ, arg)
+		}
+		arrays[i] = arr
+		if length < 0 || len(arr.Elements) < length {
+			length = len(arr.Elements)
+		}
+	}
+
+	zipped := make([]object.Object, length)
+	for i := 0; i < length; i++ {
+		tuple := make([]object.Object, len(arrays))
+		for j, arr := range arrays {
+			tuple[j] = arr.Elements[i]
+		}
+		zipped[i] = &object.Array{Elements: tuple}
+	}
+
+	return &object.Array{Elements: zipped}
+}
+
+// builtinFlatten removes one level of nesting, or depth levels when given.
+func builtinFlatten(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return wrongArgumentCount(len(args), "1 or 2")
+	}
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return wrongArgumentType("flatten", object.ARRAY_OBJ, args[0])
+	}
+
+	depth := int64(1)
+	if len(args) == 2 {
+		integer, ok := args[1].(*object.Integer)
+		if !ok {
+			return wrongArgumentType("flatten", object.INTEGER_OBJ, args[1])
+		}
+		depth = integer.Value
+	}
+
+	return &object.Array{Elements: flattenElements(arr.Elements, depth)}
+}
+
+func flattenElements(elements []object.Object, depth int64) []object.Object {
+	flattened := []object.Object{}
+	for _, el := range elements {
+		if nested, ok := el.(*object.Array); ok && depth > 0 {
+			flattened = append(flattened, flattenElements(nested.Elements, depth-1)...)
+		} else {
+			flattened = append(flattened, el)
+		}
+	}
+	return flattened
+}
+
+// builtinSlice is the function form of the a[start:end] slice operator.
+func builtinSlice(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 2 && len(args) != 3 {
+		return wrongArgumentCount(len(args), "2 or 3")
+	}
+
+	switch args[0].(type) {
+	case *object.Array, *object.String:
+	default:
+		return newError("argument to `slice` not supported, got %s", args[0].Type())
+	}
+
+	bounds := []*int64{nil, nil}
+	for i, arg := range args[1:] {
+		integer, ok := arg.(*object.Integer)
+		if !ok {
+			return wrongArgumentType("slice", object.INTEGER_OBJ, arg)
+		}
+		bounds[i] = &integer.Value
+	}
+
+	return sliceObject(args[0], bounds[0], bounds[1])
+}
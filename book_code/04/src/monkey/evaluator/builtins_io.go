@@ -0,0 +1,217 @@
+package evaluator
+
+import (
+	"io"
+	"io/ioutil"
+	"monkey/object"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Capabilities lists the I/O the file and environment builtins may do. The
+// zero value allows nothing, so scripts are sandboxed unless the host grants
+// them access.
+type Capabilities struct {
+	ReadPaths  []string // files and directories read_file and list_dir may read
+	WritePaths []string // files and directories write_file may write
+	EnvNames   []string // variables getenv may read, or "*" for all of them
+	Stdin      bool     // whether read_line may read standard input
+	Exit       bool     // whether exit may end the process
+}
+
+func init() {
+	builtins["read_file"] = &object.Builtin{Fn: builtinReadFile}
+	builtins["write_file"] = &object.Builtin{Fn: builtinWriteFile}
+	builtins["list_dir"] = &object.Builtin{Fn: builtinListDir}
+	builtins["getenv"] = &object.Builtin{Fn: builtinGetenv}
+	builtins["read_line"] = &object.Builtin{Fn: builtinReadLine}
+	builtins["exit"] = &object.Builtin{Fn: builtinExit}
+}
+
+// pathAllowed reports whether path is one of roots or inside one of them.
+// Symbolic links are resolved first, so a link cannot lead out of a root.
+// An empty root allows nothing, rather than the current directory.
+func pathAllowed(path string, roots []string) bool {
+	resolved, err := resolvePath(path)
+	if err != nil {
+		return false
+	}
+
+	for _, root := range roots {
+		if root == "" {
+			continue
+		}
+		resolvedRoot, err := resolvePath(root)
+		if err != nil {
+			continue
+		}
+
+		rel, err := filepath.Rel(resolvedRoot, resolved)
+		if err != nil {
+			continue
+		}
+		if rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// resolvePath makes path absolute and resolves symbolic links in it. A path
+// that does not exist yet is resolved through its directory, but a link that
+// leads nowhere is refused, since writing to it would create its target.
+func resolvePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	resolved, err := filepath.EvalSymlinks(abs)
+	if err == nil {
+		return resolved, nil
+	}
+	if _, lstatErr := os.Lstat(abs); !os.IsNotExist(lstatErr) {
+		return "", err
+	}
+
+	dir, err := filepath.EvalSymlinks(filepath.Dir(abs))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filepath.Base(abs)), nil
+}
+
+func builtinReadFile(env *object.Environment, args ...object.Object) object.Object {
+	values, err := stringArgs("read_file", args, 1)
+	if err != nil {
+		return err
+	}
+	path := values[0]
+
+	if !pathAllowed(path, contextOf(env).Allowed.ReadPaths) {
+		return newError("read_file: no read access to %s", path)
+	}
+
+	content, readErr := ioutil.ReadFile(path)
+	if readErr != nil {
+		return newError("read_file: %s", readErr)
+	}
+
+	return &object.String{Value: string(content)}
+}
+
+func builtinWriteFile(env *object.Environment, args ...object.Object) object.Object {
+	values, err := stringArgs("write_file", args, 2)
+	if err != nil {
+		return err
+	}
+	path := values[0]
+
+	if !pathAllowed(path, contextOf(env).Allowed.WritePaths) {
+		return newError("write_file: no write access to %s", path)
+	}
+
+	if writeErr := ioutil.WriteFile(path, []byte(values[1]), 0644); writeErr != nil {
+		return newError("write_file: %s", writeErr)
+	}
+
+	return NULL
+}
+
+// builtinListDir returns the names of the entries of a directory, sorted.
+func builtinListDir(env *object.Environment, args ...object.Object) object.Object {
+	values, err := stringArgs("list_dir", args, 1)
+	if err != nil {
+		return err
+	}
+	path := values[0]
+
+	if !pathAllowed(path, contextOf(env).Allowed.ReadPaths) {
+		return newError("list_dir: no read access to %s", path)
+	}
+
+	entries, readErr := ioutil.ReadDir(path)
+	if readErr != nil {
+		return newError("list_dir: %s", readErr)
+	}
+
+	elements := make([]object.Object, len(entries))
+	for i, entry := range entries {
+		elements[i] = &object.String{Value: entry.Name()}
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+// builtinGetenv returns the value of an environment variable, or null if
+// it is not set.
+func builtinGetenv(env *object.Environment, args ...object.Object) object.Object {
+	values, err := stringArgs("getenv", args, 1)
+	if err != nil {
+		return err
+	}
+	name := values[0]
+
+	allowed := false
+	for _, allowedName := range contextOf(env).Allowed.EnvNames {
+		if allowedName == "*" || allowedName == name {
+			allowed = true
+		}
+	}
+	if !allowed {
+		return newError("getenv: no access to environment variable %s", name)
+	}
+
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return NULL
+	}
+
+	return &object.String{Value: value}
+}
+
+// builtinReadLine returns the next line of standard input without its line
+// ending, or null at the end of the input or if the host gave it none.
+func builtinReadLine(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return wrongArgumentCount(len(args), "0")
+	}
+	ctx := contextOf(env)
+	if !ctx.Allowed.Stdin {
+		return newError("read_line: no access to standard input")
+	}
+	if ctx.Stdin == nil {
+		return NULL
+	}
+
+	line, err := ctx.Stdin.ReadString('\n')
+	if err == io.EOF && line == "" {
+		return NULL
+	}
+	if err != nil && err != io.EOF {
+		return newError("read_line: %s", err)
+	}
+
+	line = strings.TrimSuffix(line, "\n")
+	line = strings.TrimSuffix(line, "\r")
+	return &object.String{Value: line}
+}
+
+func builtinExit(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return wrongArgumentCount(len(args), "1")
+	}
+	code, ok := args[0].(*object.Integer)
+	if !ok {
+		return wrongArgumentType("exit", object.INTEGER_OBJ, args[0])
+	}
+	ctx := contextOf(env)
+	if !ctx.Allowed.Exit {
+		return newError("exit: not allowed to end the process")
+	}
+
+	ctx.exit(int(code.Value))
+	return NULL
+}
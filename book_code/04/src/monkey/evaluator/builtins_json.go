@@ -0,0 +1,484 @@
+package evaluator
+
+import (
+	"fmt"
+	"math/big"
+	"monkey/object"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+func init() {
+	builtins["json_parse"] = &object.Builtin{Fn: builtinJSONParse}
+	builtins["json_stringify"] = &object.Builtin{Fn: builtinJSONStringify}
+}
+
+func builtinJSONParse(env *object.Environment, args ...object.Object) object.Object {
+	values, err := stringArgs("json_parse", args, 1)
+	if err != nil {
+		return err
+	}
+
+	p := &jsonParser{input: values[0]}
+	p.skipWhitespace()
+
+	value := p.parseValue()
+	if isError(value) {
+		return value
+	}
+
+	p.skipWhitespace()
+	if p.pos < len(p.input) {
+		return p.unexpected()
+	}
+
+	return value
+}
+
+// jsonParser turns a JSON document into Monkey objects. Objects become
+// hashes with their keys in document order.
+type jsonParser struct {
+	input string
+	pos   int
+}
+
+// errorf reports an error at the current position, counting lines and
+// columns from 1.
+func (p *jsonParser) errorf(format string, a ...interface{}) *object.Error {
+	consumed := p.input[:p.pos]
+	line := strings.Count(consumed, "\n") + 1
+	column := utf8.RuneCountInString(consumed[strings.LastIndexByte(consumed, '\n')+1:]) + 1
+
+	return newError("json_parse: %s at line %d, column %d",
+		fmt.Sprintf(format, a...), line, column)
+}
+
+func (p *jsonParser) unexpected() *object.Error {
+	if p.pos >= len(p.input) {
+		return p.errorf("unexpected end of input")
+	}
+
+	r, _ := utf8.DecodeRuneInString(p.input[p.pos:])
+	return p.errorf("unexpected character %q", r)
+}
+
+func (p *jsonParser) skipWhitespace() {
+	for p.pos < len(p.input) {
+		switch p.input[p.pos] {
+		case ' ', '\t', '\n', '\r':
+			p.pos += 1
+		default:
+			return
+		}
+	}
+}
+
+func (p *jsonParser) peek() byte {
+	if p.pos >= len(p.input) {
+		return 0
+	}
+	return p.input[p.pos]
+}
+
+func (p *jsonParser) parseValue() object.Object {
+	switch ch := p.peek(); {
+	case ch == '{':
+		return p.parseObject()
+	case ch == '[':
+		return p.parseArray()
+	case ch == '"':
+		str, err := p.parseString()
+		if err != nil {
+			return err
+		}
+		return &object.String{Value: str}
+	case ch == '-' || isDigit(ch):
+		return p.parseNumber()
+	case strings.HasPrefix(p.input[p.pos:], "true"):
+		p.pos += len("true")
+		return TRUE
+	case strings.HasPrefix(p.input[p.pos:], "false"):
+		p.pos += len("false")
+		return FALSE
+	case strings.HasPrefix(p.input[p.pos:], "null"):
+		p.pos += len("null")
+		return NULL
+	default:
+		return p.unexpected()
+	}
+}
+
+func (p *jsonParser) parseObject() object.Object {
+	hash := object.NewHash()
+	p.pos += 1 // '{'
+	p.skipWhitespace()
+
+	if p.peek() == '}' {
+		p.pos += 1
+		return hash
+	}
+
+	for {
+		if p.peek() != '"' {
+			return p.errorf("expected string key, got %s", p.describeNext())
+		}
+		key, err := p.parseString()
+		if err != nil {
+			return err
+		}
+
+		p.skipWhitespace()
+		if p.peek() != ':' {
+			return p.errorf("expected ':' after object key, got %s", p.describeNext())
+		}
+		p.pos += 1
+		p.skipWhitespace()
+
+		value := p.parseValue()
+		if isError(value) {
+			return value
+		}
+		hash.Set(&object.String{Value: key}, value)
+
+		p.skipWhitespace()
+		switch p.peek() {
+		case ',':
+			p.pos += 1
+			p.skipWhitespace()
+		case '}':
+			p.pos += 1
+			return hash
+		default:
+			return p.errorf("expected ',' or '}' in object, got %s", p.describeNext())
+		}
+	}
+}
+
+func (p *jsonParser) parseArray() object.Object {
+	elements := []object.Object{}
+	p.pos += 1 // '['
+	p.skipWhitespace()
+
+	if p.peek() == ']' {
+		p.pos += 1
+		return &object.Array{Elements: elements}
+	}
+
+	for {
+		value := p.parseValue()
+		if isError(value) {
+			return value
+		}
+		elements = append(elements, value)
+
+		p.skipWhitespace()
+		switch p.peek() {
+		case ',':
+			p.pos += 1
+			p.skipWhitespace()
+		case ']':
+			p.pos += 1
+			return &object.Array{Elements: elements}
+		default:
+			return p.errorf("expected ',' or ']' in array, got %s", p.describeNext())
+		}
+	}
+}
+
+// describeNext names the next character for error messages.
+func (p *jsonParser) describeNext() string {
+	if p.pos >= len(p.input) {
+		return "end of input"
+	}
+
+	r, _ := utf8.DecodeRuneInString(p.input[p.pos:])
+	return strconv.QuoteRune(r)
+}
+
+func (p *jsonParser) parseString() (string, *object.Error) {
+	var out strings.Builder
+	p.pos += 1 // '"'
+
+	for {
+		if p.pos >= len(p.input) {
+			return "", p.errorf("unterminated string")
+		}
+
+		ch := p.input[p.pos]
+		switch {
+		case ch == '"':
+			p.pos += 1
+			return out.String(), nil
+		case ch < 0x20:
+			return "", p.errorf("control character %q in string", ch)
+		case ch == '\\':
+			if err := p.parseEscape(&out); err != nil {
+				return "", err
+			}
+		default:
+			out.WriteByte(ch)
+			p.pos += 1
+		}
+	}
+}
+
+var jsonEscapes = map[byte]byte{
+	'"':  '"',
+	'\\': '\\',
+	'/':  '/',
+	'b':  '\b',
+	'f':  '\f',
+	'n':  '\n',
+	'r':  '\r',
+	't':  '\t',
+}
+
+func (p *jsonParser) parseEscape(out *strings.Builder) *object.Error {
+	p.pos += 1 // '\'
+	if p.pos >= len(p.input) {
+		return p.errorf("unterminated string")
+	}
+
+	if unescaped, ok := jsonEscapes[p.input[p.pos]]; ok {
+		out.WriteByte(unescaped)
+		p.pos += 1
+		return nil
+	}
+
+	if p.input[p.pos] != 'u' {
+		return p.errorf("invalid escape sequence \\%c", p.input[p.pos])
+	}
+
+	r, err := p.parseHexRune()
+	if err != nil {
+		return err
+	}
+
+	if utf16.IsSurrogate(r) && strings.HasPrefix(p.input[p.pos:], "\\u") {
+		p.pos += 1
+		low, err := p.parseHexRune()
+		if err != nil {
+			return err
+		}
+		r = utf16.DecodeRune(r, low)
+	}
+
+	out.WriteRune(r)
+	return nil
+}
+
+// parseHexRune reads the four hex digits of a \u escape, starting at the u.
+func (p *jsonParser) parseHexRune() (rune, *object.Error) {
+	p.pos += 1 // 'u'
+	if p.pos+4 > len(p.input) {
+		return 0, p.errorf("invalid unicode escape")
+	}
+
+	value, err := strconv.ParseUint(p.input[p.pos:p.pos+4], 16, 32)
+	if err != nil {
+		return 0, p.errorf("invalid unicode escape")
+	}
+
+	p.pos += 4
+	return rune(value), nil
+}
+
+func (p *jsonParser) parseNumber() object.Object {
+	start := p.pos
+
+	if p.peek() == '-' {
+		p.pos += 1
+	}
+	if !isDigit(p.peek()) {
+		return p.unexpected()
+	}
+	if p.peek() == '0' && p.pos+1 < len(p.input) && isDigit(p.input[p.pos+1]) {
+		return p.errorf("leading zero in number")
+	}
+	for isDigit(p.peek()) {
+		p.pos += 1
+	}
+
+	if ch := p.peek(); ch == '.' || ch == 'e' || ch == 'E' {
+		p.pos = start
+		return p.errorf("only integers are supported, got a fractional number")
+	}
+
+	literal := p.input[start:p.pos]
+	if value, err := strconv.ParseInt(literal, 10, 64); err == nil {
+		return &object.Integer{Value: value}
+	}
+
+	value, _ := new(big.Int).SetString(literal, 10)
+	return &object.BigInteger{Value: value}
+}
+
+func isDigit(ch byte) bool {
+	return '0' <= ch && ch <= '9'
+}
+
+func builtinJSONStringify(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return wrongArgumentCount(len(args), "1 to 2")
+	}
+
+	indent := 0
+	if len(args) == 2 {
+		width, ok := args[1].(*object.Integer)
+		if !ok {
+			return wrongArgumentType("json_stringify", object.INTEGER_OBJ, args[1])
+		}
+		if width.Value < 0 {
+			return newError("`json_stringify` indent must not be negative, got %d", width.Value)
+		}
+		indent = int(width.Value)
+	}
+
+	s := &jsonStringifier{indent: strings.Repeat(" ", indent)}
+	if err := s.write(args[0], 0); err != nil {
+		return err
+	}
+
+	return &object.String{Value: s.out.String()}
+}
+
+// jsonStringifier writes Monkey objects as JSON. With an empty indent the
+// output is on one line.
+type jsonStringifier struct {
+	out    strings.Builder
+	indent string
+	sets   map[*object.Set]bool // the sets being written, to refuse cycles
+}
+
+func (s *jsonStringifier) write(value object.Object, depth int) *object.Error {
+	switch value := value.(type) {
+	case *object.Null:
+		s.out.WriteString("null")
+	case *object.Boolean:
+		s.out.WriteString(strconv.FormatBool(value.Value))
+	case *object.Integer, *object.BigInteger:
+		s.out.WriteString(value.Inspect())
+	case *object.String:
+		s.writeString(value.Value)
+	case *object.Array:
+		return s.writeArray(value.Elements, depth)
+	case *object.Set:
+		if s.sets[value] {
+			return newError("json_stringify: SET contains itself")
+		}
+		if s.sets == nil {
+			s.sets = make(map[*object.Set]bool)
+		}
+		s.sets[value] = true
+		defer delete(s.sets, value)
+		return s.writeArray(value.Elements(), depth)
+	case *object.Hash:
+		pairs := value.Pairs()
+		keys := make([]string, len(pairs))
+		values := make([]object.Object, len(pairs))
+		for i, pair := range pairs {
+			key, ok := pair.Key.(*object.String)
+			if !ok {
+				return newError("json_stringify: hash keys must be STRING, got %s",
+					pair.Key.Type())
+			}
+			keys[i] = key.Value
+			values[i] = pair.Value
+		}
+		return s.writeObject(keys, values, depth)
+	case *object.Instance:
+		return s.writeObject(value.Struct.Fields, value.Values, depth)
+	default:
+		return newError("json_stringify: %s cannot be represented in JSON", value.Type())
+	}
+
+	return nil
+}
+
+// newline starts a new line indented to depth, if the output is indented.
+func (s *jsonStringifier) newline(depth int) {
+	if s.indent == "" {
+		return
+	}
+
+	s.out.WriteByte('\n')
+	s.out.WriteString(strings.Repeat(s.indent, depth))
+}
+
+func (s *jsonStringifier) writeArray(elements []object.Object, depth int) *object.Error {
+	if len(elements) == 0 {
+		s.out.WriteString("[]")
+		return nil
+	}
+
+	s.out.WriteByte('[')
+	for i, element := range elements {
+		if i > 0 {
+			s.out.WriteByte(',')
+		}
+		s.newline(depth + 1)
+		if err := s.write(element, depth+1); err != nil {
+			return err
+		}
+	}
+	s.newline(depth)
+	s.out.WriteByte(']')
+
+	return nil
+}
+
+func (s *jsonStringifier) writeObject(keys []string, values []object.Object, depth int) *object.Error {
+	if len(keys) == 0 {
+		s.out.WriteString("{}")
+		return nil
+	}
+
+	s.out.WriteByte('{')
+	for i, key := range keys {
+		if i > 0 {
+			s.out.WriteByte(',')
+		}
+		s.newline(depth + 1)
+		s.writeString(key)
+		s.out.WriteByte(':')
+		if s.indent != "" {
+			s.out.WriteByte(' ')
+		}
+		if err := s.write(values[i], depth+1); err != nil {
+			return err
+		}
+	}
+	s.newline(depth)
+	s.out.WriteByte('}')
+
+	return nil
+}
+
+func (s *jsonStringifier) writeString(value string) {
+	s.out.WriteByte('"')
+
+	for _, r := range value {
+		switch r {
+		case '"':
+			s.out.WriteString(`\"`)
+		case '\\':
+			s.out.WriteString(`\\`)
+		case '\n':
+			s.out.WriteString(`\n`)
+		case '\r':
+			s.out.WriteString(`\r`)
+		case '\t':
+			s.out.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(&s.out, `\u%04x`, r)
+			} else {
+				s.out.WriteRune(r)
+			}
+		}
+	}
+
+	s.out.WriteByte('"')
+}
@@ -0,0 +1,245 @@
+package evaluator
+
+import (
+	"bytes"
+	"monkey/object"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	builtins["upper"] = &object.Builtin{Fn: builtinUpper}
+	builtins["lower"] = &object.Builtin{Fn: builtinLower}
+	builtins["trim"] = &object.Builtin{Fn: builtinTrim}
+	builtins["replace"] = &object.Builtin{Fn: builtinReplace}
+	builtins["starts_with"] = &object.Builtin{Fn: builtinStartsWith}
+	builtins["ends_with"] = &object.Builtin{Fn: builtinEndsWith}
+	builtins["find"] = &object.Builtin{Fn: builtinFind}
+	builtins["repeat"] = &object.Builtin{Fn: builtinRepeat}
+	builtins["chars"] = &object.Builtin{Fn: builtinChars}
+	builtins["format"] = &object.Builtin{Fn: builtinFormat}
+}
+
+// MAX_REPEAT is the longest string in bytes that repeat produces, so that a
+// mistaken count is reported instead of exhausting memory.
+const MAX_REPEAT = 1 << 28
+
+// stringMethods are the builtins that can be called as methods on a string,
+// as in `s.upper()`, with the string passed as their first argument.
+var stringMethods = map[string]bool{
+	"upper":       true,
+	"lower":       true,
+	"trim":        true,
+	"split":       true,
+	"replace":     true,
+	"starts_with": true,
+	"ends_with":   true,
+	"find":        true,
+	"repeat":      true,
+	"chars":       true,
+	"format":      true,
+	"len":         true,
+	"contains":    true,
+	"index_of":    true,
+	"reverse":     true,
+	"slice":       true,
+}
+
+func lookupStringMethod(str *object.String, name string) object.Object {
+	if !stringMethods[name] {
+		return newError("STRING has no method %s", name)
+	}
+
+	return &object.BoundMethod{Receiver: str, Method: builtins[name]}
+}
+
+// stringArgs checks that a builtin got count arguments, all strings.
+func stringArgs(name string, args []object.Object, count int) ([]string, *object.Error) {
+	if len(args) != count {
+		return nil, wrongArgumentCount(len(args), strconv.Itoa(count))
+	}
+
+	values := make([]string, count)
+	for i, arg := range args {
+		str, ok := arg.(*object.String)
+		if !ok {
+			return nil, wrongArgumentType(name, object.STRING_OBJ, arg)
+		}
+		values[i] = str.Value
+	}
+
+	return values, nil
+}
+
+func builtinUpper(env *object.Environment, args ...object.Object) object.Object {
+	values, err := stringArgs("upper", args, 1)
+	if err != nil {
+		return err
+	}
+	return &object.String{Value: strings.ToUpper(values[0])}
+}
+
+func builtinLower(env *object.Environment, args ...object.Object) object.Object {
+	values, err := stringArgs("lower", args, 1)
+	if err != nil {
+		return err
+	}
+	return &object.String{Value: strings.ToLower(values[0])}
+}
+
+// builtinTrim strips surrounding whitespace, or the characters of its
+// second argument when given.
+func builtinTrim(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) == 2 {
+		values, err := stringArgs("trim", args, 2)
+		if err != nil {
+			return err
+		}
+		return &object.String{Value: strings.Trim(values[0], values[1])}
+	}
+
+	values, err := stringArgs("trim", args, 1)
+	if err != nil {
+		return err
+	}
+	return &object.String{Value: strings.TrimSpace(values[0])}
+}
+
+func builtinReplace(env *object.Environment, args ...object.Object) object.Object {
+	values, err := stringArgs("replace", args, 3)
+	if err != nil {
+		return err
+	}
+	return &object.String{Value: strings.ReplaceAll(values[0], values[1], values[2])}
+}
+
+func builtinStartsWith(env *object.Environment, args ...object.Object) object.Object {
+	values, err := stringArgs("starts_with", args, 2)
+	if err != nil {
+		return err
+	}
+	return nativeBoolToBooleanObject(strings.HasPrefix(values[0], values[1]))
+}
+
+func builtinEndsWith(env *object.Environment, args ...object.Object) object.Object {
+	values, err := stringArgs("ends_with", args, 2)
+	if err != nil {
+		return err
+	}
+	return nativeBoolToBooleanObject(strings.HasSuffix(values[0], values[1]))
+}
+
+// builtinFind returns the character position of the first occurrence of a
+// substring, or -1 if there is none.
+func builtinFind(env *object.Environment, args ...object.Object) object.Object {
+	values, err := stringArgs("find", args, 2)
+	if err != nil {
+		return err
+	}
+
+	idx := strings.Index(values[0], values[1])
+	if idx < 0 {
+		return &object.Integer{Value: -1}
+	}
+	return &object.Integer{Value: int64(len([]rune(values[0][:idx])))}
+}
+
+func builtinRepeat(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return wrongArgumentCount(len(args), "2")
+	}
+	str, ok := args[0].(*object.String)
+	if !ok {
+		return wrongArgumentType("repeat", object.STRING_OBJ, args[0])
+	}
+	count, ok := args[1].(*object.Integer)
+	if !ok {
+		return wrongArgumentType("repeat", object.INTEGER_OBJ, args[1])
+	}
+	if count.Value < 0 {
+		return newError("`repeat` count must not be negative, got %d", count.Value)
+	}
+	// dividing rather than multiplying keeps the check from overflowing
+	if len(str.Value) > 0 && count.Value > MAX_REPEAT/int64(len(str.Value)) {
+		return newError("`repeat` result would be longer than %d bytes", MAX_REPEAT)
+	}
+
+	return &object.String{Value: strings.Repeat(str.Value, int(count.Value))}
+}
+
+func builtinChars(env *object.Environment, args ...object.Object) object.Object {
+	values, err := stringArgs("chars", args, 1)
+	if err != nil {
+		return err
+	}
+
+	elements := []object.Object{}
+	for _, r := range values[0] {
+		elements = append(elements, &object.String{Value: string(r)})
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+// builtinFormat replaces each `{}` in the template with the next argument
+// and each `{N}` with the Nth one. `{{` and `}}` produce literal braces.
+func builtinFormat(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) < 1 {
+		return wrongArgumentCount(len(args), "at least 1")
+	}
+	template, ok := args[0].(*object.String)
+	if !ok {
+		return wrongArgumentType("format", object.STRING_OBJ, args[0])
+	}
+	values := args[1:]
+
+	var out bytes.Buffer
+	next := 0
+	input := template.Value
+
+	for i := 0; i < len(input); i++ {
+		ch := input[i]
+
+		if (ch == '{' || ch == '}') && i+1 < len(input) && input[i+1] == ch {
+			out.WriteByte(ch)
+			i += 1
+			continue
+		}
+
+		if ch != '{' {
+			out.WriteByte(ch)
+			continue
+		}
+
+		end := strings.IndexByte(input[i:], '}')
+		if end < 0 {
+			return newError("unterminated placeholder in format string %q", input)
+		}
+		placeholder := input[i+1 : i+end]
+		i += end
+
+		idx := next
+		if placeholder == "" {
+			next += 1
+		} else {
+			n, err := strconv.Atoi(placeholder)
+			if err != nil {
+				return newError("invalid placeholder {%s} in format string", placeholder)
+			}
+			idx = n
+		}
+
+		if idx < 0 || idx >= len(values) {
+			return newError("format string refers to argument %d, but got %d arguments",
+				idx, len(values))
+		}
+
+		if str, ok := values[idx].(*object.String); ok {
+			out.WriteString(str.Value)
+		} else {
+			out.WriteString(values[idx].Inspect())
+		}
+	}
+
+	return &object.String{Value: out.String()}
+}
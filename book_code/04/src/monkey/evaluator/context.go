@@ -0,0 +1,80 @@
+package evaluator
+
+import (
+	"bufio"
+	"io"
+	"monkey/object"
+	"os"
+)
+
+// Context holds the settings of one evaluation. It is carried by the
+// environment the evaluation starts in and shared with the environments
+// enclosed in it, so programs evaluated side by side can each have their
+// own. The zero value is the default for every setting.
+type Context struct {
+	// StrictIndexing makes indexing an array or string out of range an
+	// error that reports where it happened, instead of evaluating to null.
+	StrictIndexing bool
+
+	// CheckedArithmetic makes integer overflow an error. Otherwise results
+	// that overflow int64 are promoted to arbitrary-precision integers.
+	CheckedArithmetic bool
+
+	// Allowed is the I/O the file and environment builtins may do.
+	Allowed Capabilities
+
+	// Stdin is where read_line reads from, Stdout is where puts writes, and
+	// Exit is what exit calls. Without a Stdin there is no input, a nil
+	// Stdout is os.Stdout and a nil Exit is os.Exit. Stdin is buffered so
+	// that what one read_line reads ahead is there for the next.
+	Stdin  *bufio.Reader
+	Stdout io.Writer
+	Exit   func(code int)
+
+	// Interrupt stops the evaluation once it is closed: the next statement
+	// evaluates to an error instead of running.
+	Interrupt <-chan struct{}
+
+	// Tracer is the Hook told about the evaluation, or nil for none.
+	Tracer Hook
+}
+
+// defaultContext is used for environments that were not given one.
+var defaultContext = &Context{}
+
+// NewEnvironment returns an environment for evaluating with ctx.
+func NewEnvironment(ctx *Context) *object.Environment {
+	env := object.NewEnvironment()
+	env.SetContext(ctx)
+	return env
+}
+
+func (c *Context) stdout() io.Writer {
+	if c.Stdout == nil {
+		return os.Stdout
+	}
+	return c.Stdout
+}
+
+func (c *Context) exit(code int) {
+	if c.Exit == nil {
+		os.Exit(code)
+	}
+	c.Exit(code)
+}
+
+func (c *Context) interrupted() bool {
+	select {
+	case <-c.Interrupt:
+		return true
+	default:
+		return false
+	}
+}
+
+func contextOf(env *object.Environment) *Context {
+	if ctx, ok := env.Context().(*Context); ok && ctx != nil {
+		return ctx
+	}
+	return defaultContext
+}
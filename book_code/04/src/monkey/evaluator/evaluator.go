@@ -1,9 +1,13 @@
 package evaluator
 
 import (
+	"bytes"
 	"fmt"
+	"math"
+	"math/big"
 	"monkey/ast"
 	"monkey/object"
+	"unicode/utf8"
 )
 
 var (
@@ -25,6 +29,9 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.ExpressionStatement:
 		return Eval(node.Expression, env)
 
+	case *ast.StructStatement:
+		return evalStructStatement(node, env)
+
 	case *ast.ReturnStatement:
 		val := Eval(node.ReturnValue, env)
 		if isError(val) {
@@ -37,24 +44,39 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(val) {
 			return val
 		}
+		if node.Pattern != nil {
+			return evalDestructuring(node.Pattern, val, env)
+		}
+		if _, ok := node.Value.(*ast.FunctionLiteral); ok {
+			val.(*object.Function).Name = node.Name.Value
+		}
 		env.Set(node.Name.Value, val)
 
 	// Expressions
 	case *ast.IntegerLiteral:
+		if node.Big != nil {
+			return &object.BigInteger{Value: node.Big}
+		}
 		return &object.Integer{Value: node.Value}
 
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
 
+	case *ast.TemplateLiteral:
+		return evalTemplateLiteral(node, env)
+
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
 
+	case *ast.Null:
+		return NULL
+
 	case *ast.PrefixExpression:
 		right := Eval(node.Right, env)
 		if isError(right) {
 			return right
 		}
-		return evalPrefixExpression(node.Operator, right)
+		return evalPrefixExpression(node.Operator, right, env)
 
 	case *ast.InfixExpression:
 		left := Eval(node.Left, env)
@@ -62,12 +84,16 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return left
 		}
 
+		if isLogicalOperator(node.Operator) {
+			return evalLogicalExpression(node.Operator, left, node.Right, env)
+		}
+
 		right := Eval(node.Right, env)
 		if isError(right) {
 			return right
 		}
 
-		return evalInfixExpression(node.Operator, left, right)
+		return evalInfixExpression(node.Operator, left, right, env)
 
 	case *ast.IfExpression:
 		return evalIfExpression(node, env)
@@ -78,7 +104,17 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		return &object.Function{
+			Parameters: params,
+			Defaults:   node.Defaults,
+			Rest:       node.Rest,
+			Env:        env,
+			Body:       body,
+		}
+
+	case *ast.SpreadExpression:
+		return newError("spread is only allowed in calls and array literals: %s",
+			node.String())
 
 	case *ast.CallExpression:
 		function := Eval(node.Function, env)
@@ -91,7 +127,7 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return args[0]
 		}
 
-		return applyFunction(function, args)
+		return applyFunction(function, args, env)
 
 	case *ast.ArrayLiteral:
 		elements := evalExpressions(node.Elements, env)
@@ -109,11 +145,23 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(index) {
 			return index
 		}
-		return evalIndexExpression(left, index)
+		return evalIndexExpression(node, left, index, env)
+
+	case *ast.SliceExpression:
+		return evalSliceExpression(node, env)
 
 	case *ast.HashLiteral:
 		return evalHashLiteral(node, env)
 
+	case *ast.SetLiteral:
+		return evalSetLiteral(node, env)
+
+	case *ast.MatchExpression:
+		return evalMatchExpression(node, env)
+
+	case *ast.MemberExpression:
+		return evalMemberExpression(node, env)
+
 	}
 
 	return nil
@@ -122,7 +170,14 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	var result object.Object
 
+	ctx := contextOf(env)
 	for _, statement := range program.Statements {
+		if ctx.interrupted() {
+			return newError("interrupted")
+		}
+		if ctx.Tracer != nil {
+			ctx.Tracer.Statement(statement, env)
+		}
 		result = Eval(statement, env)
 
 		switch result := result.(type) {
@@ -142,7 +197,14 @@ func evalBlockStatement(
 ) object.Object {
 	var result object.Object
 
+	ctx := contextOf(env)
 	for _, statement := range block.Statements {
+		if ctx.interrupted() {
+			return newError("interrupted")
+		}
+		if ctx.Tracer != nil {
+			ctx.Tracer.Statement(statement, env)
+		}
 		result = Eval(statement, env)
 
 		if result != nil {
@@ -163,12 +225,16 @@ func nativeBoolToBooleanObject(input bool) *object.Boolean {
 	return FALSE
 }
 
-func evalPrefixExpression(operator string, right object.Object) object.Object {
+func evalPrefixExpression(
+	operator string,
+	right object.Object,
+	env *object.Environment,
+) object.Object {
 	switch operator {
 	case "!":
 		return evalBangOperatorExpression(right)
 	case "-":
-		return evalMinusPrefixOperatorExpression(right)
+		return evalMinusPrefixOperatorExpression(right, env)
 	default:
 		return newError("unknown operator: %s%s", operator, right.Type())
 	}
@@ -177,16 +243,21 @@ func evalPrefixExpression(operator string, right object.Object) object.Object {
 func evalInfixExpression(
 	operator string,
 	left, right object.Object,
+	env *object.Environment,
 ) object.Object {
 	switch {
+	case operator == "in":
+		return evalInExpression(left, right)
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
-		return evalIntegerInfixExpression(operator, left, right)
+		return evalIntegerInfixExpression(operator, left, right, env)
+	case isInteger(left) && isInteger(right):
+		return evalBigIntegerInfixExpression(operator, left, right)
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right)
 	case operator == "==":
-		return nativeBoolToBooleanObject(left == right)
+		return nativeBoolToBooleanObject(object.Equal(left, right))
 	case operator == "!=":
-		return nativeBoolToBooleanObject(left != right)
+		return nativeBoolToBooleanObject(!object.Equal(left, right))
 	case left.Type() != right.Type():
 		return newError("type mismatch: %s %s %s",
 			left.Type(), operator, right.Type())
@@ -196,6 +267,36 @@ func evalInfixExpression(
 	}
 }
 
+func isLogicalOperator(operator string) bool {
+	return operator == "&&" || operator == "||" || operator == "??"
+}
+
+// evalLogicalExpression only evaluates the right operand when the left one
+// does not already decide the result, and returns whichever operand did.
+func evalLogicalExpression(
+	operator string,
+	left object.Object,
+	rightNode ast.Expression,
+	env *object.Environment,
+) object.Object {
+	switch operator {
+	case "&&":
+		if !isTruthy(left) {
+			return left
+		}
+	case "||":
+		if isTruthy(left) {
+			return left
+		}
+	case "??":
+		if left != NULL {
+			return left
+		}
+	}
+
+	return Eval(rightNode, env)
+}
+
 func evalBangOperatorExpression(right object.Object) object.Object {
 	switch right {
 	case TRUE:
@@ -209,30 +310,57 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 	}
 }
 
-func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJ {
+func evalMinusPrefixOperatorExpression(
+	right object.Object,
+	env *object.Environment,
+) object.Object {
+	switch right := right.(type) {
+	case *object.Integer:
+		if right.Value == math.MinInt64 {
+			if contextOf(env).CheckedArithmetic {
+				return newError("integer overflow: -(%d)", right.Value)
+			}
+			return normalizeInteger(new(big.Int).Neg(big.NewInt(right.Value)))
+		}
+		return &object.Integer{Value: -right.Value}
+	case *object.BigInteger:
+		return normalizeInteger(new(big.Int).Neg(right.Value))
+	default:
 		return newError("unknown operator: -%s", right.Type())
 	}
-
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
 }
 
 func evalIntegerInfixExpression(
 	operator string,
 	left, right object.Object,
+	env *object.Environment,
 ) object.Object {
 	leftVal := left.(*object.Integer).Value
 	rightVal := right.(*object.Integer).Value
 
 	switch operator {
 	case "+":
-		return &object.Integer{Value: leftVal + rightVal}
+		if sum, ok := addInt64(leftVal, rightVal); ok {
+			return &object.Integer{Value: sum}
+		}
+		return integerOverflow(operator, left, right, env)
 	case "-":
-		return &object.Integer{Value: leftVal - rightVal}
+		if difference, ok := subInt64(leftVal, rightVal); ok {
+			return &object.Integer{Value: difference}
+		}
+		return integerOverflow(operator, left, right, env)
 	case "*":
-		return &object.Integer{Value: leftVal * rightVal}
+		if product, ok := mulInt64(leftVal, rightVal); ok {
+			return &object.Integer{Value: product}
+		}
+		return integerOverflow(operator, left, right, env)
 	case "/":
+		if rightVal == 0 {
+			return newError("division by zero: %d / 0", leftVal)
+		}
+		if leftVal == math.MinInt64 && rightVal == -1 {
+			return integerOverflow(operator, left, right, env)
+		}
 		return &object.Integer{Value: leftVal / rightVal}
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
@@ -262,6 +390,32 @@ func evalStringInfixExpression(
 	return &object.String{Value: leftVal + rightVal}
 }
 
+func evalTemplateLiteral(
+	tl *ast.TemplateLiteral,
+	env *object.Environment,
+) object.Object {
+	var out bytes.Buffer
+
+	for _, part := range tl.Parts {
+		value := Eval(part, env)
+		if isError(value) {
+			return value
+		}
+		if value == nil {
+			// an empty block or function body has no value
+			value = NULL
+		}
+
+		if str, ok := value.(*object.String); ok {
+			out.WriteString(str.Value)
+		} else {
+			out.WriteString(value.Inspect())
+		}
+	}
+
+	return &object.String{Value: out.String()}
+}
+
 func evalIfExpression(
 	ie *ast.IfExpression,
 	env *object.Environment,
@@ -326,43 +480,119 @@ func evalExpressions(
 	var result []object.Object
 
 	for _, e := range exps {
+		spread, isSpread := e.(*ast.SpreadExpression)
+		if isSpread {
+			e = spread.Value
+		}
+
 		evaluated := Eval(e, env)
 		if isError(evaluated) {
 			return []object.Object{evaluated}
 		}
-		result = append(result, evaluated)
+
+		if !isSpread {
+			result = append(result, evaluated)
+			continue
+		}
+
+		switch spreadable := evaluated.(type) {
+		case *object.Array:
+			result = append(result, spreadable.Elements...)
+		case *object.Set:
+			result = append(result, spreadable.Elements()...)
+		default:
+			return []object.Object{newError("cannot spread %s", evaluated.Type())}
+		}
 	}
 
 	return result
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+// applyFunction calls fn with args from env, the environment of the call.
+func applyFunction(fn object.Object, args []object.Object, env *object.Environment) object.Object {
 	switch fn := fn.(type) {
 
 	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
-		return unwrapReturnValue(evaluated)
+		extendedEnv, err := extendFunctionEnv(fn, args)
+		if err != nil {
+			return err
+		}
+		return callFunction(fn, extendedEnv)
 
 	case *object.Builtin:
-		return fn.Fn(args...)
+		return fn.Fn(env, args...)
+
+	case *object.Struct:
+		return instantiateStruct(fn, args)
+
+	case *object.BoundMethod:
+		return applyBoundMethod(fn, args, env)
 
 	default:
 		return newError("not a function: %s", fn.Type())
 	}
 }
 
+// callFunction evaluates the body of fn in env, which binds its arguments.
+func callFunction(fn *object.Function, env *object.Environment) object.Object {
+	tracer := contextOf(env).Tracer
+	if tracer != nil {
+		tracer.Call(fn, env)
+	}
+	result := unwrapReturnValue(Eval(fn.Body, env))
+	if tracer != nil {
+		tracer.Return(fn, result)
+	}
+	return result
+}
+
 func extendFunctionEnv(
 	fn *object.Function,
 	args []object.Object,
-) *object.Environment {
+) (*object.Environment, *object.Error) {
+	required := len(fn.Parameters) - len(fn.Defaults)
+	if len(args) < required || (fn.Rest == nil && len(args) > len(fn.Parameters)) {
+		return nil, newError("wrong number of arguments. got=%d, want=%s",
+			len(args), describeArity(fn, required))
+	}
+
 	env := object.NewEnclosedEnvironment(fn.Env)
 
 	for paramIdx, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIdx])
+		if paramIdx < len(args) {
+			env.Set(param.Value, args[paramIdx])
+			continue
+		}
+
+		// Defaults are evaluated in the call's environment so they can
+		// refer to the parameters before them.
+		val := Eval(fn.Defaults[param.Value], env)
+		if err, ok := val.(*object.Error); ok {
+			return nil, err
+		}
+		env.Set(param.Value, val)
 	}
 
-	return env
+	if fn.Rest != nil {
+		rest := []object.Object{}
+		if len(args) > len(fn.Parameters) {
+			rest = append(rest, args[len(fn.Parameters):]...)
+		}
+		env.Set(fn.Rest.Value, &object.Array{Elements: rest})
+	}
+
+	return env, nil
+}
+
+func describeArity(fn *object.Function, required int) string {
+	switch {
+	case fn.Rest != nil:
+		return fmt.Sprintf("at least %d", required)
+	case required != len(fn.Parameters):
+		return fmt.Sprintf("%d to %d", required, len(fn.Parameters))
+	default:
+		return fmt.Sprintf("%d", required)
+	}
 }
 
 func unwrapReturnValue(obj object.Object) object.Object {
@@ -373,10 +603,16 @@ func unwrapReturnValue(obj object.Object) object.Object {
 	return obj
 }
 
-func evalIndexExpression(left, index object.Object) object.Object {
+func evalIndexExpression(
+	node *ast.IndexExpression,
+	left, index object.Object,
+	env *object.Environment,
+) object.Object {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
-		return evalArrayIndexExpression(left, index)
+		return evalArrayIndexExpression(node, left, index, env)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalStringIndexExpression(node, left, index, env)
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
 	default:
@@ -384,56 +620,190 @@ func evalIndexExpression(left, index object.Object) object.Object {
 	}
 }
 
-func evalArrayIndexExpression(array, index object.Object) object.Object {
+func evalArrayIndexExpression(
+	node *ast.IndexExpression,
+	array, index object.Object,
+	env *object.Environment,
+) object.Object {
 	arrayObject := array.(*object.Array)
 	idx := index.(*object.Integer).Value
-	max := int64(len(arrayObject.Elements) - 1)
+	length := int64(len(arrayObject.Elements))
+
+	position, ok := resolveIndex(idx, length)
+	if !ok {
+		return indexOutOfRange(node, idx, length, env)
+	}
+
+	return arrayObject.Elements[position]
+}
+
+// evalStringIndexExpression indexes by character rather than by byte, and
+// returns the character as a one-character string.
+func evalStringIndexExpression(
+	node *ast.IndexExpression,
+	str, index object.Object,
+	env *object.Environment,
+) object.Object {
+	runes := []rune(str.(*object.String).Value)
+	idx := index.(*object.Integer).Value
+	length := int64(len(runes))
+
+	position, ok := resolveIndex(idx, length)
+	if !ok {
+		return indexOutOfRange(node, idx, length, env)
+	}
+
+	return &object.String{Value: string(runes[position])}
+}
+
+// resolveIndex turns a negative index into one counted from the end and
+// reports whether the result is in range.
+func resolveIndex(idx, length int64) (int64, bool) {
+	if idx < 0 {
+		idx += length
+	}
+	return idx, idx >= 0 && idx < length
+}
 
-	if idx < 0 || idx > max {
+func indexOutOfRange(
+	node *ast.IndexExpression,
+	idx, length int64,
+	env *object.Environment,
+) object.Object {
+	if !contextOf(env).StrictIndexing {
 		return NULL
 	}
 
-	return arrayObject.Elements[idx]
+	return newError("index out of range: %d with length %d at line %d, column %d",
+		idx, length, node.Token.Line, node.Token.Column)
+}
+
+func evalSliceExpression(
+	node *ast.SliceExpression,
+	env *object.Environment,
+) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	bounds := []*int64{nil, nil}
+	for i, boundNode := range []ast.Expression{node.Start, node.End} {
+		if boundNode == nil {
+			continue
+		}
+
+		bound := Eval(boundNode, env)
+		if isError(bound) {
+			return bound
+		}
+
+		integer, ok := bound.(*object.Integer)
+		if !ok {
+			return newError("slice bound must be INTEGER, got %s", bound.Type())
+		}
+		bounds[i] = &integer.Value
+	}
+
+	return sliceObject(left, bounds[0], bounds[1])
+}
+
+// sliceObject copies the elements or characters from start up to, but not
+// including, end. Missing bounds default to the ends of the value, negative
+// ones count back from its end, and both are clamped to its length.
+func sliceObject(left object.Object, start, end *int64) object.Object {
+	var length int64
+	switch left := left.(type) {
+	case *object.Array:
+		length = int64(len(left.Elements))
+	case *object.String:
+		length = int64(utf8.RuneCountInString(left.Value))
+	default:
+		return newError("slice operator not supported: %s", left.Type())
+	}
+
+	from, to := int64(0), length
+	if start != nil {
+		from = clampSliceBound(*start, length)
+	}
+	if end != nil {
+		to = clampSliceBound(*end, length)
+	}
+	if to < from {
+		to = from
+	}
+
+	switch left := left.(type) {
+	case *object.Array:
+		elements := make([]object.Object, to-from)
+		copy(elements, left.Elements[from:to])
+		return &object.Array{Elements: elements}
+	default:
+		runes := []rune(left.(*object.String).Value)
+		return &object.String{Value: string(runes[from:to])}
+	}
+}
+
+func clampSliceBound(bound, length int64) int64 {
+	if bound < 0 {
+		bound += length
+	}
+	if bound < 0 {
+		return 0
+	}
+	if bound > length {
+		return length
+	}
+	return bound
 }
 
 func evalHashLiteral(
 	node *ast.HashLiteral,
 	env *object.Environment,
 ) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
+	hash := object.NewHash()
 
-	for keyNode, valueNode := range node.Pairs {
+	for _, keyNode := range node.Keys {
 		key := Eval(keyNode, env)
 		if isError(key) {
 			return key
 		}
 
-		hashKey, ok := key.(object.Hashable)
+		hashKey, ok := object.AsHashable(key)
 		if !ok {
-			return newError("unusable as hash key: %s", key.Type())
+			return unusableHashKey(key)
 		}
 
-		value := Eval(valueNode, env)
+		value := Eval(node.Pairs[keyNode], env)
 		if isError(value) {
 			return value
 		}
 
-		hashed := hashKey.HashKey()
-		pairs[hashed] = object.HashPair{Key: key, Value: value}
+		hash.Set(hashKey, value)
 	}
 
-	return &object.Hash{Pairs: pairs}
+	return hash
+}
+
+func unusableHashKey(key object.Object) *object.Error {
+	if hash, ok := key.(*object.Hash); ok && !hash.Frozen {
+		return newError("unusable as hash key: HASH (use `freeze` first)")
+	}
+	if _, ok := key.(object.Hashable); ok {
+		return newError("unusable as hash key: %s (it holds a hash that is not frozen)", key.Type())
+	}
+	return newError("unusable as hash key: %s", key.Type())
 }
 
 func evalHashIndexExpression(hash, index object.Object) object.Object {
 	hashObject := hash.(*object.Hash)
 
-	key, ok := index.(object.Hashable)
+	key, ok := object.AsHashable(index)
 	if !ok {
-		return newError("unusable as hash key: %s", index.Type())
+		return unusableHashKey(index)
 	}
 
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	pair, ok := hashObject.Get(key)
 	if !ok {
 		return NULL
 	}
@@ -1,9 +1,17 @@
 package evaluator
 
 import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"monkey/ast"
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -35,6 +43,100 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestBigIntegerArithmetic(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775807 + 1", "9223372036854775808"},
+		{"-9223372036854775807 - 2", "-9223372036854775809"},
+		{"4611686018427387904 * 4", "18446744073709551616"},
+		{"-(-9223372036854775807 - 1)", "9223372036854775808"},
+		{"(-9223372036854775807 - 1) / -1", "9223372036854775808"},
+		{"99999999999999999999", "99999999999999999999"},
+		{"99999999999999999999 * 99999999999999999999", "9999999999999999999800000000000000000001"},
+		{"-99999999999999999999", "-99999999999999999999"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		bigInt, ok := evaluated.(*object.BigInteger)
+		if !ok {
+			t.Errorf("%s: object is not BigInteger. got=%T (%+v)",
+				tt.input, evaluated, evaluated)
+			continue
+		}
+
+		if bigInt.Value.String() != tt.expected {
+			t.Errorf("%s: wrong value. expected=%s, got=%s",
+				tt.input, tt.expected, bigInt.Value.String())
+		}
+	}
+
+	integerTests := []struct {
+		input    string
+		expected int64
+	}{
+		{"99999999999999999999 - 99999999999999999998", 1},
+		{"(9223372036854775807 + 1) - 1", 9223372036854775807},
+		{"99999999999999999999 / 99999999999999999999", 1},
+		{"let h = {99999999999999999999: 5}; h[99999999999999999998 + 1]", 5},
+	}
+
+	for _, tt := range integerTests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+
+	booleanTests := []struct {
+		input    string
+		expected bool
+	}{
+		{"99999999999999999999 == 99999999999999999999", true},
+		{"99999999999999999999 != 99999999999999999998", true},
+		{"99999999999999999999 > 1", true},
+		{"-99999999999999999999 < 1", true},
+		{"sort([99999999999999999999, 1, -99999999999999999999])[0] < 0", true},
+		{"match (99999999999999999999) { 99999999999999999999 => true, _ => false }", true},
+	}
+
+	for _, tt := range booleanTests {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestArithmeticErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		checked         bool
+		expectedMessage string
+	}{
+		{"5 / 0", false, "division by zero: 5 / 0"},
+		{"99999999999999999999 / 0", false, "division by zero: 99999999999999999999 / 0"},
+		{"9223372036854775807 + 1", true, "integer overflow: 9223372036854775807 + 1"},
+		{"-9223372036854775807 - 2", true, "integer overflow: -9223372036854775807 - 2"},
+		{"4611686018427387904 * 2", true, "integer overflow: 4611686018427387904 * 2"},
+		{"(-9223372036854775807 - 1) / -1", true, "integer overflow: -9223372036854775808 / -1"},
+		{"-(-9223372036854775807 - 1)", true, "integer overflow: -(-9223372036854775808)"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWith(tt.input, &Context{CheckedArithmetic: tt.checked})
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%s: no error object returned. got=%T(%+v)",
+				tt.input, evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q",
+				tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
 func TestEvalBooleanExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -86,6 +188,81 @@ func TestBangOperator(t *testing.T) {
 	}
 }
 
+func TestLogicalOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"true && true", true},
+		{"true && false", false},
+		{"false && true", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 && 2", 2},
+		{"null && 2", nil},
+		{"0 || 5", 0},
+		{"null || 5", 5},
+		{"false || null", nil},
+		{"null ?? 5", 5},
+		{"false ?? 5", false},
+		{"3 ?? 5", 3},
+		{"null ?? null", nil},
+		{"null", nil},
+		{"if (null) { 1 } else { 2 }", 2},
+		{"if (1 > 2) { 1 } == null", true},
+		{"false && missing", false},
+		{"true || missing", true},
+		{"1 ?? missing", 1},
+		{"let f = fn() { 1 / 0 }; false && f()", false},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestStructuralEquality(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"[1, 2] == [1, 2]", true},
+		{"[1, 2] != [1, 2]", false},
+		{"[1, 2] == [2, 1]", false},
+		{"[1, [2, [3]]] == [1, [2, [3]]]", true},
+		{"[1, [2, [3]]] == [1, [2, [4]]]", false},
+		{"[] == []", true},
+		{"[1] == [1, 2]", false},
+		{`{"a": 1, "b": [2]} == {"b": [2], "a": 1}`, true},
+		{`{"a": 1} == {"a": 2}`, false},
+		{`{"a": 1} == {"b": 1}`, false},
+		{`{"a": 1} != {"a": 1, "b": 2}`, true},
+		{`{[1]: {"x": null}} == {[1]: {"x": null}}`, true},
+		{"[1, 2] == 1", false},
+		{`[1, "a"] == [1, "b"]`, false},
+		{"let f = fn() {}; [f] == [f]", true},
+		{"[fn() {}] == [fn() {}]", false},
+		{"[99999999999999999999] == [99999999999999999999]", true},
+		{"let a = [1, 2]; same(a, a)", true},
+		{"same([1, 2], [1, 2])", false},
+		{"same(true, true)", true},
+		{"same(null, null)", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
 func TestIfElseExpressions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -221,6 +398,18 @@ if (10 > 1) {
 			`999[1]`,
 			"index operator not supported: INTEGER",
 		},
+		{
+			`"value: ${missing}"`,
+			"identifier not found: missing",
+		},
+		{
+			"true && missing",
+			"identifier not found: missing",
+		},
+		{
+			"null ?? (1 + true)",
+			"type mismatch: INTEGER + BOOLEAN",
+		},
 	}
 
 	for _, tt := range tests {
@@ -299,6 +488,81 @@ func TestFunctionApplication(t *testing.T) {
 	}
 }
 
+func TestFunctionDefaultAndRestParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let f = fn(x, y = 10) { x + y }; f(1);", 11},
+		{"let f = fn(x, y = 10) { x + y }; f(1, 2);", 3},
+		{"let f = fn(x, y = x * 2) { x + y }; f(3);", 9},
+		{"let f = fn(...args) { len(args) }; f();", 0},
+		{"let f = fn(...args) { len(args) }; f(1, 2, 3);", 3},
+		{"let f = fn(x, ...rest) { x + len(rest) }; f(10, 1, 1);", 12},
+		{"let f = fn(x, y = 5, ...rest) { x + y + len(rest) }; f(1);", 6},
+		{"let add = fn(a, b, c) { a + b + c }; add(...[1, 2, 3]);", 6},
+		{"let add = fn(a, b, c) { a + b + c }; add(1, ...[2], ...[3]);", 6},
+		{"let f = fn(...xs) { len(xs) }; f(...[1, 2], 3, ...[]);", 3},
+		{"len([0, ...[1, 2], 3])", 4},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestDestructuringLetStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let [a, b] = [1, 2]; a * 10 + b;", 12},
+		{"let [a, ...rest] = [1, 2, 3]; a + len(rest);", 3},
+		{"let [a, b, ...rest] = [1, 2]; a + b + len(rest);", 3},
+		{"let [_, [x, y]] = [0, [4, 5]]; x * y;", 20},
+		{`let {"x": x} = {"x": 7, "y": 8}; x;`, 7},
+		{`let {"p": [a, b]} = {"p": [2, 3]}; a * b;`, 6},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestArityAndDestructuringErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"fn(x, y) { x }(1)", "wrong number of arguments. got=1, want=2"},
+		{"fn(x) { x }(1, 2)", "wrong number of arguments. got=2, want=1"},
+		{"fn(x, y = 1) { x }()", "wrong number of arguments. got=0, want=1 to 2"},
+		{"fn(x, ...r) { x }()", "wrong number of arguments. got=0, want=at least 1"},
+		{"fn(x, y = z) { x }(1)", "identifier not found: z"},
+		{"fn(x) { x }(...5)", "cannot spread INTEGER"},
+		{"let x = ...[1];", "spread is only allowed in calls and array literals: ...[1]"},
+		{"let [a, b] = [1];", "cannot destructure [1] with pattern [a, b]"},
+		{`let {"x": x} = {"y": 1};`, "cannot destructure {y: 1} with pattern {x: x}"},
+		{"let [a] = 5;", "cannot destructure 5 with pattern [a]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%s: no error object returned. got=%T(%+v)",
+				tt.input, evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q",
+				tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
 func TestEnclosingEnvironments(t *testing.T) {
 	input := `
 let first = 10;
@@ -356,6 +620,34 @@ func TestStringConcatenation(t *testing.T) {
 	}
 }
 
+func TestStringInterpolation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let name = "Monkey"; "Hello ${name}!"`, "Hello Monkey!"},
+		{`let age = 5; "n=${age}, next=${age + 1}"`, "n=5, next=6"},
+		{`"${[1, "two", true]} ${if (false) { 1 }}"`, "[1, two, true] null"},
+		{`"nested ${"${1}" + "2"}"`, "nested 12"},
+		{`"empty ${fn() {}()} ${if (true) {}}"`, "empty null null"},
+		{`"literal \${x}"`, "literal ${x}"},
+		{"`raw ${x}\\n`", "raw ${x}\\n"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+		}
+
+		if str.Value != tt.expected {
+			t.Errorf("String has wrong value. expected=%q, got=%q",
+				tt.expected, str.Value)
+		}
+	}
+}
+
 func TestBuiltinFunctions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -364,6 +656,8 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`len("")`, 0},
 		{`len("four")`, 4},
 		{`len("hello world")`, 11},
+		{`len("héllo")`, 5},
+		{`len("犬の数")`, 3},
 		{`len(1)`, "argument to `len` not supported, got INTEGER"},
 		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
 		{`len([1, 2, 3])`, 3},
@@ -420,6 +714,207 @@ func TestBuiltinFunctions(t *testing.T) {
 	}
 }
 
+func TestCollectionBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"map([1, 2, 3], fn(x) { x * 2 })", "[2, 4, 6]"},
+		{"map([[1], [1, 2]], len)", "[1, 2]"},
+		{"map([], fn(x) { x })", "[]"},
+		{"filter([1, 2, 3, 4], fn(x) { x > 2 })", "[3, 4]"},
+		{"filter([1, null, false, 0], fn(x) { x })", "[1, 0]"},
+		{"reduce([1, 2, 3, 4], fn(acc, x) { acc + x })", "10"},
+		{"reduce([1, 2, 3], fn(acc, x) { acc * x }, 10)", "60"},
+		{"reduce([], fn(acc, x) { acc + x }, 0)", "0"},
+		{"range(4)", "[0, 1, 2, 3]"},
+		{"range(2, 5)", "[2, 3, 4]"},
+		{"range(10, 0, -3)", "[10, 7, 4, 1]"},
+		{"range(0)", "[]"},
+		{"range(-3)", "[]"},
+		{"range(9223372036854775806, 9223372036854775807, 2)", "[9223372036854775806]"},
+		{"range(-9223372036854775807, -9223372036854775807 + 7, 4611686018427387904)", "[-9223372036854775807]"},
+		{"range(9223372036854775807, 9223372036854775800, -4)", "[9223372036854775807, 9223372036854775803]"},
+		{"sort([3, 1, 2])", "[1, 2, 3]"},
+		{`sort(["b", "c", "a"])`, "[a, b, c]"},
+		{"sort([3, 1, 2], fn(a, b) { b - a })", "[3, 2, 1]"},
+		{"sort([[2, 1], [1, 2], [2, 0]], fn(a, b) { first(a) - first(b) })", "[[1, 2], [2, 1], [2, 0]]"},
+		{"let a = [2, 1]; sort(a); a", "[2, 1]"},
+		{"reverse([1, 2, 3])", "[3, 2, 1]"},
+		{`reverse("abc")`, "cba"},
+		{`join([1, "a", true], ", ")`, "1, a, true"},
+		{`join(["a", "b"])`, "ab"},
+		{`split("a,b,,c", ",")`, "[a, b, , c]"},
+		{`split("abc", "")`, "[a, b, c]"},
+		{`keys({"a": 1})`, "[a]"},
+		{`values({"a": 1})`, "[1]"},
+		{`sort(keys({"b": 1, "a": 2}))`, "[a, b]"},
+		{`sort(values({"b": 1, "a": 2}))`, "[1, 2]"},
+		{"contains([1, 2, 3], 2)", "true"},
+		{`contains([1, "2"], 2)`, "false"},
+		{`contains("hello", "ell")`, "true"},
+		{`contains({"a": 1}, "a")`, "true"},
+		{`contains({"a": 1}, "b")`, "false"},
+		{"index_of([5, 6, 7], 7)", "2"},
+		{"index_of([5, 6, 7], 8)", "-1"},
+		{`index_of("héllo", "llo")`, "2"},
+		{"zip([1, 2, 3], [4, 5])", "[[1, 4], [2, 5]]"},
+		{`zip([1], ["a"], [true])`, "[[1, a, true]]"},
+		{"flatten([1, [2, [3, [4]]]])", "[1, 2, [3, [4]]]"},
+		{"flatten([1, [2, [3, [4]]]], 2)", "[1, 2, 3, [4]]"},
+		{"slice([1, 2, 3, 4], 1, 3)", "[2, 3]"},
+		{"slice([1, 2, 3, 4], 2)", "[3, 4]"},
+		{"slice([1, 2, 3, 4], -2)", "[3, 4]"},
+		{"slice([1, 2, 3, 4], 3, 1)", "[]"},
+		{"slice([1, 2], 0, 10)", "[1, 2]"},
+		{`slice("héllo", 1, 3)`, "él"},
+		{"struct P { x } map([1, 2], P)", "[P{x: 1}, P{x: 2}]"},
+		{"struct P { x; fn add(y) { self.x + y } } map([1, 2], P(10).add)", "[11, 12]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil {
+			t.Errorf("%s: evaluated to nil", tt.input)
+			continue
+		}
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: wrong result. expected=%q, got=%q",
+				tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestCollectionBuiltinErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"map(1, fn(x) { x })", "argument to `map` must be ARRAY, got INTEGER"},
+		{"map([1], fn(x) { x + true })", "type mismatch: INTEGER + BOOLEAN"},
+		{"map([1], 5)", "not a function: INTEGER"},
+		{"map([1], fn(x, y) { x })", "wrong number of arguments. got=1, want=2"},
+		{"filter([1])", "wrong number of arguments. got=1, want=2"},
+		{"reduce([], fn(acc, x) { acc })", "`reduce` of empty array with no initial value"},
+		{"range(1, 2, 0)", "`range` step must not be zero"},
+		{`range("a")`, "argument to `range` must be INTEGER, got STRING"},
+		{"range(9223372036854775807)", "`range` would produce 9223372036854775807 elements, more than 10000000"},
+		{"range(-9223372036854775807, 9223372036854775807, 4)", "`range` would produce 4611686018427387904 elements, more than 10000000"},
+		{"sort([true, false])", "cannot compare BOOLEAN with BOOLEAN"},
+		{"sort([1, 2], fn(a, b) { true })", "`sort` comparator must return INTEGER, got BOOLEAN"},
+		{"sort([1, 2], fn(a, b) { missing })", "identifier not found: missing"},
+		{"reverse(1)", "argument to `reverse` not supported, got INTEGER"},
+		{"join([1], 1)", "argument to `join` must be STRING, got INTEGER"},
+		{`split("a")`, "wrong number of arguments. got=1, want=2"},
+		{"keys([])", "argument to `keys` must be HASH, got ARRAY"},
+		{`contains("abc", 1)`, "argument to `contains` must be STRING, got INTEGER"},
+		{"zip([1])", "wrong number of arguments. got=1, want=at least 2"},
+		{`slice([1], "a")`, "argument to `slice` must be INTEGER, got STRING"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%s: no error object returned. got=%T(%+v)",
+				tt.input, evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q",
+				tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestStringBuiltinsAndMethods(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`upper("héllo")`, "HÉLLO"},
+		{`lower("HeLLo")`, "hello"},
+		{`trim("  hi \n")`, "hi"},
+		{`trim("--hi--", "-")`, "hi"},
+		{`replace("a-b-c", "-", "+")`, "a+b+c"},
+		{`starts_with("monkey", "mon")`, "true"},
+		{`ends_with("monkey", "mon")`, "false"},
+		{`find("犬の数", "数")`, "2"},
+		{`find("abc", "z")`, "-1"},
+		{`repeat("ab", 3)`, "ababab"},
+		{`repeat("ab", 0)`, ""},
+		{`repeat("", 9223372036854775807)`, ""},
+		{`chars("añb")`, "[a, ñ, b]"},
+		{`format("{} + {} = {}", 1, 2, 3)`, "1 + 2 = 3"},
+		{`format("{1}{0}{1}", "a", "b")`, "bab"},
+		{`format("{{}} {}", [1, "x"])`, "{} [1, x]"},
+		{`"monkey".upper()`, "MONKEY"},
+		{`let s = "  padded  "; s.trim().len()`, "6"},
+		{`"a,b".split(",")`, "[a, b]"},
+		{`"Hello {}".format("world")`, "Hello world"},
+		{`"abc".reverse().upper()`, "CBA"},
+		{`"abc".contains("b")`, "true"},
+		{`let up = "x".upper; up()`, "X"},
+		{`"héllo"[1]`, "é"},
+		{`"héllo"[4]`, "o"},
+		{`"abc"[3]`, "null"},
+		{`"abc"[-1]`, "c"},
+		{`"abc"[-4]`, "null"},
+		{`let s = "abc"; s[0] + s[2]`, "ac"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil {
+			t.Errorf("%s: evaluated to nil", tt.input)
+			continue
+		}
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: wrong result. expected=%q, got=%q",
+				tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestStringBuiltinErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{`upper(1)`, "argument to `upper` must be STRING, got INTEGER"},
+		{`upper("a", "b")`, "wrong number of arguments. got=2, want=1"},
+		{`replace("a", "b")`, "wrong number of arguments. got=2, want=3"},
+		{`repeat("a", -1)`, "`repeat` count must not be negative, got -1"},
+		{`repeat("ab", 9223372036854775807)`, "`repeat` result would be longer than 268435456 bytes"},
+		{`repeat("ab", 134217729)`, "`repeat` result would be longer than 268435456 bytes"},
+		{`format("{} {}", 1)`, "format string refers to argument 1, but got 1 arguments"},
+		{`format("{x}", 1)`, "invalid placeholder {x} in format string"},
+		{`format("{", 1)`, "unterminated placeholder in format string \"{\""},
+		{`"abc".shout()`, "STRING has no method shout"},
+		{`"abc".repeat("x")`, "argument to `repeat` must be INTEGER, got STRING"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%s: no error object returned. got=%T(%+v)",
+				tt.input, evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q",
+				tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
 func TestArrayLiterals(t *testing.T) {
 	input := "[1, 2 * 2, 3 + 3]"
 
@@ -482,6 +977,14 @@ func TestArrayIndexExpressions(t *testing.T) {
 		},
 		{
 			"[1, 2, 3][-1]",
+			3,
+		},
+		{
+			"[1, 2, 3][-3]",
+			1,
+		},
+		{
+			"[1, 2, 3][-4]",
 			nil,
 		},
 	}
@@ -497,52 +1000,127 @@ func TestArrayIndexExpressions(t *testing.T) {
 	}
 }
 
-func TestHashLiterals(t *testing.T) {
-	input := `let two = "two";
-	{
-		"one": 10 - 9,
-		two: 1 + 1,
-		"thr" + "ee": 6 / 2,
-		4: 4,
-		true: 5,
-		false: 6
-	}`
-
-	evaluated := testEval(input)
-	result, ok := evaluated.(*object.Hash)
-	if !ok {
-		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
-	}
-
-	expected := map[object.HashKey]int64{
-		(&object.String{Value: "one"}).HashKey():   1,
-		(&object.String{Value: "two"}).HashKey():   2,
-		(&object.String{Value: "three"}).HashKey(): 3,
-		(&object.Integer{Value: 4}).HashKey():      4,
-		TRUE.HashKey():                             5,
-		FALSE.HashKey():                            6,
-	}
-
-	if len(result.Pairs) != len(expected) {
-		t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
+func TestSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2, 3, 4][1:3]", "[2, 3]"},
+		{"[1, 2, 3, 4][:2]", "[1, 2]"},
+		{"[1, 2, 3, 4][2:]", "[3, 4]"},
+		{"[1, 2, 3, 4][:]", "[1, 2, 3, 4]"},
+		{"[1, 2, 3, 4][-2:]", "[3, 4]"},
+		{"[1, 2, 3, 4][:-1]", "[1, 2, 3]"},
+		{"[1, 2, 3, 4][3:1]", "[]"},
+		{"[1, 2, 3, 4][-10:10]", "[1, 2, 3, 4]"},
+		{"let i = 1; [1, 2, 3, 4][i:i + 2]", "[2, 3]"},
+		{`"héllo"[1:3]`, "él"},
+		{`"héllo"[-3:]`, "llo"},
+		{`"héllo"[:0]`, ""},
+		{"let a = [1, 2, 3]; let b = a[:]; same(a, b)", "false"},
 	}
 
-	for expectedKey, expectedValue := range expected {
-		pair, ok := result.Pairs[expectedKey]
-		if !ok {
-			t.Errorf("no pair for given key in Pairs")
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil {
+			t.Errorf("%s: evaluated to nil", tt.input)
+			continue
 		}
 
-		testIntegerObject(t, pair.Value, expectedValue)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: wrong result. expected=%q, got=%q",
+				tt.input, tt.expected, evaluated.Inspect())
+		}
 	}
 }
 
-func TestHashIndexExpressions(t *testing.T) {
+func TestStrictIndexing(t *testing.T) {
+	ctx := &Context{StrictIndexing: true}
+
 	tests := []struct {
-		input    string
-		expected interface{}
+		input           string
+		expectedMessage string
 	}{
-		{
+		{"[1, 2, 3][3]", "index out of range: 3 with length 3 at line 1, column 10"},
+		{"let a = [1];\nlet b = a[-2];", "index out of range: -2 with length 1 at line 2, column 10"},
+		{`"abc"[5]`, "index out of range: 5 with length 3 at line 1, column 6"},
+		{"[1, 2][true:]", "slice bound must be INTEGER, got BOOLEAN"},
+		{"5[1:]", "slice operator not supported: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWith(tt.input, ctx)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%s: no error object returned. got=%T(%+v)",
+				tt.input, evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q",
+				tt.expectedMessage, errObj.Message)
+		}
+	}
+
+	testIntegerObject(t, testEvalWith("[1, 2, 3][-1]", ctx), 3)
+	testIntegerObject(t, testEvalWith("let f = fn(a) { a[-1] }; f([1, 2, 3])", ctx), 3)
+
+	if evaluated := testEvalWith("let f = fn(a) { a[3] }; f([1, 2, 3])", ctx); !isError(evaluated) {
+		t.Errorf("strict indexing did not reach a function body. got=%s", evaluated.Inspect())
+	}
+	if evaluated := testEval("[1, 2, 3][3]"); evaluated != NULL {
+		t.Errorf("strict indexing leaked into another evaluation. got=%s", evaluated.Inspect())
+	}
+}
+
+func TestHashLiterals(t *testing.T) {
+	input := `let two = "two";
+	{
+		"one": 10 - 9,
+		two: 1 + 1,
+		"thr" + "ee": 6 / 2,
+		4: 4,
+		true: 5,
+		false: 6
+	}`
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Hash)
+	if !ok {
+		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := map[object.Hashable]int64{
+		&object.String{Value: "one"}:   1,
+		&object.String{Value: "two"}:   2,
+		&object.String{Value: "three"}: 3,
+		&object.Integer{Value: 4}:      4,
+		TRUE:                           5,
+		FALSE:                          6,
+	}
+
+	if result.Len() != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got=%d", result.Len())
+	}
+
+	for expectedKey, expectedValue := range expected {
+		pair, ok := result.Get(expectedKey)
+		if !ok {
+			t.Errorf("no pair for given key in Pairs")
+		}
+
+		testIntegerObject(t, pair.Value, expectedValue)
+	}
+}
+
+func TestHashIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{
 			`{"foo": 5}["foo"]`,
 			5,
 		},
@@ -570,6 +1148,46 @@ func TestHashIndexExpressions(t *testing.T) {
 			`{false: 5}[false]`,
 			5,
 		},
+		{
+			`{[1, 2]: 5}[[1, 2]]`,
+			5,
+		},
+		{
+			`{[1, 2]: 5}[[2, 1]]`,
+			nil,
+		},
+		{
+			`{[1, [2, "a"]]: 5}[[1, [2, "a"]]]`,
+			5,
+		},
+		{
+			`let k = freeze({"a": 1, "b": 2}); {k: 5}[freeze({"b": 2, "a": 1})]`,
+			5,
+		},
+		{
+			`let k = freeze({"a": 1}); {k: 5}[freeze({"a": 2})]`,
+			nil,
+		},
+		{
+			`{[1, 2]: 5, [1, 2]: 6}[[1, 2]]`,
+			6,
+		},
+		{
+			`let h = {"a": 1, "b": 2}; delete(h, "a"); h["a"]`,
+			nil,
+		},
+		{
+			`let h = {"a": 1, "b": 2}; delete(h, "a"); h["b"]`,
+			2,
+		},
+		{
+			`delete({"a": 1}, "a")`,
+			1,
+		},
+		{
+			`delete({"a": 1}, "b")`,
+			nil,
+		},
 	}
 
 	for _, tt := range tests {
@@ -582,11 +1200,590 @@ func TestHashIndexExpressions(t *testing.T) {
 		}
 	}
 }
+
+func TestHashOrder(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"b": 1, "a": 2, "c": 3, 1: 4, true: 5}`, `{b: 1, a: 2, c: 3, 1: 4, true: 5}`},
+		{`keys({"b": 1, "a": 2, "c": 3})`, `[b, a, c]`},
+		{`values({"b": 1, "a": 2, "c": 3})`, `[1, 2, 3]`},
+		{`{"b": 1, "a": 2, "b": 3}`, `{b: 3, a: 2}`},
+		{`let h = {"a": 1, "b": 2, "c": 3}; delete(h, "b"); h`, `{a: 1, c: 3}`},
+		{`let h = {"a": 1, "b": 2, "c": 3}; delete(h, "a"); delete(h, "c"); keys(h)`, `[b]`},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: wrong result. expected=%q, got=%q",
+				tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestUnusableHashKeys(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{`{{"a": 1}: 5}`, "unusable as hash key: HASH (use `freeze` first)"},
+		{`{"a": 1}[{"a": 1}]`, "unusable as hash key: HASH (use `freeze` first)"},
+		{`{fn() {}: 5}`, "unusable as hash key: FUNCTION"},
+		{`{[1, {"a": 1}]: 5}`, "unusable as hash key: ARRAY (it holds a hash that is not frozen)"},
+		{`{freeze({"a": [{}]}): 5}`, "unusable as hash key: HASH (it holds a hash that is not frozen)"},
+		{`#{[{}]}`, "unusable as set element: ARRAY (it holds a hash that is not frozen)"},
+		{`freeze([1])`, "argument to `freeze` must be HASH, got ARRAY"},
+		{`delete(freeze({"a": 1}), "a")`, "cannot delete from a frozen hash"},
+		{`delete([1], 0)`, "argument to `delete` must be HASH, got ARRAY"},
+		{`delete({}, fn() {})`, "unusable as hash key: FUNCTION"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%s: no error object returned. got=%T(%+v)",
+				tt.input, evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q",
+				tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestSets(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"#{}", "#{}"},
+		{"#{3, 1, 2, 1, 3}", "#{3, 1, 2}"},
+		{`#{"b", [1], "a", [1]}`, "#{b, [1], a}"},
+		{"let xs = [2, 2, 1]; #{...xs}", "#{2, 1}"},
+		{"[...#{1, 1, 2}]", "[1, 2]"},
+		{"len(#{1, 2, 2})", "2"},
+		{"2 in #{1, 2}", "true"},
+		{"3 in #{1, 2}", "false"},
+		{"[1] in #{[1]}", "true"},
+		{`"a" in {"a": 1}`, "true"},
+		{"2 in [1, 2]", "true"},
+		{`"ell" in "hello"`, "true"},
+		{"contains(#{1, 2}, 2)", "true"},
+		{"union(#{1, 2}, #{3, 2})", "#{1, 2, 3}"},
+		{"intersection(#{1, 2, 3}, #{3, 2})", "#{2, 3}"},
+		{"difference(#{1, 2, 3}, #{2})", "#{1, 3}"},
+		{"let s = #{1}; add(s, 2); add(s, 1); s", "#{1, 2}"},
+		{"let s = #{1, 2, 3}; remove(s, 2)", "true"},
+		{"let s = #{1, 2, 3}; remove(s, 4)", "false"},
+		{"let s = #{1, 2, 3}; remove(s, 1); add(s, 1); s", "#{2, 3, 1}"},
+		{"#{1, 2} == #{2, 1}", "true"},
+		{"#{1, 2} == #{1, 2, 3}", "false"},
+		{"let s = #{1}; add(s, [s]); s", "#{1, [#{...}]}"},
+		{"let s = #{}; add(s, [s]); let t = #{}; add(t, [t]); s == t", "true"},
+		{"let s = #{}; add(s, [s]); let t = #{}; add(t, [t]); add(t, 1); s == t", "false"},
+		{"let s = #{}; add(s, [s]); [s] in s", "true"},
+		{"let s = #{}; add(s, [s]); let t = #{}; add(t, [t]); add(s, [t]); len(s)", "1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: wrong result. expected=%q, got=%q",
+				tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestSetErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"#{fn() {}}", "unusable as set element: FUNCTION"},
+		{`#{{"a": 1}}`, "unusable as set element: HASH (use `freeze` first)"},
+		{"#{#{1}}", "unusable as set element: SET"},
+		{"1 in 2", "unknown operator: INTEGER in INTEGER"},
+		{`1 in "abc"`, "type mismatch: INTEGER in STRING"},
+		{"union(#{1}, [1])", "argument to `union` must be SET, got ARRAY"},
+		{"intersection(#{1})", "wrong number of arguments. got=1, want=2"},
+		{"add([1], 2)", "argument to `add` must be SET, got ARRAY"},
+		{"remove(#{1}, fn() {})", "unusable as set element: FUNCTION"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%s: no error object returned. got=%T(%+v)",
+				tt.input, evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q",
+				tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestJSONBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`json_parse("{\"b\": 1, \"a\": [true, false, null], \"c\": \"x\"}")`,
+			`{b: 1, a: [true, false, null], c: x}`},
+		{`keys(json_parse("{\"z\": 1, \"y\": 2, \"x\": 3}"))`, `[z, y, x]`},
+		{`json_parse(" [ ] ")`, `[]`},
+		{`json_parse("-12")`, `-12`},
+		{`json_parse("123456789012345678901234567890")`, `123456789012345678901234567890`},
+		{`json_parse("\"a\\n\\u00e9\\ud83d\\ude00\"")`, "a\n\u00e9\U0001F600"},
+		{`json_parse("{\"a\": 1, \"a\": 2}")`, `{a: 2}`},
+		{`json_stringify({"b": 1, "a": [1, "x", null, true]})`, `{"b":1,"a":[1,"x",null,true]}`},
+		{`json_stringify({"a": [1, {}], "b": []}, 2)`,
+			"{\n  \"a\": [\n    1,\n    {}\n  ],\n  \"b\": []\n}"},
+		{`json_stringify("quote \" and \\ and \n")`, `"quote \" and \\ and \n"`},
+		{`json_stringify(#{1, 2})`, `[1,2]`},
+		{`let s = #{1}; json_stringify([s, s])`, `[[1],[1]]`},
+		{`struct P { x, y } json_stringify(P(1, "a"))`, `{"x":1,"y":"a"}`},
+		{`let s = "{\"k\": [1, 2, {\"n\": null}]}"; json_stringify(json_parse(s))`,
+			`{"k":[1,2,{"n":null}]}`},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: wrong result. expected=%q, got=%q",
+				tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestJSONBuiltinErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{`json_parse("{\"a\": 1,}")`, "json_parse: expected string key, got '}' at line 1, column 9"},
+		{`json_parse("[1, 2")`, "json_parse: expected ',' or ']' in array, got end of input at line 1, column 6"},
+		{`json_parse("{\n  \"a\" 1\n}")`, "json_parse: expected ':' after object key, got '1' at line 2, column 7"},
+		{`json_parse("[1] x")`, "json_parse: unexpected character 'x' at line 1, column 5"},
+		{`json_parse("")`, "json_parse: unexpected end of input at line 1, column 1"},
+		{`json_parse("tru")`, "json_parse: unexpected character 't' at line 1, column 1"},
+		{`json_parse("1.5")`, "json_parse: only integers are supported, got a fractional number at line 1, column 1"},
+		{`json_parse("01")`, "json_parse: leading zero in number at line 1, column 1"},
+		{`json_parse("\"abc")`, "json_parse: unterminated string at line 1, column 5"},
+		{`json_parse("\"\\x\"")`, "json_parse: invalid escape sequence \\x at line 1, column 3"},
+		{`json_parse(1)`, "argument to `json_parse` must be STRING, got INTEGER"},
+		{`json_stringify(fn(x) { x })`, "json_stringify: FUNCTION cannot be represented in JSON"},
+		{`json_stringify({"f": len})`, "json_stringify: BUILTIN cannot be represented in JSON"},
+		{`json_stringify({1: 2})`, "json_stringify: hash keys must be STRING, got INTEGER"},
+		{`json_stringify([1], -1)`, "`json_stringify` indent must not be negative, got -1"},
+		{`let s = #{}; add(s, [s]); json_stringify(s)`, "json_stringify: SET contains itself"},
+		{`json_stringify()`, "wrong number of arguments. got=0, want=1 to 2"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%s: no error object returned. got=%T(%+v)",
+				tt.input, evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q",
+				tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestIOBuiltins(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	readable := filepath.Join(dir, "readable")
+	writable := filepath.Join(dir, "writable")
+	os.Mkdir(readable, 0755)
+	os.Mkdir(writable, 0755)
+	ioutil.WriteFile(filepath.Join(readable, "b.txt"), []byte("bee"), 0644)
+	ioutil.WriteFile(filepath.Join(readable, "a.txt"), []byte("ay"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "secret.txt"), []byte("secret"), 0644)
+	os.Setenv("MONKEY_TEST_VAR", "banana")
+
+	exitCode := -1
+	var stdout bytes.Buffer
+	ctx := &Context{
+		Allowed: Capabilities{
+			ReadPaths:  []string{readable},
+			WritePaths: []string{writable},
+			EnvNames:   []string{"MONKEY_TEST_VAR", "MONKEY_UNSET_VAR"},
+			Stdin:      true,
+			Exit:       true,
+		},
+		Stdin:  bufio.NewReader(strings.NewReader("first line\r\nsecond")),
+		Stdout: &stdout,
+		Exit:   func(code int) { exitCode = code },
+	}
+
+	quote := func(path string) string { return fmt.Sprintf("%q", path) }
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"read_file(" + quote(filepath.Join(readable, "a.txt")) + ")", "ay"},
+		{"list_dir(" + quote(readable) + ")", "[a.txt, b.txt]"},
+		{"write_file(" + quote(filepath.Join(writable, "out.txt")) + `, "written")`, "null"},
+		{`getenv("MONKEY_TEST_VAR")`, "banana"},
+		{`getenv("MONKEY_UNSET_VAR")`, "null"},
+		{"read_line()", "first line"},
+		{"read_line()", "second"},
+		{"read_line()", "null"},
+		{"exit(3)", "null"},
+		{"read_file(" + quote(filepath.Join(readable, "..", "secret.txt")) + ")",
+			"ERROR: read_file: no read access to " + filepath.Join(readable, "..", "secret.txt")},
+		{"write_file(" + quote(filepath.Join(readable, "a.txt")) + `, "x")`,
+			"ERROR: write_file: no write access to " + filepath.Join(readable, "a.txt")},
+		{"list_dir(" + quote(dir) + ")", "ERROR: list_dir: no read access to " + dir},
+		{`getenv("HOME")`, "ERROR: getenv: no access to environment variable HOME"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWith(tt.input, ctx)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: wrong result. expected=%q, got=%q",
+				tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	written, _ := ioutil.ReadFile(filepath.Join(writable, "out.txt"))
+	if string(written) != "written" {
+		t.Errorf("write_file wrote %q, want %q", written, "written")
+	}
+
+	if exitCode != 3 {
+		t.Errorf("exit called with %d, want 3", exitCode)
+	}
+
+	testEvalWith(`puts("out", 1); map([2], puts)`, ctx)
+	if stdout.String() != "out\n1\n2\n" {
+		t.Errorf("puts wrote %q, want %q", stdout.String(), "out\n1\n2\n")
+	}
+
+	// a dangling link in a writable directory must not let write_file
+	// create its target outside it
+	outside := filepath.Join(dir, "outside.txt")
+	link := filepath.Join(writable, "link.txt")
+	if err := os.Symlink(outside, link); err == nil {
+		expected := "ERROR: write_file: no write access to " + link
+		if evaluated := testEvalWith("write_file("+quote(link)+`, "x")`, ctx); evaluated.Inspect() != expected {
+			t.Errorf("wrong result through a dangling link. expected=%q, got=%q", expected, evaluated.Inspect())
+		}
+		if _, err := os.Stat(outside); !os.IsNotExist(err) {
+			t.Errorf("write_file created %s outside the writable directory", outside)
+		}
+	}
+
+	// an empty root does not stand for the current directory
+	empty := &Context{Allowed: Capabilities{ReadPaths: []string{""}}}
+	if evaluated := testEvalWith(`list_dir(".")`, empty); evaluated.Inspect() != "ERROR: list_dir: no read access to ." {
+		t.Errorf("an empty read path allowed %s", evaluated.Inspect())
+	}
+
+	// with access but no input given, read_line finds none
+	if evaluated := testEvalWith("read_line()", &Context{Allowed: Capabilities{Stdin: true}}); evaluated != NULL {
+		t.Errorf("read_line without input returned %s, want null", evaluated.Inspect())
+	}
+}
+
+func TestIOBuiltinsSandboxedByDefault(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{`read_file("main.go")`, "read_file: no read access to main.go"},
+		{`write_file("out.txt", "x")`, "write_file: no write access to out.txt"},
+		{`list_dir(".")`, "list_dir: no read access to ."},
+		{`getenv("HOME")`, "getenv: no access to environment variable HOME"},
+		{`read_line()`, "read_line: no access to standard input"},
+		{`exit(0)`, "exit: not allowed to end the process"},
+		{`read_file(1)`, "argument to `read_file` must be STRING, got INTEGER"},
+		{`exit("0")`, "argument to `exit` must be INTEGER, got STRING"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%s: no error object returned. got=%T(%+v)",
+				tt.input, evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q",
+				tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestInterrupt(t *testing.T) {
+	interrupt := make(chan struct{})
+	close(interrupt)
+
+	evaluated := testEvalWith("let f = fn() { 1 }; f()", &Context{Interrupt: interrupt})
+	errObj, ok := evaluated.(*object.Error)
+	if !ok || errObj.Message != "interrupted" {
+		t.Errorf("expected an interrupted evaluation to stop. got=%s", evaluated.Inspect())
+	}
+}
+
+func TestMatchExpressions(t *testing.T) {
+	describe := `
+let describe = fn(value) {
+  match (value) {
+    0 => "zero",
+    -1 => "minus one",
+    "hi" => "greeting",
+    true => "yes",
+    null => "nothing",
+    [] => "empty",
+    [x] => "one: ${x}",
+    [a, [b, _]] => "nested: ${a + b}",
+    [a, b] => "pair: ${a + b}",
+    {"kind": "circle", "r": r} => "circle ${r}",
+    {"kind": k} => "shape ${k}",
+    n if n == 10 => "big ${n}",
+    n => "other ${n}",
+  }
+};
+`
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"describe(0)", "zero"},
+		{"describe(-1)", "minus one"},
+		{`describe("hi")`, "greeting"},
+		{"describe(true)", "yes"},
+		{"describe(null)", "nothing"},
+		{"describe([])", "empty"},
+		{"describe([7])", "one: 7"},
+		{"describe([1, [2, 3]])", "nested: 3"},
+		{"describe([1, 2])", "pair: 3"},
+		{`describe({"kind": "circle", "r": 2})`, "circle 2"},
+		{`describe({"kind": "square", "side": 2})`, "shape square"},
+		{"describe(10)", "big 10"},
+		{"describe(3)", "other 3"},
+		{"describe(false)", "other false"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(describe + tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("%s: object is not String. got=%T (%+v)",
+				tt.input, evaluated, evaluated)
+			continue
+		}
+
+		if str.Value != tt.expected {
+			t.Errorf("%s: wrong value. expected=%q, got=%q",
+				tt.input, tt.expected, str.Value)
+		}
+	}
+}
+
+func TestMatchExpressionScoping(t *testing.T) {
+	input := `
+let x = 1;
+let y = match ([2, 3]) { [x, z] if x > 5 => 0, [x, z] => x + z };
+x + y;`
+
+	testIntegerObject(t, testEval(input), 6)
+}
+
+func TestMatchExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"match (5) { 1 => 1, 2 => 2 }", "match is not exhaustive: no arm matches 5"},
+		{"match (5) { n if n > 10 => 1 }", "match is not exhaustive: no arm matches 5"},
+		{"match (missing) { _ => 1 }", "identifier not found: missing"},
+		{"match (5) { n if n + true => 1 }", "type mismatch: INTEGER + BOOLEAN"},
+		{"match (5) { n => n + true }", "type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q",
+				tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestStructs(t *testing.T) {
+	point := `
+struct Point {
+  x, y;
+  fn normSquared() { self.x * self.x + self.y * self.y }
+  fn add(other) { Point(self.x + other.x, self.y + other.y) }
+  fn scale(by = 2) { Point(self.x * by, self.y * by) }
+}
+struct Empty {}
+`
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"Point(1, 2).x", 1},
+		{"let p = Point(3, 4); p.y", 4},
+		{"Point(3, 4).normSquared()", 25},
+		{"Point(1, 2).add(Point(10, 20)).y", 22},
+		{"Point(1, 2).scale().x + Point(1, 2).scale(3).y", 8},
+		{"let m = Point(1, 1).normSquared; m()", 2},
+		{"Point(1, 2) == Point(1, 2)", true},
+		{"Point(1, 2) != Point(1, 3)", true},
+		{"Point(1, 2) == Point(2, 1)", false},
+		{"Point(1, [1]) == Point(1, [1])", true},
+		{"Point(1, [1]) == Point(1, [2])", false},
+		{"Empty() == Empty()", true},
+		{"struct Other { x, y } Point(1, 2) == Other(1, 2)", false},
+		{"Point(1, 2) == 1", false},
+		{`{Point(1, 2): "a"}[Point(1, 2)]`, "a"},
+		{`Point("a", Point(1, 2))`, "Point{x: a, y: Point{x: 1, y: 2}}"},
+		{"Point", "struct Point { x, y }"},
+		{"Empty()", "Empty{}"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(point + tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			if evaluated == nil || evaluated.Inspect() != expected {
+				t.Errorf("%s: wrong value. expected=%q, got=%+v",
+					tt.input, expected, evaluated)
+			}
+		}
+	}
+}
+
+func TestStructErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"struct P { x } P(1, 2)", "wrong number of arguments to P. got=2, want=1"},
+		{"struct P { x } P(1).y", "P has no field or method y"},
+		{"struct P { x; fn f(a) { a } } P(1).f()", "wrong number of arguments. got=0, want=1"},
+		{"5.x", "member access not supported: INTEGER"},
+		{"struct P { x } P(1) + P(1)", "unknown operator: INSTANCE + INSTANCE"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%s: no error object returned. got=%T(%+v)",
+				tt.input, evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q",
+				tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+// recordingHook records what a Hook is told, one line per call.
+type recordingHook struct {
+	events []string
+}
+
+func (h *recordingHook) Statement(stmt ast.Statement, env *object.Environment) {
+	h.events = append(h.events, "statement "+stmt.String())
+}
+
+func (h *recordingHook) Call(fn *object.Function, env *object.Environment) {
+	h.events = append(h.events, "call "+fn.Name)
+}
+
+func (h *recordingHook) Return(fn *object.Function, result object.Object) {
+	h.events = append(h.events, "return "+fn.Name+" "+result.Inspect())
+}
+
+func TestTracer(t *testing.T) {
+	input := `let double = fn(x) { x * 2 };
+struct P { v; fn get() { self.v } }
+double(P(3).get());`
+
+	expected := []string{
+		"statement let double = fn(x) (x * 2);",
+		"statement struct P { v; fn get() { (self.v) } }",
+		"statement double((P(3).get)())",
+		"call P.get",
+		"statement (self.v)",
+		"return P.get 3",
+		"call double",
+		"statement (x * 2)",
+		"return double 6",
+	}
+
+	hook := &recordingHook{}
+	testIntegerObject(t, testEvalWith(input, &Context{Tracer: hook}), 6)
+
+	if len(hook.events) != len(expected) {
+		t.Fatalf("wrong number of events. expected=%d, got=%d:\n%s",
+			len(expected), len(hook.events), strings.Join(hook.events, "\n"))
+	}
+	for i, e := range expected {
+		if hook.events[i] != e {
+			t.Errorf("event %d wrong. expected=%q, got=%q", i, e, hook.events[i])
+		}
+	}
+}
+
 func testEval(input string) object.Object {
+	return testEvalWith(input, nil)
+}
+
+// testEvalWith evaluates input with ctx, or with the defaults if it is nil.
+func testEvalWith(input string, ctx *Context) object.Object {
 	l := lexer.New(input)
 	p := parser.New(l)
 	program := p.ParseProgram()
-	env := object.NewEnvironment()
+	env := NewEnvironment(ctx)
 
 	return Eval(program, env)
 }
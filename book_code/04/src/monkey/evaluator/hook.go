@@ -0,0 +1,16 @@
+package evaluator
+
+import (
+	"monkey/ast"
+	"monkey/object"
+)
+
+// Hook follows the evaluation of a program, for debuggers and profilers.
+// Statement is called before each statement of a program or block is
+// evaluated, Call when a function written in Monkey has its arguments bound
+// and is about to run its body, and Return when the body is done.
+type Hook interface {
+	Statement(stmt ast.Statement, env *object.Environment)
+	Call(fn *object.Function, env *object.Environment)
+	Return(fn *object.Function, result object.Object)
+}
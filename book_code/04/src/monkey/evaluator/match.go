@@ -0,0 +1,142 @@
+package evaluator
+
+import (
+	"monkey/ast"
+	"monkey/object"
+)
+
+func evalMatchExpression(
+	me *ast.MatchExpression,
+	env *object.Environment,
+) object.Object {
+	subject := Eval(me.Subject, env)
+	if isError(subject) {
+		return subject
+	}
+
+	for _, arm := range me.Arms {
+		armEnv := object.NewEnclosedEnvironment(env)
+
+		matched, err := matchPattern(arm.Pattern, subject, armEnv)
+		if err != nil {
+			return err
+		}
+		if !matched {
+			continue
+		}
+
+		if arm.Guard != nil {
+			guard := Eval(arm.Guard, armEnv)
+			if isError(guard) {
+				return guard
+			}
+			if !isTruthy(guard) {
+				continue
+			}
+		}
+
+		return Eval(arm.Body, armEnv)
+	}
+
+	return newError("match is not exhaustive: no arm matches %s", subject.Inspect())
+}
+
+func evalDestructuring(
+	pattern ast.Pattern,
+	value object.Object,
+	env *object.Environment,
+) object.Object {
+	matched, err := matchPattern(pattern, value, env)
+	if err != nil {
+		return err
+	}
+	if !matched {
+		return newError("cannot destructure %s with pattern %s",
+			value.Inspect(), pattern.String())
+	}
+
+	return nil
+}
+
+// matchPattern reports whether value has the shape described by pattern,
+// binding the names the pattern introduces in env as it goes.
+func matchPattern(
+	pattern ast.Pattern,
+	value object.Object,
+	env *object.Environment,
+) (bool, *object.Error) {
+	switch pattern := pattern.(type) {
+
+	case *ast.WildcardPattern:
+		return true, nil
+
+	case *ast.BindingPattern:
+		env.Set(pattern.Name.Value, value)
+		return true, nil
+
+	case *ast.LiteralPattern:
+		expected := Eval(pattern.Value, env)
+		if err, ok := expected.(*object.Error); ok {
+			return false, err
+		}
+		return object.Equal(expected, value), nil
+
+	case *ast.ArrayPattern:
+		array, ok := value.(*object.Array)
+		if !ok {
+			return false, nil
+		}
+
+		length := len(array.Elements)
+		if length < len(pattern.Elements) ||
+			(pattern.Rest == nil && length != len(pattern.Elements)) {
+			return false, nil
+		}
+
+		for i, element := range pattern.Elements {
+			matched, err := matchPattern(element, array.Elements[i], env)
+			if err != nil || !matched {
+				return false, err
+			}
+		}
+
+		if pattern.Rest != nil {
+			rest := make([]object.Object, length-len(pattern.Elements))
+			copy(rest, array.Elements[len(pattern.Elements):])
+			env.Set(pattern.Rest.Value, &object.Array{Elements: rest})
+		}
+		return true, nil
+
+	case *ast.HashPattern:
+		hash, ok := value.(*object.Hash)
+		if !ok {
+			return false, nil
+		}
+
+		for i, keyNode := range pattern.Keys {
+			key := Eval(keyNode, env)
+			if err, ok := key.(*object.Error); ok {
+				return false, err
+			}
+
+			hashKey, ok := object.AsHashable(key)
+			if !ok {
+				return false, unusableHashKey(key)
+			}
+
+			pair, ok := hash.Get(hashKey)
+			if !ok {
+				return false, nil
+			}
+
+			matched, err := matchPattern(pattern.Values[i], pair.Value, env)
+			if err != nil || !matched {
+				return false, err
+			}
+		}
+		return true, nil
+
+	default:
+		return false, newError("unknown pattern: %s", pattern.String())
+	}
+}
@@ -0,0 +1,181 @@
+package evaluator
+
+import (
+	"monkey/ast"
+	"monkey/object"
+	"strings"
+)
+
+func init() {
+	builtins["union"] = &object.Builtin{Fn: builtinUnion}
+	builtins["intersection"] = &object.Builtin{Fn: builtinIntersection}
+	builtins["difference"] = &object.Builtin{Fn: builtinDifference}
+	builtins["add"] = &object.Builtin{Fn: builtinAdd}
+	builtins["remove"] = &object.Builtin{Fn: builtinRemove}
+}
+
+func evalSetLiteral(
+	node *ast.SetLiteral,
+	env *object.Environment,
+) object.Object {
+	elements := evalExpressions(node.Elements, env)
+	if len(elements) == 1 && isError(elements[0]) {
+		return elements[0]
+	}
+
+	set := object.NewSet()
+	for _, element := range elements {
+		hashable, ok := object.AsHashable(element)
+		if !ok {
+			return unusableSetElement(element)
+		}
+		set.Add(hashable)
+	}
+
+	return set
+}
+
+func unusableSetElement(element object.Object) *object.Error {
+	if hash, ok := element.(*object.Hash); ok && !hash.Frozen {
+		return newError("unusable as set element: HASH (use `freeze` first)")
+	}
+	if _, ok := element.(object.Hashable); ok {
+		return newError("unusable as set element: %s (it holds a hash that is not frozen)", element.Type())
+	}
+	return newError("unusable as set element: %s", element.Type())
+}
+
+// evalInExpression tests for membership: of an element in a set or array,
+// of a key in a hash, or of a substring in a string.
+func evalInExpression(item, container object.Object) object.Object {
+	switch container := container.(type) {
+	case *object.Set:
+		element, ok := object.AsHashable(item)
+		if !ok {
+			return unusableSetElement(item)
+		}
+		return nativeBoolToBooleanObject(container.Contains(element))
+	case *object.Hash:
+		key, ok := object.AsHashable(item)
+		if !ok {
+			return unusableHashKey(item)
+		}
+		_, ok = container.Get(key)
+		return nativeBoolToBooleanObject(ok)
+	case *object.Array:
+		for _, element := range container.Elements {
+			if object.Equal(element, item) {
+				return TRUE
+			}
+		}
+		return FALSE
+	case *object.String:
+		str, ok := item.(*object.String)
+		if !ok {
+			return newError("type mismatch: %s in %s", item.Type(), container.Type())
+		}
+		return nativeBoolToBooleanObject(strings.Contains(container.Value, str.Value))
+	default:
+		return newError("unknown operator: %s in %s", item.Type(), container.Type())
+	}
+}
+
+// setArgs checks that a builtin got two sets.
+func setArgs(name string, args []object.Object) (*object.Set, *object.Set, *object.Error) {
+	if len(args) != 2 {
+		return nil, nil, wrongArgumentCount(len(args), "2")
+	}
+
+	sets := [2]*object.Set{}
+	for i, arg := range args {
+		set, ok := arg.(*object.Set)
+		if !ok {
+			return nil, nil, wrongArgumentType(name, object.SET_OBJ, arg)
+		}
+		sets[i] = set
+	}
+
+	return sets[0], sets[1], nil
+}
+
+// filterSet returns a new set of the elements of set for which keep returns
+// true, in the same order.
+func filterSet(set *object.Set, keep func(object.Hashable) bool) *object.Set {
+	result := object.NewSet()
+	for _, element := range set.Elements() {
+		hashable := element.(object.Hashable)
+		if keep(hashable) {
+			result.Add(hashable)
+		}
+	}
+	return result
+}
+
+func builtinUnion(env *object.Environment, args ...object.Object) object.Object {
+	a, b, err := setArgs("union", args)
+	if err != nil {
+		return err
+	}
+
+	result := filterSet(a, func(object.Hashable) bool { return true })
+	for _, element := range b.Elements() {
+		result.Add(element.(object.Hashable))
+	}
+	return result
+}
+
+func builtinIntersection(env *object.Environment, args ...object.Object) object.Object {
+	a, b, err := setArgs("intersection", args)
+	if err != nil {
+		return err
+	}
+
+	return filterSet(a, b.Contains)
+}
+
+func builtinDifference(env *object.Environment, args ...object.Object) object.Object {
+	a, b, err := setArgs("difference", args)
+	if err != nil {
+		return err
+	}
+
+	return filterSet(a, func(element object.Hashable) bool {
+		return !b.Contains(element)
+	})
+}
+
+// builtinAdd adds an element to a set and returns the set.
+func builtinAdd(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return wrongArgumentCount(len(args), "2")
+	}
+	set, ok := args[0].(*object.Set)
+	if !ok {
+		return wrongArgumentType("add", object.SET_OBJ, args[0])
+	}
+	element, ok := object.AsHashable(args[1])
+	if !ok {
+		return unusableSetElement(args[1])
+	}
+
+	set.Add(element)
+	return set
+}
+
+// builtinRemove removes an element from a set and reports whether the set
+// contained it.
+func builtinRemove(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return wrongArgumentCount(len(args), "2")
+	}
+	set, ok := args[0].(*object.Set)
+	if !ok {
+		return wrongArgumentType("remove", object.SET_OBJ, args[0])
+	}
+	element, ok := object.AsHashable(args[1])
+	if !ok {
+		return unusableSetElement(args[1])
+	}
+
+	return nativeBoolToBooleanObject(set.Remove(element))
+}
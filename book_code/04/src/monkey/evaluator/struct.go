@@ -0,0 +1,102 @@
+package evaluator
+
+import (
+	"monkey/ast"
+	"monkey/object"
+)
+
+func evalStructStatement(
+	ss *ast.StructStatement,
+	env *object.Environment,
+) object.Object {
+	structObj := &object.Struct{
+		Name:    ss.Name.Value,
+		Fields:  []string{},
+		Methods: make(map[string]*object.Function),
+	}
+
+	for _, field := range ss.Fields {
+		structObj.Fields = append(structObj.Fields, field.Value)
+	}
+
+	for _, method := range ss.Methods {
+		structObj.Methods[method.Name.Value] = &object.Function{
+			Name:       structObj.Name + "." + method.Name.Value,
+			Parameters: method.Function.Parameters,
+			Defaults:   method.Function.Defaults,
+			Rest:       method.Function.Rest,
+			Env:        env,
+			Body:       method.Function.Body,
+		}
+	}
+
+	env.Set(structObj.Name, structObj)
+
+	return nil
+}
+
+func instantiateStruct(structObj *object.Struct, args []object.Object) object.Object {
+	if len(args) != len(structObj.Fields) {
+		return newError("wrong number of arguments to %s. got=%d, want=%d",
+			structObj.Name, len(args), len(structObj.Fields))
+	}
+
+	values := make([]object.Object, len(args))
+	copy(values, args)
+
+	return &object.Instance{Struct: structObj, Values: values}
+}
+
+func evalMemberExpression(
+	me *ast.MemberExpression,
+	env *object.Environment,
+) object.Object {
+	receiver := Eval(me.Object, env)
+	if isError(receiver) {
+		return receiver
+	}
+
+	name := me.Property.Value
+
+	if str, ok := receiver.(*object.String); ok {
+		return lookupStringMethod(str, name)
+	}
+
+	instance, ok := receiver.(*object.Instance)
+	if !ok {
+		return newError("member access not supported: %s", receiver.Type())
+	}
+
+	if idx := instance.Struct.FieldIndex(name); idx >= 0 {
+		return instance.Values[idx]
+	}
+
+	if method, ok := instance.Struct.Methods[name]; ok {
+		return &object.BoundMethod{Receiver: instance, Method: method}
+	}
+
+	return newError("%s has no field or method %s", instance.Struct.Name, name)
+}
+
+func applyBoundMethod(
+	bm *object.BoundMethod,
+	args []object.Object,
+	env *object.Environment,
+) object.Object {
+	switch method := bm.Method.(type) {
+
+	case *object.Function:
+		extendedEnv, err := extendFunctionEnv(method, args)
+		if err != nil {
+			return err
+		}
+		extendedEnv.Set("self", bm.Receiver)
+		return callFunction(method, extendedEnv)
+
+	case *object.Builtin:
+		return method.Fn(env, append([]object.Object{bm.Receiver}, args...)...)
+
+	default:
+		return newError("not a function: %s", method.Type())
+	}
+}
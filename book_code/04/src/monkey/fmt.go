@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"monkey/format"
+	"os"
+)
+
+// runFmt runs the fmt subcommand with args, the arguments after "fmt", and
+// returns the exit status for the process.
+func runFmt(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	check := flags.Bool("check", false,
+		"list the files that are not formatted instead of rewriting them, and fail if there are any")
+	diff := flags.Bool("diff", false,
+		"print the changes formatting would make instead of rewriting the files")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: monkey fmt [--check] [--diff] [files]\n\n"+
+			"Formats the files in place, or standard input to standard output.\n\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		source, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return fmtFile("<stdin>", source, *check, *diff, func(formatted []byte) error {
+			_, err := os.Stdout.Write(formatted)
+			return err
+		})
+	}
+
+	status := 0
+	for _, path := range flags.Args() {
+		source, err := ioutil.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+			continue
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+			continue
+		}
+		write := func(formatted []byte) error {
+			if bytes.Equal(source, formatted) {
+				return nil
+			}
+			return ioutil.WriteFile(path, formatted, info.Mode())
+		}
+
+		if s := fmtFile(path, source, *check, *diff, write); s != 0 {
+			status = s
+		}
+	}
+
+	return status
+}
+
+// fmtFile formats source, read from path, and passes the result to write,
+// unless check or diff is set. It returns the exit status for the file.
+func fmtFile(path string, source []byte, check, diff bool, write func([]byte) error) int {
+	formatted, err := format.Source(source)
+	if err != nil {
+		if pe, ok := err.(*format.ParseError); ok {
+			for _, msg := range pe.Messages {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", path, msg)
+			}
+		} else {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path, err)
+		}
+		return 1
+	}
+
+	if !check && !diff {
+		if err := write(formatted); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+
+	if bytes.Equal(source, formatted) {
+		return 0
+	}
+	if diff {
+		os.Stdout.Write(format.Diff(path+".orig", path, source, formatted))
+	}
+	if check {
+		fmt.Println(path)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,189 @@
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// CONTEXT is the number of unchanged lines shown around a change by Diff.
+const CONTEXT = 3
+
+// Diff returns a unified diff turning a into b, or nil if they are the same.
+func Diff(oldName, newName string, a, b []byte) []byte {
+	if bytes.Equal(a, b) {
+		return nil
+	}
+
+	before := splitLines(string(a))
+	after := splitLines(string(b))
+	edits := diffLines(before, after)
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+
+	for start := 0; start < len(edits); {
+		// find the next change and the hunk around it
+		for start < len(edits) && edits[start].kind == ' ' {
+			start += 1
+		}
+		if start == len(edits) {
+			break
+		}
+
+		first := start - CONTEXT
+		if first < 0 {
+			first = 0
+		}
+		end := start
+		for end < len(edits) {
+			if edits[end].kind != ' ' {
+				end += 1
+				continue
+			}
+			unchanged := end
+			for unchanged < len(edits) && edits[unchanged].kind == ' ' {
+				unchanged += 1
+			}
+			if unchanged == len(edits) || unchanged-end > 2*CONTEXT {
+				break
+			}
+			end = unchanged
+		}
+		last := end + CONTEXT
+		if last > len(edits) {
+			last = len(edits)
+		}
+
+		writeHunk(&out, edits[first:last])
+		start = last
+	}
+
+	return out.Bytes()
+}
+
+type edit struct {
+	kind     byte // ' ', '-' or '+'
+	line     string
+	old, new int // line numbers, counting from 1, before and after the edit
+}
+
+func writeHunk(out *bytes.Buffer, edits []edit) {
+	oldStart, newStart := edits[0].old, edits[0].new
+	oldCount, newCount := 0, 0
+	for _, e := range edits {
+		if e.kind != '+' {
+			oldCount += 1
+		}
+		if e.kind != '-' {
+			newCount += 1
+		}
+	}
+	// an empty range is given by the line before it
+	if oldCount == 0 {
+		oldStart -= 1
+	}
+	if newCount == 0 {
+		newStart -= 1
+	}
+
+	fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(oldStart, oldCount), hunkRange(newStart, newCount))
+	for _, e := range edits {
+		out.WriteByte(e.kind)
+		out.WriteString(e.line)
+		if !strings.HasSuffix(e.line, "\n") {
+			out.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+func hunkRange(start, count int) string {
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+// splitLines splits s after each newline.
+func splitLines(s string) []string {
+	lines := []string{}
+	for len(s) > 0 {
+		i := strings.IndexByte(s, '\n') + 1
+		if i == 0 {
+			i = len(s)
+		}
+		lines = append(lines, s[:i])
+		s = s[i:]
+	}
+	return lines
+}
+
+// diffLines returns the edits turning a into b, using a longest common
+// subsequence of their lines.
+func diffLines(a, b []string) []edit {
+	// the common prefix and suffix need no table
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix += 1
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix &&
+		a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix += 1
+	}
+	middleA := a[prefix : len(a)-suffix]
+	middleB := b[prefix : len(b)-suffix]
+
+	// lcs[i][j] is the length of the longest common subsequence of
+	// middleA[i:] and middleB[j:]
+	lcs := make([][]int, len(middleA)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(middleB)+1)
+	}
+	for i := len(middleA) - 1; i >= 0; i-- {
+		for j := len(middleB) - 1; j >= 0; j-- {
+			if middleA[i] == middleB[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	edits := []edit{}
+	i, j := 0, 0
+	add := func(kind byte, line string) {
+		edits = append(edits, edit{kind: kind, line: line, old: i + 1, new: j + 1})
+		switch kind {
+		case ' ':
+			i += 1
+			j += 1
+		case '-':
+			i += 1
+		case '+':
+			j += 1
+		}
+	}
+
+	for k := 0; k < prefix; k++ {
+		add(' ', a[k])
+	}
+	for i-prefix < len(middleA) || j-prefix < len(middleB) {
+		x, y := i-prefix, j-prefix
+		switch {
+		case x < len(middleA) && y < len(middleB) && middleA[x] == middleB[y]:
+			add(' ', middleA[x])
+		case y == len(middleB) || x < len(middleA) && lcs[x+1][y] >= lcs[x][y+1]:
+			add('-', middleA[x])
+		default:
+			add('+', middleB[y])
+		}
+	}
+	for k := len(a) - suffix; k < len(a); k++ {
+		add(' ', a[k])
+	}
+
+	return edits
+}
@@ -0,0 +1,699 @@
+// Package format prints Monkey programs in a canonical style: two-space
+// indentation, one space around infix operators, only the parentheses the
+// precedence of the operators requires, and a trailing comma after each item
+// of a literal or call that spans several lines. Comments are kept.
+//
+// Like gofmt, the formatter leaves some choices to the source: a literal,
+// call or block is put on several lines if it was written on several lines,
+// and single blank lines between statements are kept.
+package format
+
+import (
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/token"
+	"strings"
+)
+
+const INDENT = "  "
+
+// ParseError is returned for programs that do not parse.
+type ParseError struct {
+	Messages []string
+}
+
+func (pe *ParseError) Error() string {
+	return strings.Join(pe.Messages, "\n")
+}
+
+// Source formats the program src.
+func Source(src []byte) ([]byte, error) {
+	l := lexer.New(string(src))
+	p := parser.New(l)
+
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, &ParseError{Messages: p.Errors()}
+	}
+
+	pr := newPrinter(string(src), l.Comments())
+	pr.statements(program.Statements, len(src))
+	if pr.out.Len() > 0 {
+		pr.out.WriteString("\n")
+	}
+
+	return []byte(pr.out.String()), nil
+}
+
+type printer struct {
+	src      string
+	tokens   []token.Token        // in source order, without comments
+	index    map[int]int          // index in tokens, by offset
+	raw      map[int]string       // source text of string tokens, by offset
+	close    map[int]token.Token  // matching closing bracket, by offset of the opening one
+	lines    map[int]int          // line a token ends on, by offset
+	trailing map[token.Token]bool // comments with code before them on their line
+
+	comments []token.Token // not printed yet
+
+	out       strings.Builder
+	depth     int
+	lastLine  int  // source line where what was printed last ends
+	afterOpen bool // whether an opening brace or bracket was printed last
+}
+
+func newPrinter(src string, comments []token.Token) *printer {
+	p := &printer{
+		src:       src,
+		index:     map[int]int{},
+		raw:       map[int]string{},
+		close:     map[int]token.Token{},
+		lines:     map[int]int{},
+		trailing:  map[token.Token]bool{},
+		comments:  comments,
+		afterOpen: true,
+	}
+
+	l := lexer.New(src)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		p.index[tok.Offset] = len(p.tokens)
+		p.tokens = append(p.tokens, tok)
+	}
+
+	nextComment := 0
+	stack := []token.Token{}
+	for i, tok := range p.tokens {
+		// a token runs up to the next token or comment
+		end := len(src)
+		if i+1 < len(p.tokens) {
+			end = p.tokens[i+1].Offset
+		}
+		for nextComment < len(comments) && comments[nextComment].Offset < tok.Offset {
+			nextComment += 1
+		}
+		if nextComment < len(comments) && comments[nextComment].Offset < end {
+			end = comments[nextComment].Offset
+		}
+		text := strings.TrimRight(src[tok.Offset:end], " \t\r\n")
+
+		if tok.Type == token.STRING || tok.Type == token.TEMPLATE {
+			p.raw[tok.Offset] = text
+		}
+		p.lines[tok.Offset] = tok.Line + strings.Count(text, "\n")
+
+		switch tok.Type {
+		case token.LPAREN, token.LBRACKET, token.LBRACE, token.SET_LBRACE:
+			stack = append(stack, tok)
+		case token.RPAREN, token.RBRACKET, token.RBRACE:
+			if len(stack) > 0 {
+				p.close[stack[len(stack)-1].Offset] = tok
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+
+	for _, comment := range comments {
+		lineStart := strings.LastIndexByte(src[:comment.Offset], '\n') + 1
+		if strings.TrimSpace(src[lineStart:comment.Offset]) != "" {
+			p.trailing[comment] = true
+		}
+	}
+
+	return p
+}
+
+func (p *printer) write(s string) {
+	p.out.WriteString(s)
+	p.afterOpen = false
+}
+
+// open writes an opening brace or bracket that starts a block of lines.
+func (p *printer) open(s string) {
+	p.write(s)
+	p.afterOpen = true
+	p.depth += 1
+}
+
+// closeLines ends a block of lines with s on a line of its own.
+func (p *printer) closeLines(s string) {
+	p.depth -= 1
+	p.out.WriteString("\n")
+	p.out.WriteString(strings.Repeat(INDENT, p.depth))
+	p.write(s)
+}
+
+// startLine starts a new line for something on line of the source, leaving
+// a blank line if the source had one or more.
+func (p *printer) startLine(line int) {
+	if p.out.Len() == 0 {
+		p.lastLine = line
+		return
+	}
+
+	if line-p.lastLine > 1 && !p.afterOpen {
+		p.out.WriteString("\n")
+	}
+	p.out.WriteString("\n")
+	p.out.WriteString(strings.Repeat(INDENT, p.depth))
+	p.afterOpen = false
+}
+
+// flushComments prints the comments before offset. A comment that followed
+// code on its line goes at the end of the current line.
+func (p *printer) flushComments(offset int) {
+	for len(p.comments) > 0 && p.comments[0].Offset < offset {
+		comment := p.comments[0]
+		p.comments = p.comments[1:]
+
+		if p.trailing[comment] && p.out.Len() > 0 {
+			afterOpen := p.afterOpen
+			p.write(" " + comment.Literal)
+			p.afterOpen = afterOpen
+			continue
+		}
+
+		p.startLine(comment.Line)
+		p.write(comment.Literal)
+		p.lastLine = comment.Line
+	}
+}
+
+func (p *printer) hasComments(start, end int) bool {
+	for _, comment := range p.comments {
+		if start < comment.Offset && comment.Offset < end {
+			return true
+		}
+	}
+	return false
+}
+
+// endLine returns the line where the source from start up to end ends.
+func (p *printer) endLine(start, end int) int {
+	line := 0
+	for i := p.index[start]; i < len(p.tokens) && p.tokens[i].Offset < end; i++ {
+		if l := p.lines[p.tokens[i].Offset]; l > line {
+			line = l
+		}
+	}
+	return line
+}
+
+// closing returns the bracket closing the one at open, or open itself if it
+// is unmatched.
+func (p *printer) closing(open token.Token) token.Token {
+	if tok, ok := p.close[open.Offset]; ok {
+		return tok
+	}
+	return open
+}
+
+// tokenAfter returns the token n places after tok.
+func (p *printer) tokenAfter(tok token.Token, n int) token.Token {
+	i := p.index[tok.Offset] + n
+	if i >= len(p.tokens) {
+		return tok
+	}
+	return p.tokens[i]
+}
+
+// statements prints stmts one per line, with the comments among them, up to
+// end, which is where the block or program ends.
+func (p *printer) statements(stmts []ast.Statement, end int) {
+	for i, stmt := range stmts {
+		start := startOf(stmt)
+
+		next := end
+		if i+1 < len(stmts) {
+			next = startOf(stmts[i+1]).Offset
+		}
+
+		p.flushComments(start.Offset)
+		p.startLine(start.Line)
+		p.statement(stmt)
+		if p.needsSemicolon(stmt, stmts[i+1:]) {
+			p.write(";")
+		}
+		p.lastLine = p.endLine(start.Offset, next)
+	}
+
+	p.flushComments(end)
+}
+
+// needsSemicolon reports whether stmt ends with a semicolon when followed by
+// rest. An if or match statement goes without one, unless the next statement
+// starts with something that would continue it, such as a parenthesis.
+func (p *printer) needsSemicolon(stmt ast.Statement, rest []ast.Statement) bool {
+	switch stmt := stmt.(type) {
+	case *ast.StructStatement:
+		return false
+	case *ast.ExpressionStatement:
+		switch stmt.Expression.(type) {
+		case *ast.IfExpression, *ast.MatchExpression:
+			if len(rest) == 0 {
+				return false
+			}
+			return parser.Precedence(startOf(rest[0]).Type) != parser.LOWEST
+		}
+	}
+	return true
+}
+
+// startOf returns the first token of a statement or expression.
+func startOf(node ast.Node) token.Token {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		return node.Token
+	case *ast.ReturnStatement:
+		return node.Token
+	case *ast.StructStatement:
+		return node.Token
+	case *ast.ExpressionStatement:
+		return node.Token
+	case *ast.InfixExpression:
+		return startOf(node.Left)
+	case *ast.CallExpression:
+		return startOf(node.Function)
+	case *ast.IndexExpression:
+		return startOf(node.Left)
+	case *ast.SliceExpression:
+		return startOf(node.Left)
+	case *ast.MemberExpression:
+		return startOf(node.Object)
+	}
+
+	return tokenOf(node)
+}
+
+// tokenOf returns the Token field every node but a few has.
+func tokenOf(node ast.Node) token.Token {
+	switch node := node.(type) {
+	case *ast.Identifier:
+		return node.Token
+	case *ast.Boolean:
+		return node.Token
+	case *ast.Null:
+		return node.Token
+	case *ast.IntegerLiteral:
+		return node.Token
+	case *ast.StringLiteral:
+		return node.Token
+	case *ast.TemplateLiteral:
+		return node.Token
+	case *ast.PrefixExpression:
+		return node.Token
+	case *ast.SpreadExpression:
+		return node.Token
+	case *ast.IfExpression:
+		return node.Token
+	case *ast.FunctionLiteral:
+		return node.Token
+	case *ast.ArrayLiteral:
+		return node.Token
+	case *ast.SetLiteral:
+		return node.Token
+	case *ast.HashLiteral:
+		return node.Token
+	case *ast.MatchExpression:
+		return node.Token
+	case *ast.WildcardPattern:
+		return node.Token
+	case *ast.BindingPattern:
+		return node.Token
+	case *ast.LiteralPattern:
+		return node.Token
+	case *ast.ArrayPattern:
+		return node.Token
+	case *ast.HashPattern:
+		return node.Token
+	}
+
+	return token.Token{}
+}
+
+func (p *printer) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		p.write("let ")
+		if stmt.Pattern != nil {
+			p.pattern(stmt.Pattern)
+		} else {
+			p.write(stmt.Name.Value)
+		}
+		p.write(" = ")
+		p.expression(stmt.Value)
+
+	case *ast.ReturnStatement:
+		p.write("return")
+		if stmt.ReturnValue != nil {
+			p.write(" ")
+			p.expression(stmt.ReturnValue)
+		}
+
+	case *ast.StructStatement:
+		p.structStatement(stmt)
+
+	case *ast.ExpressionStatement:
+		if stmt.Expression != nil {
+			p.expression(stmt.Expression)
+		}
+	}
+}
+
+func (p *printer) structStatement(stmt *ast.StructStatement) {
+	p.write("struct " + stmt.Name.Value + " ")
+
+	open := p.tokenAfter(stmt.Token, 2)
+	close := p.closing(open)
+
+	fields := []string{}
+	for _, field := range stmt.Fields {
+		fields = append(fields, field.Value)
+	}
+
+	if len(stmt.Methods) == 0 && close.Line == open.Line {
+		if len(fields) == 0 {
+			p.write("{}")
+		} else {
+			p.write("{ " + strings.Join(fields, ", ") + " }")
+		}
+		return
+	}
+
+	p.open("{")
+	if len(fields) > 0 {
+		p.flushComments(stmt.Fields[0].Token.Offset)
+		p.startLine(stmt.Fields[0].Token.Line)
+		p.write(strings.Join(fields, ", "))
+		if len(stmt.Methods) > 0 {
+			p.write(";")
+		}
+		p.lastLine = stmt.Fields[len(stmt.Fields)-1].Token.Line
+	}
+
+	for i, method := range stmt.Methods {
+		start := method.Function.Token
+
+		next := close.Offset
+		if i+1 < len(stmt.Methods) {
+			next = stmt.Methods[i+1].Function.Token.Offset
+		}
+
+		p.flushComments(start.Offset)
+		p.startLine(start.Line)
+		p.write("fn " + method.Name.Value)
+		p.function(method.Function)
+		p.lastLine = p.endLine(start.Offset, next)
+	}
+
+	p.flushComments(close.Offset)
+	p.closeLines("}")
+}
+
+// block prints a block on one line if it was on one line in the source and
+// has at most one statement, and with a statement per line otherwise.
+func (p *printer) block(block *ast.BlockStatement) {
+	close := p.closing(block.Token)
+
+	if !p.hasComments(block.Token.Offset, close.Offset) {
+		if len(block.Statements) == 0 {
+			p.write("{}")
+			return
+		}
+		if len(block.Statements) == 1 && close.Line == block.Token.Line {
+			stmt := block.Statements[0]
+			p.write("{ ")
+			p.statement(stmt)
+			if _, ok := stmt.(*ast.ExpressionStatement); !ok && p.needsSemicolon(stmt, nil) {
+				p.write(";")
+			}
+			p.write(" }")
+			return
+		}
+	}
+
+	p.open("{")
+	p.statements(block.Statements, close.Offset)
+	p.closeLines("}")
+}
+
+// item is an element of a list such as the arguments of a call.
+type item struct {
+	start token.Token
+	print func()
+}
+
+// list prints items between open and close, separated by commas. If the
+// source had the list on several lines, each item goes on a line of its own
+// and is followed by a comma. Spaces just inside open and close are only
+// printed on one line.
+func (p *printer) list(openToken token.Token, open, close string, items []item) {
+	closeToken := p.closing(openToken)
+
+	multiline := (len(items) > 0 && closeToken.Line != openToken.Line) ||
+		p.hasComments(openToken.Offset, closeToken.Offset)
+	if !multiline {
+		if len(items) == 0 {
+			p.write(strings.TrimSpace(open) + strings.TrimSpace(close))
+			return
+		}
+		p.write(open)
+		for i, item := range items {
+			if i > 0 {
+				p.write(", ")
+			}
+			item.print()
+		}
+		p.write(close)
+		return
+	}
+
+	p.open(strings.TrimSpace(open))
+	for i, item := range items {
+		next := closeToken.Offset
+		if i+1 < len(items) {
+			next = items[i+1].start.Offset
+		}
+
+		p.flushComments(item.start.Offset)
+		p.startLine(item.start.Line)
+		item.print()
+		p.write(",")
+		p.lastLine = p.endLine(item.start.Offset, next)
+	}
+	p.flushComments(closeToken.Offset)
+	p.closeLines(strings.TrimSpace(close))
+}
+
+func (p *printer) expressionList(openToken token.Token, open, close string, expressions []ast.Expression) {
+	items := []item{}
+	for _, e := range expressions {
+		e := e
+		items = append(items, item{start: startOf(e), print: func() { p.expression(e) }})
+	}
+	p.list(openToken, open, close, items)
+}
+
+// precedence returns how tightly e holds together, in terms of the parser's
+// precedences.
+func precedence(e ast.Expression) int {
+	switch e := e.(type) {
+	case *ast.InfixExpression:
+		return parser.Precedence(e.Token.Type)
+	case *ast.PrefixExpression, *ast.SpreadExpression:
+		return parser.PREFIX
+	case *ast.CallExpression:
+		return parser.CALL
+	case *ast.IndexExpression, *ast.SliceExpression, *ast.MemberExpression:
+		return parser.INDEX
+	default:
+		return parser.INDEX + 1
+	}
+}
+
+// operand prints e, in parentheses if it binds less tightly than min.
+func (p *printer) operand(e ast.Expression, min int) {
+	if precedence(e) < min {
+		p.write("(")
+		p.expression(e)
+		p.write(")")
+		return
+	}
+	p.expression(e)
+}
+
+func (p *printer) expression(e ast.Expression) {
+	switch e := e.(type) {
+	case *ast.Identifier:
+		p.write(e.Value)
+	case *ast.Boolean:
+		p.write(e.Token.Literal)
+	case *ast.Null:
+		p.write(e.Token.Literal)
+	case *ast.IntegerLiteral:
+		p.write(e.Token.Literal)
+	case *ast.StringLiteral:
+		p.write(p.raw[e.Token.Offset])
+	case *ast.TemplateLiteral:
+		p.write(p.raw[e.Token.Offset])
+
+	case *ast.PrefixExpression:
+		p.write(e.Operator)
+		p.operand(e.Right, parser.PREFIX)
+
+	case *ast.SpreadExpression:
+		p.write("...")
+		p.operand(e.Value, parser.PREFIX)
+
+	case *ast.InfixExpression:
+		// operators are left-associative, so an operand on the right
+		// with the same precedence needs parentheses
+		precedence := parser.Precedence(e.Token.Type)
+		p.operand(e.Left, precedence)
+		p.write(" " + e.Operator + " ")
+		p.operand(e.Right, precedence+1)
+
+	case *ast.IfExpression:
+		p.write("if (")
+		p.expression(e.Condition)
+		p.write(") ")
+		p.block(e.Consequence)
+		if e.Alternative != nil {
+			p.write(" else ")
+			p.block(e.Alternative)
+		}
+
+	case *ast.FunctionLiteral:
+		p.write("fn")
+		p.function(e)
+
+	case *ast.CallExpression:
+		p.operand(e.Function, parser.CALL)
+		p.expressionList(e.Token, "(", ")", e.Arguments)
+
+	case *ast.ArrayLiteral:
+		p.expressionList(e.Token, "[", "]", e.Elements)
+
+	case *ast.SetLiteral:
+		p.expressionList(e.Token, "#{", "}", e.Elements)
+
+	case *ast.HashLiteral:
+		items := []item{}
+		for _, key := range e.Keys {
+			key, value := key, e.Pairs[key]
+			items = append(items, item{start: startOf(key), print: func() {
+				p.expression(key)
+				p.write(": ")
+				p.expression(value)
+			}})
+		}
+		p.list(e.Token, "{", "}", items)
+
+	case *ast.IndexExpression:
+		p.operand(e.Left, parser.INDEX)
+		p.write("[")
+		p.expression(e.Index)
+		p.write("]")
+
+	case *ast.SliceExpression:
+		p.operand(e.Left, parser.INDEX)
+		p.write("[")
+		if e.Start != nil {
+			p.expression(e.Start)
+		}
+		p.write(":")
+		if e.End != nil {
+			p.expression(e.End)
+		}
+		p.write("]")
+
+	case *ast.MemberExpression:
+		p.operand(e.Object, parser.INDEX)
+		p.write("." + e.Property.Value)
+
+	case *ast.MatchExpression:
+		p.write("match (")
+		p.expression(e.Subject)
+		p.write(") ")
+
+		// the arms are between the braces after the parenthesized subject
+		open := p.tokenAfter(p.closing(p.tokenAfter(e.Token, 1)), 1)
+		items := []item{}
+		for _, arm := range e.Arms {
+			arm := arm
+			items = append(items, item{start: tokenOf(arm.Pattern), print: func() {
+				p.pattern(arm.Pattern)
+				if arm.Guard != nil {
+					p.write(" if ")
+					p.expression(arm.Guard)
+				}
+				p.write(" => ")
+				p.expression(arm.Body)
+			}})
+		}
+		p.list(open, "{ ", " }", items)
+	}
+}
+
+// function prints the parameters and body of a function.
+func (p *printer) function(fn *ast.FunctionLiteral) {
+	p.write("(")
+	for i, param := range fn.Parameters {
+		if i > 0 {
+			p.write(", ")
+		}
+		p.write(param.Value)
+		if def, ok := fn.Defaults[param.Value]; ok {
+			p.write(" = ")
+			p.expression(def)
+		}
+	}
+	if fn.Rest != nil {
+		if len(fn.Parameters) > 0 {
+			p.write(", ")
+		}
+		p.write("..." + fn.Rest.Value)
+	}
+	p.write(") ")
+
+	p.block(fn.Body)
+}
+
+func (p *printer) pattern(pattern ast.Pattern) {
+	switch pattern := pattern.(type) {
+	case *ast.WildcardPattern:
+		p.write("_")
+	case *ast.BindingPattern:
+		p.write(pattern.Name.Value)
+	case *ast.LiteralPattern:
+		p.expression(pattern.Value)
+
+	case *ast.ArrayPattern:
+		p.write("[")
+		for i, element := range pattern.Elements {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.pattern(element)
+		}
+		if pattern.Rest != nil {
+			if len(pattern.Elements) > 0 {
+				p.write(", ")
+			}
+			p.write("..." + pattern.Rest.Value)
+		}
+		p.write("]")
+
+	case *ast.HashPattern:
+		p.write("{")
+		for i, key := range pattern.Keys {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.expression(key)
+			p.write(": ")
+			p.pattern(pattern.Values[i])
+		}
+		p.write("}")
+	}
+}
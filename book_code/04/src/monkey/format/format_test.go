@@ -0,0 +1,238 @@
+package format
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x=1+2*3", "let x = 1 + 2 * 3;\n"},
+		{"let x = (1 + 2) * 3;", "let x = (1 + 2) * 3;\n"},
+		{"let x = (1 * 2) + 3;", "let x = 1 * 2 + 3;\n"},
+		{"a - (b - c); (a - b) - c;", "a - (b - c);\na - b - c;\n"},
+		{"-(a + b); (-a)[0]; !(a == b)", "-(a + b);\n(-a)[0];\n!(a == b);\n"},
+		{"(a ?? b) || (c && d); a ?? (b || c)", "(a ?? b) || c && d;\na ?? b || c;\n"},
+		{"((f))(x)(y)", "f(x)(y);\n"},
+		{"a[1:]; a[:2]; a[1:2]; p.x", "a[1:];\na[:2];\na[1:2];\np.x;\n"},
+		{"x in #{1,2}", "x in #{1, 2};\n"},
+		{"return   x", "return x;\n"},
+		{"", ""},
+		{
+			"let add = fn(a,b){a+b};",
+			"let add = fn(a, b) { a + b };\n",
+		},
+		{
+			"let f = fn(a, b = 2, ...rest) {}",
+			"let f = fn(a, b = 2, ...rest) {};\n",
+		},
+		{
+			"let f = fn(x) {\nlet y = x;\ny\n}",
+			"let f = fn(x) {\n  let y = x;\n  y;\n};\n",
+		},
+		{
+			"if (x) { 1 } else { 2 }\nputs(x)",
+			"if (x) { 1 } else { 2 }\nputs(x);\n",
+		},
+		{
+			"if (x) { 1 };\n(y)",
+			"if (x) { 1 };\ny;\n",
+		},
+		{
+			"if (x) { 1 };\n[1]",
+			"if (x) { 1 };\n[1];\n",
+		},
+		{
+			"let a = [1,\n2]",
+			"let a = [\n  1,\n  2,\n];\n",
+		},
+		{
+			"let h = {\"a\": 1,\n\"b\": 2,}",
+			"let h = {\n  \"a\": 1,\n  \"b\": 2,\n};\n",
+		},
+		{
+			"let h = {\"a\": 1, \"b\": 2,}",
+			"let h = {\"a\": 1, \"b\": 2};\n",
+		},
+		{
+			"f(1,\n  g(2, 3))",
+			"f(\n  1,\n  g(2, 3),\n);\n",
+		},
+		{
+			"let s = \"a\\\"b${ x }\"; let r = `raw\\n`;",
+			"let s = \"a\\\"b${ x }\";\nlet r = `raw\\n`;\n",
+		},
+		{
+			"match (x) { 1 => \"one\", [a, ...rest] if a > 0 => a, {\"k\": -1} => 0, _ => null }",
+			"match (x) { 1 => \"one\", [a, ...rest] if a > 0 => a, {\"k\": -1} => 0, _ => null }\n",
+		},
+		{
+			"match (x) {\n1 => \"one\",\n_ => null\n}",
+			"match (x) {\n  1 => \"one\",\n  _ => null,\n}\n",
+		},
+		{
+			"struct Point {x,y}",
+			"struct Point { x, y }\n",
+		},
+		{
+			"struct Vec { x, y; fn len() { x * x + y * y } }",
+			"struct Vec {\n  x, y;\n  fn len() { x * x + y * y }\n}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		testSource(t, tt.input, tt.expected)
+	}
+}
+
+func TestComments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"// only a comment", "// only a comment\n"},
+		{"let x = 1;   // one\n", "let x = 1; // one\n"},
+		{
+			"// leading\nlet x = 1;\n// trailing\n",
+			"// leading\nlet x = 1;\n// trailing\n",
+		},
+		{
+			"let f = fn() {\n  // inside\n  1\n};",
+			"let f = fn() {\n  // inside\n  1;\n};\n",
+		},
+		{
+			"let f = fn() { 1 // after\n};",
+			"let f = fn() {\n  1; // after\n};\n",
+		},
+		{
+			"let f = fn() {\n  1;\n  // at the end\n};",
+			"let f = fn() {\n  1;\n  // at the end\n};\n",
+		},
+		{
+			"let a = [\n1, // one\n// two\n2\n];",
+			"let a = [\n  1, // one\n  // two\n  2,\n];\n",
+		},
+	}
+
+	for _, tt := range tests {
+		testSource(t, tt.input, tt.expected)
+	}
+}
+
+func TestBlankLines(t *testing.T) {
+	input := `let a = 1;
+
+
+
+let b = 2;
+let c = 3;
+
+// about d
+
+let d = 4;
+`
+	expected := `let a = 1;
+
+let b = 2;
+let c = 3;
+
+// about d
+
+let d = 4;
+`
+	testSource(t, input, expected)
+}
+
+func TestIdempotent(t *testing.T) {
+	input := `
+// A program.
+let add = fn(a,b){a+b}   // adds
+let big = [1,2,
+  3];
+let hm = {
+  "one": 1, // the first
+  // leading
+  "two": 2
+};
+if (x > 1) { puts("big") } else { puts("small") };
+[1, 2];
+let v = a - (b - c) + (a * b) / (c / d);
+struct Vec {
+  x, y;
+  fn scale(k) {
+    Vec(x * k, y * k)
+  }
+}
+`
+	once, err := Source([]byte(input))
+	if err != nil {
+		t.Fatalf("Source failed: %s", err)
+	}
+	twice, err := Source(once)
+	if err != nil {
+		t.Fatalf("Source failed on its own output: %s\n%s", err, once)
+	}
+	if string(once) != string(twice) {
+		t.Errorf("formatting is not idempotent. first=\n%s\nsecond=\n%s", once, twice)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	_, err := Source([]byte("let = 1;"))
+	pe, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("err is not *ParseError. got=%T (%v)", err, err)
+	}
+	if len(pe.Messages) == 0 {
+		t.Errorf("ParseError has no messages")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\n"
+	b := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\n"
+
+	expected := `--- old
++++ new
+@@ -1,5 +1,5 @@
+ a
+-b
++B
+ c
+ d
+ e
+@@ -9,3 +9,4 @@
+ i
+ j
+ k
++l
+`
+	if diff := string(Diff("old", "new", []byte(a), []byte(b))); diff != expected {
+		t.Errorf("wrong diff. expected=\n%s\ngot=\n%s", expected, diff)
+	}
+
+	if diff := Diff("old", "new", []byte(a), []byte(a)); diff != nil {
+		t.Errorf("expected no diff for equal input. got=\n%s", diff)
+	}
+
+	diff := string(Diff("old", "new", []byte("x"), []byte("x\n")))
+	if !strings.Contains(diff, "\\ No newline at end of file") {
+		t.Errorf("missing no-newline marker. got=\n%s", diff)
+	}
+}
+
+func testSource(t *testing.T, input, expected string) {
+	t.Helper()
+
+	actual, err := Source([]byte(input))
+	if err != nil {
+		t.Errorf("Source(%q) failed: %s", input, err)
+		return
+	}
+	if string(actual) != expected {
+		t.Errorf("Source(%q) wrong. expected=\n%s\ngot=\n%s", input, expected, actual)
+	}
+}
@@ -0,0 +1,503 @@
+// Package kernel runs Monkey as a Jupyter kernel. Each kernel evaluates the
+// cells of its notebook in one environment, so bindings carry over from
+// cell to cell as they do in the REPL.
+package kernel
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/repl"
+	"monkey/token"
+	"monkey/zmtp"
+	"net"
+	"os"
+	"strings"
+	"sync"
+)
+
+const VERSION = "0.1.0"
+
+// ConnectionInfo is the connection file Jupyter starts a kernel with. Ports
+// left at 0 are picked by the kernel.
+type ConnectionInfo struct {
+	Transport       string `json:"transport"`
+	IP              string `json:"ip"`
+	ShellPort       int    `json:"shell_port"`
+	ControlPort     int    `json:"control_port"`
+	StdinPort       int    `json:"stdin_port"`
+	IOPubPort       int    `json:"iopub_port"`
+	HBPort          int    `json:"hb_port"`
+	Key             string `json:"key"`
+	SignatureScheme string `json:"signature_scheme"`
+}
+
+func ReadConnectionInfo(path string) (ConnectionInfo, error) {
+	var info ConnectionInfo
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return info, err
+	}
+	if err := json.Unmarshal(content, &info); err != nil {
+		return info, fmt.Errorf("%s: %s", path, err)
+	}
+
+	return info, nil
+}
+
+type Kernel struct {
+	// Info is the connection info with the ports the kernel is bound to.
+	Info ConnectionInfo
+
+	signer  signer
+	session string
+
+	shell     *zmtp.Socket
+	control   *zmtp.Socket
+	stdin     *zmtp.Socket
+	iopub     *zmtp.Socket
+	heartbeat *zmtp.Socket
+
+	// running is held while a request uses the environment, so cells and
+	// completions take turns. Requests that do not, such as those on the
+	// control socket, are answered while a cell runs.
+	running        sync.Mutex
+	context        *evaluator.Context // env's, with the streams of the cell being run
+	env            *object.Environment
+	executionCount int
+
+	mu        sync.Mutex    // guards interrupt
+	interrupt chan struct{} // closed to interrupt the cell being run
+
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// New binds the sockets of a kernel as info describes.
+func New(info ConnectionInfo) (*Kernel, error) {
+	if info.Transport != "" && info.Transport != "tcp" {
+		return nil, fmt.Errorf("unsupported transport %s", info.Transport)
+	}
+	if info.SignatureScheme != "" && info.SignatureScheme != "hmac-sha256" {
+		return nil, fmt.Errorf("unsupported signature scheme %s", info.SignatureScheme)
+	}
+
+	k := &Kernel{
+		Info:    info,
+		signer:  signer{key: []byte(info.Key)},
+		session: newID(),
+		context: &evaluator.Context{},
+		done:    make(chan struct{}),
+	}
+	k.env = evaluator.NewEnvironment(k.context)
+
+	sockets := []struct {
+		socket     **zmtp.Socket
+		socketType zmtp.SocketType
+		port       *int
+	}{
+		{&k.shell, zmtp.ROUTER, &k.Info.ShellPort},
+		{&k.control, zmtp.ROUTER, &k.Info.ControlPort},
+		{&k.stdin, zmtp.ROUTER, &k.Info.StdinPort},
+		{&k.iopub, zmtp.PUB, &k.Info.IOPubPort},
+		{&k.heartbeat, zmtp.REP, &k.Info.HBPort},
+	}
+	for _, s := range sockets {
+		socket, err := zmtp.Listen(s.socketType, fmt.Sprintf("tcp://%s:%d", info.IP, *s.port))
+		if err != nil {
+			k.Close()
+			return nil, err
+		}
+		*s.socket = socket
+		*s.port = socket.Addr().(*net.TCPAddr).Port
+	}
+
+	return k, nil
+}
+
+// Run serves requests until a shutdown request arrives or the kernel is
+// closed.
+func (k *Kernel) Run() {
+	go k.beat()
+	go k.serve(k.control)
+	go k.serve(k.shell)
+
+	<-k.done
+	k.Close()
+}
+
+// Close unbinds the sockets, which ends Run.
+func (k *Kernel) Close() {
+	k.stopOnce.Do(func() { close(k.done) })
+
+	for _, socket := range []*zmtp.Socket{k.shell, k.control, k.stdin, k.iopub, k.heartbeat} {
+		if socket != nil {
+			socket.Close()
+		}
+	}
+}
+
+// beat echoes heartbeats so the frontend knows the kernel is alive.
+func (k *Kernel) beat() {
+	for {
+		msg, err := k.heartbeat.Recv()
+		if err != nil {
+			return
+		}
+		k.heartbeat.Send(msg)
+	}
+}
+
+func (k *Kernel) serve(socket *zmtp.Socket) {
+	for {
+		frames, err := socket.Recv()
+		if err != nil {
+			return
+		}
+
+		request, err := k.signer.decode(frames)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "monkey-kernel: dropping message: %s\n", err)
+			continue
+		}
+
+		k.handle(socket, request)
+	}
+}
+
+func (k *Kernel) handle(socket *zmtp.Socket, request *Message) {
+	k.publish(request, "status", map[string]interface{}{"execution_state": "busy"})
+	defer k.publish(request, "status", map[string]interface{}{"execution_state": "idle"})
+
+	switch request.Header.MsgType {
+	case "kernel_info_request":
+		k.reply(socket, request, "kernel_info_reply", kernelInfo())
+	case "execute_request":
+		k.execute(socket, request)
+	case "complete_request":
+		k.complete(socket, request)
+	case "inspect_request":
+		k.inspect(socket, request)
+	case "interrupt_request":
+		k.interruptExecution(socket, request)
+	case "shutdown_request":
+		k.shutdown(socket, request)
+	default:
+		fmt.Fprintf(os.Stderr, "monkey-kernel: unsupported message type %s\n",
+			request.Header.MsgType)
+	}
+}
+
+// send sends a message of type msgType in reply to parent.
+func (k *Kernel) send(socket *zmtp.Socket, identities [][]byte, parent *Message, msgType string, content interface{}) {
+	contentJSON, err := json.Marshal(content)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "monkey-kernel: %s\n", err)
+		return
+	}
+
+	msg := &Message{
+		Identities: identities,
+		Header: Header{
+			MsgID:    newID(),
+			Session:  k.session,
+			Username: "kernel",
+			Date:     now(),
+			MsgType:  msgType,
+			Version:  PROTOCOL_VERSION,
+		},
+		Parent:  parent.Header,
+		Content: contentJSON,
+	}
+
+	frames, err := k.signer.encode(msg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "monkey-kernel: %s\n", err)
+		return
+	}
+	socket.Send(frames)
+}
+
+func (k *Kernel) reply(socket *zmtp.Socket, request *Message, msgType string, content interface{}) {
+	k.send(socket, request.Identities, request, msgType, content)
+}
+
+// publish broadcasts a message on the IOPub socket, with its type as the
+// topic.
+func (k *Kernel) publish(parent *Message, msgType string, content interface{}) {
+	k.send(k.iopub, [][]byte{[]byte(msgType)}, parent, msgType, content)
+}
+
+func kernelInfo() map[string]interface{} {
+	return map[string]interface{}{
+		"status":                 "ok",
+		"protocol_version":       PROTOCOL_VERSION,
+		"implementation":         "monkey",
+		"implementation_version": VERSION,
+		"language_info": map[string]interface{}{
+			"name":           "monkey",
+			"version":        VERSION,
+			"mimetype":       "text/x-monkey",
+			"file_extension": ".code",
+		},
+		"banner":     "Monkey " + VERSION,
+		"help_links": []interface{}{},
+	}
+}
+
+type executeRequest struct {
+	Code         string `json:"code"`
+	Silent       bool   `json:"silent"`
+	StoreHistory bool   `json:"store_history"`
+}
+
+// executionError describes a failed execution, for error messages and
+// replies.
+type executionError struct {
+	Name      string   `json:"ename"`
+	Value     string   `json:"evalue"`
+	Traceback []string `json:"traceback"`
+}
+
+func (k *Kernel) execute(socket *zmtp.Socket, request *Message) {
+	content := executeRequest{StoreHistory: true}
+	if err := json.Unmarshal(request.Content, &content); err != nil {
+		fmt.Fprintf(os.Stderr, "monkey-kernel: malformed execute_request: %s\n", err)
+		return
+	}
+
+	k.running.Lock()
+	defer k.running.Unlock()
+
+	// the cell can be interrupted from the moment its input is published
+	interrupt := make(chan struct{})
+	k.mu.Lock()
+	k.interrupt = interrupt
+	k.mu.Unlock()
+	defer func() {
+		k.mu.Lock()
+		k.interrupt = nil
+		k.mu.Unlock()
+	}()
+
+	if !content.Silent && content.StoreHistory {
+		k.executionCount += 1
+	}
+	if !content.Silent {
+		k.publish(request, "execute_input", map[string]interface{}{
+			"code":            content.Code,
+			"execution_count": k.executionCount,
+		})
+	}
+
+	var stdout io.Writer = ioutil.Discard
+	if !content.Silent {
+		stdout = &streamWriter{kernel: k, parent: request, name: "stdout"}
+	}
+
+	result, failure := k.evaluate(content.Code, stdout, interrupt)
+	if failure != nil {
+		k.publish(request, "error", failure)
+		k.reply(socket, request, "execute_reply", map[string]interface{}{
+			"status":          "error",
+			"execution_count": k.executionCount,
+			"ename":           failure.Name,
+			"evalue":          failure.Value,
+			"traceback":       failure.Traceback,
+		})
+		return
+	}
+
+	if result != nil && !content.Silent {
+		k.publish(request, "execute_result", map[string]interface{}{
+			"execution_count": k.executionCount,
+			"data":            map[string]interface{}{"text/plain": result.Inspect()},
+			"metadata":        map[string]interface{}{},
+		})
+	}
+
+	k.reply(socket, request, "execute_reply", map[string]interface{}{
+		"status":           "ok",
+		"execution_count":  k.executionCount,
+		"user_expressions": map[string]interface{}{},
+		"payload":          []interface{}{},
+	})
+}
+
+// evaluate runs code in the kernel's environment with puts writing to
+// stdout, until it is done or interrupt is closed.
+func (k *Kernel) evaluate(
+	code string,
+	stdout io.Writer,
+	interrupt <-chan struct{},
+) (object.Object, *executionError) {
+	p := parser.New(lexer.New(code))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, &executionError{
+			Name:      "ParseError",
+			Value:     strings.Join(p.Errors(), "\n"),
+			Traceback: p.Errors(),
+		}
+	}
+
+	k.context.Stdout = stdout
+	k.context.Interrupt = interrupt
+
+	result := evaluator.Eval(program, k.env)
+	if errObj, ok := result.(*object.Error); ok {
+		return nil, &executionError{
+			Name:      "RuntimeError",
+			Value:     errObj.Message,
+			Traceback: []string{errObj.Inspect()},
+		}
+	}
+
+	return result, nil
+}
+
+// streamWriter publishes what is written to it as stream messages.
+type streamWriter struct {
+	kernel *Kernel
+	parent *Message
+	name   string
+}
+
+func (sw *streamWriter) Write(p []byte) (int, error) {
+	sw.kernel.publish(sw.parent, "stream", map[string]interface{}{
+		"name": sw.name,
+		"text": string(p),
+	})
+	return len(p), nil
+}
+
+type cursorRequest struct {
+	Code      string `json:"code"`
+	CursorPos int    `json:"cursor_pos"` // in characters
+}
+
+func (k *Kernel) decodeCursorRequest(request *Message) ([]rune, int, error) {
+	var content cursorRequest
+	if err := json.Unmarshal(request.Content, &content); err != nil {
+		return nil, 0, err
+	}
+
+	code := []rune(content.Code)
+	if content.CursorPos < 0 || content.CursorPos > len(code) {
+		return nil, 0, errors.New("cursor_pos out of range")
+	}
+	return code, content.CursorPos, nil
+}
+
+func isIdentifierRune(r rune) bool {
+	return 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || r == '_'
+}
+
+func (k *Kernel) complete(socket *zmtp.Socket, request *Message) {
+	code, cursor, err := k.decodeCursorRequest(request)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "monkey-kernel: malformed complete_request: %s\n", err)
+		return
+	}
+
+	k.running.Lock()
+	defer k.running.Unlock()
+
+	start := cursor
+	for start > 0 && isIdentifierRune(code[start-1]) {
+		start -= 1
+	}
+
+	matches := repl.Completions(k.env, string(code[start:cursor]))
+	if matches == nil {
+		matches = []string{}
+	}
+
+	k.reply(socket, request, "complete_reply", map[string]interface{}{
+		"status":       "ok",
+		"matches":      matches,
+		"cursor_start": start,
+		"cursor_end":   cursor,
+		"metadata":     map[string]interface{}{},
+	})
+}
+
+// inspect describes the name under the cursor: the type and value it is
+// bound to, or whether it is a builtin or keyword.
+func (k *Kernel) inspect(socket *zmtp.Socket, request *Message) {
+	code, cursor, err := k.decodeCursorRequest(request)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "monkey-kernel: malformed inspect_request: %s\n", err)
+		return
+	}
+
+	k.running.Lock()
+	defer k.running.Unlock()
+
+	start, end := cursor, cursor
+	for start > 0 && isIdentifierRune(code[start-1]) {
+		start -= 1
+	}
+	for end < len(code) && isIdentifierRune(code[end]) {
+		end += 1
+	}
+	name := string(code[start:end])
+
+	description := ""
+	if value, ok := k.env.Get(name); ok {
+		description = fmt.Sprintf("%s: %s\n%s", name, value.Type(), value.Inspect())
+	} else if name != "" && token.LookupIdent(name) != token.IDENT {
+		description = name + ": keyword"
+	} else {
+		for _, builtin := range evaluator.BuiltinNames() {
+			if builtin == name {
+				description = name + ": builtin function"
+			}
+		}
+	}
+
+	data := map[string]interface{}{}
+	if description != "" {
+		data["text/plain"] = description
+	}
+
+	k.reply(socket, request, "inspect_reply", map[string]interface{}{
+		"status":   "ok",
+		"found":    description != "",
+		"data":     data,
+		"metadata": map[string]interface{}{},
+	})
+}
+
+// interruptExecution stops the cell being run, if there is one, before its
+// next statement.
+func (k *Kernel) interruptExecution(socket *zmtp.Socket, request *Message) {
+	k.mu.Lock()
+	if k.interrupt != nil {
+		close(k.interrupt)
+		k.interrupt = nil
+	}
+	k.mu.Unlock()
+
+	k.reply(socket, request, "interrupt_reply", map[string]interface{}{"status": "ok"})
+}
+
+func (k *Kernel) shutdown(socket *zmtp.Socket, request *Message) {
+	var content struct {
+		Restart bool `json:"restart"`
+	}
+	json.Unmarshal(request.Content, &content)
+
+	k.reply(socket, request, "shutdown_reply", map[string]interface{}{
+		"status":  "ok",
+		"restart": content.Restart,
+	})
+	k.stopOnce.Do(func() { close(k.done) })
+}
@@ -0,0 +1,490 @@
+package kernel
+
+import (
+	"encoding/json"
+	"fmt"
+	"monkey/zmtp"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeClient talks to a kernel the way a notebook frontend does.
+type fakeClient struct {
+	t       *testing.T
+	signer  signer
+	shell   *zmtp.Conn
+	control *zmtp.Conn
+	iopub   *zmtp.Conn
+	hb      *zmtp.Conn
+
+	published chan *Message
+}
+
+func startKernel(t *testing.T) (*Kernel, *fakeClient) {
+	k, err := New(ConnectionInfo{
+		Transport:       "tcp",
+		IP:              "127.0.0.1",
+		Key:             "secret",
+		SignatureScheme: "hmac-sha256",
+	})
+	if err != nil {
+		t.Fatalf("New failed: %s", err)
+	}
+	go k.Run()
+
+	address := func(port int) string { return fmt.Sprintf("tcp://127.0.0.1:%d", port) }
+	dial := func(port int, socketType zmtp.SocketType) *zmtp.Conn {
+		c, err := zmtp.Dial(address(port), socketType)
+		if err != nil {
+			t.Fatalf("Dial failed: %s", err)
+		}
+		return c
+	}
+
+	c := &fakeClient{
+		t:         t,
+		signer:    signer{key: []byte("secret")},
+		shell:     dial(k.Info.ShellPort, zmtp.DEALER),
+		control:   dial(k.Info.ControlPort, zmtp.DEALER),
+		iopub:     dial(k.Info.IOPubPort, zmtp.SUB),
+		hb:        dial(k.Info.HBPort, zmtp.REQ),
+		published: make(chan *Message, 100),
+	}
+	c.iopub.Send(zmtp.Message{[]byte{1}})
+	go c.readIOPub()
+
+	// a subscription takes effect some time after it is sent, so ask for
+	// the kernel info until its status messages come through
+	for i := 0; ; i++ {
+		request := c.request(c.shell, "kernel_info_request", map[string]interface{}{})
+		c.reply(c.shell, request)
+
+		select {
+		case <-c.published:
+			c.outputs(request)
+			return k, c
+		case <-time.After(20 * time.Millisecond):
+		}
+		if i == 250 {
+			t.Fatal("no messages on IOPub")
+		}
+	}
+}
+
+func (c *fakeClient) close() {
+	for _, conn := range []*zmtp.Conn{c.shell, c.control, c.iopub, c.hb} {
+		conn.Close()
+	}
+}
+
+func (c *fakeClient) readIOPub() {
+	for {
+		frames, err := c.iopub.Recv()
+		if err != nil {
+			return
+		}
+		msg, err := c.signer.decode(frames)
+		if err != nil {
+			c.t.Errorf("bad IOPub message: %s", err)
+			continue
+		}
+		c.published <- msg
+	}
+}
+
+// request sends a request and returns its header.
+func (c *fakeClient) request(conn *zmtp.Conn, msgType string, content interface{}) Header {
+	contentJSON, err := json.Marshal(content)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+
+	header := Header{
+		MsgID:    newID(),
+		Session:  "test-session",
+		Username: "test",
+		Date:     now(),
+		MsgType:  msgType,
+		Version:  PROTOCOL_VERSION,
+	}
+	frames, err := c.signer.encode(&Message{Header: header, Content: contentJSON})
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if err := conn.Send(frames); err != nil {
+		c.t.Fatal(err)
+	}
+
+	return header
+}
+
+// reply waits for the reply to request.
+func (c *fakeClient) reply(conn *zmtp.Conn, request Header) map[string]interface{} {
+	for {
+		frames, err := conn.Recv()
+		if err != nil {
+			c.t.Fatalf("no reply to %s: %s", request.MsgType, err)
+		}
+		msg, err := c.signer.decode(frames)
+		if err != nil {
+			c.t.Fatalf("bad reply: %s", err)
+		}
+		if msg.Parent.MsgID != request.MsgID {
+			continue
+		}
+		if msg.Header.Session == "" || msg.Header.Version != PROTOCOL_VERSION {
+			c.t.Errorf("bad reply header: %+v", msg.Header)
+		}
+
+		return decodeContent(c.t, msg)
+	}
+}
+
+// outputs waits until the kernel is idle after request and returns the
+// IOPub messages for it, other than the status messages.
+func (c *fakeClient) outputs(request Header) []*Message {
+	outputs := []*Message{}
+	for {
+		var msg *Message
+		select {
+		case msg = <-c.published:
+		case <-time.After(5 * time.Second):
+			c.t.Fatalf("kernel did not get idle after %s", request.MsgType)
+		}
+		if msg.Parent.MsgID != request.MsgID {
+			continue
+		}
+
+		if msg.Header.MsgType == "status" {
+			if decodeContent(c.t, msg)["execution_state"] == "idle" {
+				return outputs
+			}
+			continue
+		}
+		outputs = append(outputs, msg)
+	}
+}
+
+func (c *fakeClient) execute(code string) (map[string]interface{}, []*Message) {
+	request := c.request(c.shell, "execute_request", map[string]interface{}{
+		"code":   code,
+		"silent": false,
+	})
+	reply := c.reply(c.shell, request)
+	return reply, c.outputs(request)
+}
+
+func decodeContent(t *testing.T, msg *Message) map[string]interface{} {
+	var content map[string]interface{}
+	if err := json.Unmarshal(msg.Content, &content); err != nil {
+		t.Fatalf("bad content %s: %s", msg.Content, err)
+	}
+	return content
+}
+
+// summarize turns IOPub messages into "type: detail" strings.
+func summarize(t *testing.T, messages []*Message) []string {
+	summaries := []string{}
+	for _, msg := range messages {
+		content := decodeContent(t, msg)
+
+		detail := ""
+		switch msg.Header.MsgType {
+		case "execute_input":
+			detail = fmt.Sprintf("%v %v", content["execution_count"], content["code"])
+		case "stream":
+			detail = fmt.Sprintf("%v %q", content["name"], content["text"])
+		case "execute_result":
+			data := content["data"].(map[string]interface{})
+			detail = fmt.Sprintf("%v %v", content["execution_count"], data["text/plain"])
+		case "error":
+			detail = fmt.Sprintf("%v %v %v", content["ename"], content["evalue"], content["traceback"])
+		}
+		summaries = append(summaries, msg.Header.MsgType+": "+detail)
+	}
+	return summaries
+}
+
+func TestKernelInfo(t *testing.T) {
+	k, c := startKernel(t)
+	defer k.Close()
+	defer c.close()
+
+	for _, conn := range []*zmtp.Conn{c.shell, c.control} {
+		reply := c.reply(conn, c.request(conn, "kernel_info_request", map[string]interface{}{}))
+
+		if reply["status"] != "ok" || reply["protocol_version"] != PROTOCOL_VERSION ||
+			reply["implementation"] != "monkey" {
+			t.Errorf("wrong kernel info: %v", reply)
+		}
+		languageInfo := reply["language_info"].(map[string]interface{})
+		if languageInfo["name"] != "monkey" || languageInfo["file_extension"] != ".code" {
+			t.Errorf("wrong language info: %v", languageInfo)
+		}
+	}
+}
+
+func TestExecute(t *testing.T) {
+	k, c := startKernel(t)
+	defer k.Close()
+	defer c.close()
+
+	tests := []struct {
+		code          string
+		expectedReply map[string]interface{}
+		expected      []string
+	}{
+		{
+			`let greeting = "hello"; puts(greeting); puts(1, 2);`,
+			map[string]interface{}{"status": "ok", "execution_count": 1.0},
+			[]string{
+				`execute_input: 1 let greeting = "hello"; puts(greeting); puts(1, 2);`,
+				`stream: stdout "hello\n"`,
+				`stream: stdout "1\n"`,
+				`stream: stdout "2\n"`,
+				`execute_result: 1 null`,
+			},
+		},
+		{
+			`len(greeting) * 2`,
+			map[string]interface{}{"status": "ok", "execution_count": 2.0},
+			[]string{
+				`execute_input: 2 len(greeting) * 2`,
+				`execute_result: 2 10`,
+			},
+		},
+		{
+			`let f = fn(x) { x };`,
+			map[string]interface{}{"status": "ok", "execution_count": 3.0},
+			[]string{`execute_input: 3 let f = fn(x) { x };`},
+		},
+		{
+			`f(1) + true`,
+			map[string]interface{}{
+				"status":          "error",
+				"execution_count": 4.0,
+				"ename":           "RuntimeError",
+				"evalue":          "type mismatch: INTEGER + BOOLEAN",
+			},
+			[]string{
+				`execute_input: 4 f(1) + true`,
+				`error: RuntimeError type mismatch: INTEGER + BOOLEAN [ERROR: type mismatch: INTEGER + BOOLEAN]`,
+			},
+		},
+		{
+			`let x = ;`,
+			map[string]interface{}{
+				"status":          "error",
+				"execution_count": 5.0,
+				"ename":           "ParseError",
+			},
+			[]string{
+				`execute_input: 5 let x = ;`,
+				`error: ParseError no prefix parse function for ; found [no prefix parse function for ; found]`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		reply, outputs := c.execute(tt.code)
+
+		for key, expected := range tt.expectedReply {
+			if !reflect.DeepEqual(reply[key], expected) {
+				t.Errorf("%s: reply field %s wrong. expected=%v, got=%v",
+					tt.code, key, expected, reply[key])
+			}
+		}
+
+		actual := summarize(t, outputs)
+		if !reflect.DeepEqual(actual, tt.expected) {
+			t.Errorf("%s: wrong outputs.\nexpected=%q\ngot=     %q", tt.code, tt.expected, actual)
+		}
+	}
+}
+
+func TestSilentExecute(t *testing.T) {
+	k, c := startKernel(t)
+	defer k.Close()
+	defer c.close()
+
+	request := c.request(c.shell, "execute_request", map[string]interface{}{
+		"code":   `let hidden = 1; puts("not shown"); hidden`,
+		"silent": true,
+	})
+	reply := c.reply(c.shell, request)
+	if reply["status"] != "ok" || reply["execution_count"] != 0.0 {
+		t.Errorf("wrong reply: %v", reply)
+	}
+	if outputs := summarize(t, c.outputs(request)); len(outputs) != 0 {
+		t.Errorf("silent execution published %q", outputs)
+	}
+
+	_, outputs := c.execute("hidden + 1")
+	expected := []string{"execute_input: 1 hidden + 1", "execute_result: 1 2"}
+	if actual := summarize(t, outputs); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("wrong outputs.\nexpected=%q\ngot=     %q", expected, actual)
+	}
+}
+
+func TestInterrupt(t *testing.T) {
+	k, c := startKernel(t)
+	defer k.Close()
+	defer c.close()
+
+	// a cell that runs for a long time unless it is stopped
+	request := c.request(c.shell, "execute_request", map[string]interface{}{
+		"code": `let loop = fn(n) { if (n > 0) { loop(n - 1) } else { 0 } };
+map(range(1000000), fn(x) { loop(20) })`,
+		"silent": false,
+	})
+	for started := false; !started; {
+		select {
+		case msg := <-c.published:
+			started = msg.Parent.MsgID == request.MsgID && msg.Header.MsgType == "execute_input"
+		case <-time.After(5 * time.Second):
+			t.Fatal("the cell did not start")
+		}
+	}
+
+	// the control socket is answered while the cell runs
+	reply := c.reply(c.control, c.request(c.control, "interrupt_request", map[string]interface{}{}))
+	if reply["status"] != "ok" {
+		t.Errorf("wrong interrupt reply: %v", reply)
+	}
+
+	reply = c.reply(c.shell, request)
+	if reply["status"] != "error" || reply["ename"] != "RuntimeError" || reply["evalue"] != "interrupted" {
+		t.Errorf("expected the cell to be interrupted. got=%v", reply)
+	}
+
+	// the next cell runs as usual
+	if reply, _ := c.execute("loop(3)"); reply["status"] != "ok" {
+		t.Errorf("wrong reply after the interrupt: %v", reply)
+	}
+}
+
+func TestCompleteAndInspect(t *testing.T) {
+	k, c := startKernel(t)
+	defer k.Close()
+	defer c.close()
+
+	c.execute(`let length = 3; let lengths = [1, 2];`)
+
+	reply := c.reply(c.shell, c.request(c.shell, "complete_request", map[string]interface{}{
+		"code":       "puts(é, leng",
+		"cursor_pos": 12,
+	}))
+	expected := map[string]interface{}{
+		"status":       "ok",
+		"matches":      []interface{}{"length", "lengths"},
+		"cursor_start": 8.0,
+		"cursor_end":   12.0,
+		"metadata":     map[string]interface{}{},
+	}
+	if !reflect.DeepEqual(reply, expected) {
+		t.Errorf("wrong complete reply.\nexpected=%v\ngot=     %v", expected, reply)
+	}
+
+	tests := []struct {
+		code     string
+		cursor   int
+		expected interface{}
+	}{
+		{"lengths + 1", 3, "lengths: ARRAY\n[1, 2]"},
+		{"lengths + 1", 7, "lengths: ARRAY\n[1, 2]"},
+		{"len(x)", 1, "len: builtin function"},
+		{"let x", 2, "let: keyword"},
+		{"1 + unknown", 6, nil},
+		{"1 + 2", 1, nil},
+	}
+
+	for _, tt := range tests {
+		reply := c.reply(c.shell, c.request(c.shell, "inspect_request", map[string]interface{}{
+			"code":         tt.code,
+			"cursor_pos":   tt.cursor,
+			"detail_level": 0,
+		}))
+
+		data := reply["data"].(map[string]interface{})
+		if reply["found"] != (tt.expected != nil) || data["text/plain"] != tt.expected {
+			t.Errorf("inspect %q at %d: wrong reply %v", tt.code, tt.cursor, reply)
+		}
+	}
+}
+
+func TestBadSignatureIsIgnored(t *testing.T) {
+	k, c := startKernel(t)
+	defer k.Close()
+	defer c.close()
+
+	forger := signer{key: []byte("wrong")}
+	frames, err := forger.encode(&Message{
+		Header:  Header{MsgID: "forged", MsgType: "execute_request"},
+		Content: json.RawMessage(`{"code": "let forged = 1;"}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.shell.Send(frames)
+
+	reply, _ := c.execute("forged")
+	if reply["status"] != "error" || reply["evalue"] != "identifier not found: forged" {
+		t.Errorf("forged request was executed: %v", reply)
+	}
+}
+
+func TestHeartbeat(t *testing.T) {
+	k, c := startKernel(t)
+	defer k.Close()
+	defer c.close()
+
+	c.hb.Send(zmtp.Message{[]byte{}, []byte("ping")})
+	msg, err := c.hb.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msg) != 2 || string(msg[1]) != "ping" {
+		t.Errorf("wrong heartbeat reply: %q", msg)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	k, err := New(ConnectionInfo{IP: "127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		k.Run()
+		close(stopped)
+	}()
+
+	control, err := zmtp.Dial(fmt.Sprintf("127.0.0.1:%d", k.Info.ControlPort), zmtp.DEALER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer control.Close()
+
+	c := &fakeClient{t: t}
+	reply := c.reply(control, c.request(control, "shutdown_request", map[string]interface{}{"restart": false}))
+	if reply["status"] != "ok" || reply["restart"] != false {
+		t.Errorf("wrong shutdown reply: %v", reply)
+	}
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("kernel did not stop")
+	}
+}
+
+func TestUnsupportedConnection(t *testing.T) {
+	if _, err := New(ConnectionInfo{Transport: "ipc"}); err == nil {
+		t.Errorf("expected an error for the ipc transport")
+	}
+	if _, err := New(ConnectionInfo{IP: "127.0.0.1", SignatureScheme: "hmac-md5"}); err == nil {
+		t.Errorf("expected an error for an unsupported signature scheme")
+	}
+}
@@ -0,0 +1,143 @@
+package kernel
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"monkey/zmtp"
+	"time"
+)
+
+const PROTOCOL_VERSION = "5.3"
+
+// delimiter separates the routing identities of a message from its parts.
+var delimiter = []byte("<IDS|MSG>")
+
+type Header struct {
+	MsgID    string `json:"msg_id"`
+	Session  string `json:"session"`
+	Username string `json:"username"`
+	Date     string `json:"date"`
+	MsgType  string `json:"msg_type"`
+	Version  string `json:"version"`
+}
+
+// Message is a message of the Jupyter messaging protocol.
+type Message struct {
+	Identities [][]byte // where the reply goes
+	Header     Header
+	Parent     Header // the zero Header if there is none
+	Metadata   map[string]interface{}
+	Content    json.RawMessage
+}
+
+// signer signs and checks messages with HMAC-SHA256. With an empty key
+// messages are not signed.
+type signer struct {
+	key []byte
+}
+
+func (s signer) sign(parts [][]byte) []byte {
+	if len(s.key) == 0 {
+		return []byte{}
+	}
+
+	mac := hmac.New(sha256.New, s.key)
+	for _, part := range parts {
+		mac.Write(part)
+	}
+
+	signature := make([]byte, hex.EncodedLen(mac.Size()))
+	hex.Encode(signature, mac.Sum(nil))
+	return signature
+}
+
+// decode parses a message received on a ROUTER socket, checking its
+// signature.
+func (s signer) decode(msg zmtp.Message) (*Message, error) {
+	split := -1
+	for i, frame := range msg {
+		if bytes.Equal(frame, delimiter) {
+			split = i
+			break
+		}
+	}
+	if split < 0 || len(msg) < split+6 {
+		return nil, errors.New("malformed message")
+	}
+
+	parts := msg[split+2 : split+6]
+	if !hmac.Equal(s.sign(parts), msg[split+1]) {
+		return nil, errors.New("invalid signature")
+	}
+
+	m := &Message{Identities: msg[:split]}
+	if err := json.Unmarshal(parts[0], &m.Header); err != nil {
+		return nil, fmt.Errorf("malformed header: %s", err)
+	}
+	if err := json.Unmarshal(parts[1], &m.Parent); err != nil {
+		return nil, fmt.Errorf("malformed parent header: %s", err)
+	}
+	if err := json.Unmarshal(parts[2], &m.Metadata); err != nil {
+		return nil, fmt.Errorf("malformed metadata: %s", err)
+	}
+	m.Content = parts[3]
+
+	return m, nil
+}
+
+// encode turns m into frames, signed.
+func (s signer) encode(m *Message) (zmtp.Message, error) {
+	header, err := json.Marshal(m.Header)
+	if err != nil {
+		return nil, err
+	}
+
+	parent := []byte("{}")
+	if m.Parent != (Header{}) {
+		if parent, err = json.Marshal(m.Parent); err != nil {
+			return nil, err
+		}
+	}
+
+	metadata := m.Metadata
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	content := []byte(m.Content)
+	if len(content) == 0 {
+		content = []byte("{}")
+	}
+
+	parts := [][]byte{header, parent, metadataJSON, content}
+
+	msg := zmtp.Message{}
+	msg = append(msg, m.Identities...)
+	msg = append(msg, delimiter, s.sign(parts))
+	msg = append(msg, parts...)
+	return msg, nil
+}
+
+// newID returns a random UUID for message and session ids.
+func newID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // RFC 4122 variant
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+func now() string {
+	return time.Now().UTC().Format(time.RFC3339Nano)
+}
@@ -1,32 +1,65 @@
 package lexer
 
-import "monkey/token"
+import (
+	"monkey/token"
+	"strings"
+	"unicode/utf8"
+)
 
 type Lexer struct {
 	input        string
 	position     int  // current position in input (points to current char)
 	readPosition int  // current reading position in input (after current char)
 	ch           byte // current char under examination
+	line         int  // line of the current char, starting at 1
+	column       int  // column of the current char, starting at 1
+	unterminated bool // whether the input ended inside a string
+	base         int  // offset of input in the source it was taken from
+
+	comments []token.Token // skipped like whitespace, but kept for tools
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	l := &Lexer{input: input, line: 1, column: 1}
 	l.readChar()
 	return l
 }
 
+// NextToken returns the next token, stamped with the line, column and byte
+// offset where it starts.
 func (l *Lexer) NextToken() token.Token {
-	var tok token.Token
-
 	l.skipWhitespace()
 
+	line, column, offset := l.line, l.column, l.position
+
+	tok := l.readToken()
+	tok.Line = line
+	tok.Column = column
+	tok.Offset = l.base + offset
+
+	return tok
+}
+
+// Comments returns the comments skipped so far, in source order.
+func (l *Lexer) Comments() []token.Token {
+	return l.comments
+}
+
+// Unterminated reports whether the input read so far ended inside a string
+// literal, so that more input could complete it.
+func (l *Lexer) Unterminated() bool {
+	return l.unterminated
+}
+
+func (l *Lexer) readToken() token.Token {
+	var tok token.Token
+
 	switch l.ch {
 	case '=':
 		if l.peekChar() == '=' {
-			ch := l.ch
-			l.readChar()
-			literal := string(ch) + string(l.ch)
-			tok = token.Token{Type: token.EQ, Literal: literal}
+			tok = l.readTwoCharToken(token.EQ)
+		} else if l.peekChar() == '>' {
+			tok = l.readTwoCharToken(token.ARROW)
 		} else {
 			tok = newToken(token.ASSIGN, l.ch)
 		}
@@ -36,13 +69,36 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.MINUS, l.ch)
 	case '!':
 		if l.peekChar() == '=' {
-			ch := l.ch
-			l.readChar()
-			literal := string(ch) + string(l.ch)
-			tok = token.Token{Type: token.NOT_EQ, Literal: literal}
+			tok = l.readTwoCharToken(token.NOT_EQ)
 		} else {
 			tok = newToken(token.BANG, l.ch)
 		}
+	case '&':
+		if l.peekChar() == '&' {
+			tok = l.readTwoCharToken(token.AND)
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
+	case '|':
+		if l.peekChar() == '|' {
+			tok = l.readTwoCharToken(token.OR)
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
+	case '?':
+		if l.peekChar() == '?' {
+			tok = l.readTwoCharToken(token.NULLISH)
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
+	case '.':
+		if strings.HasPrefix(l.input[l.position:], "...") {
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+		} else {
+			tok = newToken(token.DOT, l.ch)
+		}
 	case '/':
 		tok = newToken(token.SLASH, l.ch)
 	case '*':
@@ -61,13 +117,27 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.LBRACE, l.ch)
 	case '}':
 		tok = newToken(token.RBRACE, l.ch)
+	case '#':
+		if l.peekChar() == '{' {
+			tok = l.readTwoCharToken(token.SET_LBRACE)
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
 	case '(':
 		tok = newToken(token.LPAREN, l.ch)
 	case ')':
 		tok = newToken(token.RPAREN, l.ch)
 	case '"':
+		literal, isTemplate := l.readString()
+		if isTemplate {
+			tok.Type = token.TEMPLATE
+		} else {
+			tok.Type = token.STRING
+		}
+		tok.Literal = literal
+	case '`':
 		tok.Type = token.STRING
-		tok.Literal = l.readString()
+		tok.Literal = l.readRawString()
 	case '[':
 		tok = newToken(token.LBRACKET, l.ch)
 	case ']':
@@ -93,13 +163,49 @@ func (l *Lexer) NextToken() token.Token {
 	return tok
 }
 
+func (l *Lexer) readTwoCharToken(tokenType token.TokenType) token.Token {
+	ch := l.ch
+	l.readChar()
+	literal := string(ch) + string(l.ch)
+	return token.Token{Type: tokenType, Literal: literal}
+}
+
+// skipWhitespace skips whitespace and comments, recording the comments.
 func (l *Lexer) skipWhitespace() {
-	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
+	for {
+		switch {
+		case l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r':
+			l.readChar()
+		case l.ch == '/' && l.peekChar() == '/':
+			l.readComment()
+		default:
+			return
+		}
+	}
+}
+
+func (l *Lexer) readComment() {
+	comment := token.Token{
+		Type:   token.COMMENT,
+		Line:   l.line,
+		Column: l.column,
+		Offset: l.base + l.position,
+	}
+
+	position := l.position
+	for l.ch != '\n' && l.ch != 0 {
 		l.readChar()
 	}
+	comment.Literal = strings.TrimRight(l.input[position:l.position], "\r")
+
+	l.comments = append(l.comments, comment)
 }
 
 func (l *Lexer) readChar() {
+	if l.readPosition > 0 {
+		l.advancePosition()
+	}
+
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
@@ -109,6 +215,23 @@ func (l *Lexer) readChar() {
 	l.readPosition += 1
 }
 
+// advancePosition moves the line and column past the current char, counting
+// columns in characters and tab stops the same way the root lexer does.
+func (l *Lexer) advancePosition() {
+	switch l.ch {
+	case '\n':
+		l.line += 1
+		l.column = 1
+	case '\t':
+		l.column += 4 - (l.column % 4)
+	case '\r':
+	default:
+		if l.readPosition >= len(l.input) || utf8.RuneStart(l.input[l.readPosition]) {
+			l.column += 1
+		}
+	}
+}
+
 func (l *Lexer) peekChar() byte {
 	if l.readPosition >= len(l.input) {
 		return 0
@@ -133,11 +256,72 @@ func (l *Lexer) readNumber() string {
 	return l.input[position:l.position]
 }
 
-func (l *Lexer) readString() string {
+// readString returns the contents of a double-quoted string. Strings that
+// contain a `${` interpolation are returned raw together with true, so the
+// parser can split them with SplitTemplate; other strings are unescaped.
+func (l *Lexer) readString() (string, bool) {
 	position := l.position + 1
+	isTemplate := false
 	for {
 		l.readChar()
-		if l.ch == '"' || l.ch == 0 {
+		if l.ch == '\\' && l.peekChar() != 0 {
+			l.readChar()
+			continue
+		}
+		if l.ch == '$' && l.peekChar() == '{' {
+			isTemplate = true
+			l.readChar()
+			l.skipInterpolation()
+			if l.ch == 0 {
+				break
+			}
+			continue
+		}
+		if l.ch == '"' {
+			break
+		}
+		if l.ch == 0 {
+			l.unterminated = true
+			break
+		}
+	}
+
+	raw := l.input[position:l.position]
+	if isTemplate {
+		return raw, true
+	}
+	return unescape(raw), false
+}
+
+// skipInterpolation advances from the opening brace of a `${` to its
+// matching closing brace, stepping over strings nested inside it.
+func (l *Lexer) skipInterpolation() {
+	depth := 1
+	for depth > 0 {
+		l.readChar()
+		switch l.ch {
+		case '{':
+			depth += 1
+		case '}':
+			depth -= 1
+		case '"':
+			l.readString()
+		case 0:
+			l.unterminated = true
+			return
+		}
+	}
+}
+
+func (l *Lexer) readRawString() string {
+	position := l.position + 1
+	for {
+		l.readChar()
+		if l.ch == '`' {
+			break
+		}
+		if l.ch == 0 {
+			l.unterminated = true
 			break
 		}
 	}
@@ -30,6 +30,13 @@ if (5 < 10) {
 "foo bar"
 [1, 2];
 {"foo": "bar"}
+"Hello ${name}, ${ {"a": "}"}["a"] }"
+"say \"hi\"\n"
+a && b || null ?? c;
+f(...xs);
+struct P { x } p.x;
+x in #{1};
+` + "`raw\nstring`" + `
 `
 
 	tests := []struct {
@@ -122,6 +129,38 @@ if (5 < 10) {
 		{token.COLON, ":"},
 		{token.STRING, "bar"},
 		{token.RBRACE, "}"},
+		{token.TEMPLATE, `Hello ${name}, ${ {"a": "}"}["a"] }`},
+		{token.STRING, "say \"hi\"\n"},
+		{token.IDENT, "a"},
+		{token.AND, "&&"},
+		{token.IDENT, "b"},
+		{token.OR, "||"},
+		{token.NULL, "null"},
+		{token.NULLISH, "??"},
+		{token.IDENT, "c"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "f"},
+		{token.LPAREN, "("},
+		{token.ELLIPSIS, "..."},
+		{token.IDENT, "xs"},
+		{token.RPAREN, ")"},
+		{token.SEMICOLON, ";"},
+		{token.STRUCT, "struct"},
+		{token.IDENT, "P"},
+		{token.LBRACE, "{"},
+		{token.IDENT, "x"},
+		{token.RBRACE, "}"},
+		{token.IDENT, "p"},
+		{token.DOT, "."},
+		{token.IDENT, "x"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.IN, "in"},
+		{token.SET_LBRACE, "#{"},
+		{token.INT, "1"},
+		{token.RBRACE, "}"},
+		{token.SEMICOLON, ";"},
+		{token.STRING, "raw\nstring"},
 		{token.EOF, ""},
 	}
 
@@ -141,3 +180,169 @@ if (5 < 10) {
 		}
 	}
 }
+
+func TestSplitTemplate(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []TemplatePart
+	}{
+		{"plain", []TemplatePart{{Value: "plain"}}},
+		{
+			"Hello ${name}!",
+			[]TemplatePart{
+				{Value: "Hello "},
+				{Value: "name", IsExpression: true, Offset: 8},
+				{Value: "!"},
+			},
+		},
+		{
+			`${a}${ {"k": "}"}["k"] }`,
+			[]TemplatePart{
+				{Value: "a", IsExpression: true, Offset: 2},
+				{Value: ` {"k": "}"}["k"] `, IsExpression: true, Offset: 6},
+			},
+		},
+		{`cost: \${price}`, []TemplatePart{{Value: "cost: ${price}"}}},
+	}
+
+	for _, tt := range tests {
+		parts, err := SplitTemplate(tt.input)
+		if err != nil {
+			t.Fatalf("SplitTemplate(%q) returned error: %s", tt.input, err)
+		}
+
+		if len(parts) != len(tt.expected) {
+			t.Fatalf("SplitTemplate(%q) has wrong number of parts. expected=%d, got=%d",
+				tt.input, len(tt.expected), len(parts))
+		}
+
+		for i, part := range parts {
+			if part != tt.expected[i] {
+				t.Errorf("SplitTemplate(%q)[%d] wrong. expected=%+v, got=%+v",
+					tt.input, i, tt.expected[i], part)
+			}
+		}
+	}
+
+	if _, err := SplitTemplate("oops ${name"); err == nil {
+		t.Errorf("expected error for unterminated interpolation")
+	}
+}
+
+func TestNextTokenPositions(t *testing.T) {
+	input := "let x = 5;\n\tx + \"é\" +\n  `a\nb` == 犬;"
+
+	tests := []struct {
+		expectedType   token.TokenType
+		expectedLine   int
+		expectedColumn int
+		expectedOffset int
+	}{
+		{token.LET, 1, 1, 0},
+		{token.IDENT, 1, 5, 4},
+		{token.ASSIGN, 1, 7, 6},
+		{token.INT, 1, 9, 8},
+		{token.SEMICOLON, 1, 10, 9},
+		{token.IDENT, 2, 4, 12},
+		{token.PLUS, 2, 6, 14},
+		{token.STRING, 2, 8, 16},
+		{token.PLUS, 2, 12, 21},
+		{token.STRING, 3, 3, 25},
+		{token.EQ, 4, 4, 31},
+		{token.ILLEGAL, 4, 7, 34},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Line != tt.expectedLine || tok.Column != tt.expectedColumn {
+			t.Fatalf("tests[%d] - position wrong. expected=%d:%d, got=%d:%d",
+				i, tt.expectedLine, tt.expectedColumn, tok.Line, tok.Column)
+		}
+
+		if tok.Offset != tt.expectedOffset {
+			t.Fatalf("tests[%d] - offset wrong. expected=%d, got=%d",
+				i, tt.expectedOffset, tok.Offset)
+		}
+	}
+}
+
+func TestUnterminatedStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`"abc"`, false},
+		{`"abc`, true},
+		{`"a\"`, true},
+		{`"${ "x" }"`, false},
+		{`"${ "x" }`, true},
+		{`"${ "x"`, true},
+		{"`raw`", false},
+		{"`raw", true},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		}
+
+		if l.Unterminated() != tt.expected {
+			t.Errorf("%s: Unterminated() wrong. expected=%t, got=%t",
+				tt.input, tt.expected, l.Unterminated())
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := "// leading\nlet x = 10 / 2; // trailing\r\n\"// not a comment\"\n//"
+
+	expectedTokens := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "10"},
+		{token.SLASH, "/"},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.STRING, "// not a comment"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range expectedTokens {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%s %q, got=%s %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+
+	expectedComments := []token.Token{
+		{Type: token.COMMENT, Literal: "// leading", Line: 1, Column: 1, Offset: 0},
+		{Type: token.COMMENT, Literal: "// trailing", Line: 2, Column: 17, Offset: 27},
+		{Type: token.COMMENT, Literal: "//", Line: 4, Column: 1, Offset: 59},
+	}
+
+	comments := l.Comments()
+	if len(comments) != len(expectedComments) {
+		t.Fatalf("wrong number of comments. expected=%d, got=%d (%v)",
+			len(expectedComments), len(comments), comments)
+	}
+	for i, expected := range expectedComments {
+		if comments[i] != expected {
+			t.Errorf("comments[%d] wrong. expected=%+v, got=%+v", i, expected, comments[i])
+		}
+	}
+}
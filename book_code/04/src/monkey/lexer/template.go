@@ -0,0 +1,143 @@
+package lexer
+
+import (
+	"fmt"
+	"monkey/token"
+	"strings"
+)
+
+// TemplatePart is one piece of an interpolated string: either literal text
+// or the source code of an embedded `${...}` expression.
+type TemplatePart struct {
+	Value        string
+	IsExpression bool
+	Offset       int // of an expression, in the raw contents
+}
+
+// SplitTemplate splits the raw contents of an interpolated string into its
+// literal text and expression parts. Escape sequences in the text parts are
+// resolved, so `\${` produces a literal `${`.
+func SplitTemplate(raw string) ([]TemplatePart, error) {
+	parts := []TemplatePart{}
+	var text strings.Builder
+
+	for i := 0; i < len(raw); i++ {
+		ch := raw[i]
+
+		if ch == '\\' && i+1 < len(raw) {
+			text.WriteByte(unescapeChar(raw[i+1]))
+			i += 1
+			continue
+		}
+
+		if ch == '$' && i+1 < len(raw) && raw[i+1] == '{' {
+			end := findInterpolationEnd(raw, i+2)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated interpolation in %q", raw)
+			}
+
+			if text.Len() > 0 {
+				parts = append(parts, TemplatePart{Value: text.String()})
+				text.Reset()
+			}
+			parts = append(parts, TemplatePart{
+				Value:        raw[i+2 : end],
+				IsExpression: true,
+				Offset:       i + 2,
+			})
+
+			i = end
+			continue
+		}
+
+		text.WriteByte(ch)
+	}
+
+	if text.Len() > 0 {
+		parts = append(parts, TemplatePart{Value: text.String()})
+	}
+
+	return parts, nil
+}
+
+// NewInterpolation returns a lexer for the expression part of the template
+// token tok. Its tokens are stamped with their positions in the source tok
+// was read from, not in the part.
+func NewInterpolation(tok token.Token, part TemplatePart) *Lexer {
+	l := &Lexer{
+		input:  tok.Literal[:part.Offset+len(part.Value)],
+		line:   tok.Line,
+		column: tok.Column + 1, // the contents start after the quote
+		base:   tok.Offset + 1,
+	}
+	l.readChar()
+	for l.position < part.Offset {
+		l.readChar()
+	}
+	return l
+}
+
+// findInterpolationEnd returns the index of the brace closing an
+// interpolation whose expression starts at start, or -1 if there is none.
+func findInterpolationEnd(raw string, start int) int {
+	depth := 1
+	inString := false
+
+	for i := start; i < len(raw); i++ {
+		ch := raw[i]
+
+		if inString {
+			if ch == '\\' {
+				i += 1
+			} else if ch == '"' {
+				inString = false
+			}
+			continue
+		}
+
+		switch ch {
+		case '"':
+			inString = true
+		case '{':
+			depth += 1
+		case '}':
+			depth -= 1
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+func unescape(raw string) string {
+	if !strings.Contains(raw, "\\") {
+		return raw
+	}
+
+	var out strings.Builder
+	for i := 0; i < len(raw); i++ {
+		if raw[i] == '\\' && i+1 < len(raw) {
+			out.WriteByte(unescapeChar(raw[i+1]))
+			i += 1
+			continue
+		}
+		out.WriteByte(raw[i])
+	}
+
+	return out.String()
+}
+
+func unescapeChar(ch byte) byte {
+	switch ch {
+	case 'n':
+		return '\n'
+	case 't':
+		return '\t'
+	case 'r':
+		return '\r'
+	default:
+		return ch
+	}
+}
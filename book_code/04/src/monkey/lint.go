@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"monkey/lexer"
+	"monkey/lint"
+	"monkey/parser"
+	"os"
+)
+
+// runLint runs the lint subcommand with args, the arguments after "lint",
+// and returns the exit status for the process: 1 if there were errors, and
+// 0 if there were only warnings.
+func runLint(args []string) int {
+	config := lint.Config{}
+
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	flags.Var(config, "rule",
+		"comma-separated `rule=severity` settings, with severity off, warning or error")
+	list := flags.Bool("list", false, "list the rules with their default severities")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: monkey lint [--rule rule=severity] [--list] [files]\n\n"+
+			"Checks the files, or standard input, for likely mistakes.\n\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if *list {
+		for _, rule := range lint.Rules() {
+			fmt.Printf("%s (%s)\n\t%s\n", rule.Name, rule.Severity, rule.Doc)
+		}
+		return 0
+	}
+
+	if flags.NArg() == 0 {
+		source, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return lintFile("<stdin>", source, config)
+	}
+
+	status := 0
+	for _, path := range flags.Args() {
+		source, err := ioutil.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+			continue
+		}
+		if s := lintFile(path, source, config); s != 0 {
+			status = s
+		}
+	}
+
+	return status
+}
+
+// lintFile prints the diagnostics for source, read from path, and returns
+// the exit status for the file.
+func lintFile(path string, source []byte, config lint.Config) int {
+	l := lexer.New(string(source))
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		printParseErrors(path, p)
+		return 1
+	}
+
+	status := 0
+	for _, d := range lint.Check(program, string(source), l.Comments(), config) {
+		fmt.Printf("%s:%s\n", path, d)
+		if d.Severity == lint.ERROR {
+			status = 1
+		}
+	}
+	return status
+}
@@ -0,0 +1,224 @@
+// Package lint finds likely mistakes in Monkey programs without running
+// them. Each check is a Rule in a registry, reporting Diagnostics at the
+// line and column of the token they are about.
+//
+// A diagnostic can be suppressed with a comment such as
+//
+//	// lint:ignore unused-let, shadowed-builtin
+//
+// after the code on the line of the diagnostic, or alone on the line before
+// it. Without rule names, the comment suppresses every rule.
+package lint
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/scope"
+	"monkey/token"
+	"sort"
+	"strings"
+)
+
+type Severity int
+
+const (
+	OFF Severity = iota
+	WARNING
+	ERROR
+)
+
+var severityNames = map[Severity]string{
+	OFF:     "off",
+	WARNING: "warning",
+	ERROR:   "error",
+}
+
+func (s Severity) String() string {
+	if name, ok := severityNames[s]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// ParseSeverity returns the severity called name.
+func ParseSeverity(name string) (Severity, error) {
+	for s, n := range severityNames {
+		if n == name {
+			return s, nil
+		}
+	}
+	return OFF, fmt.Errorf("unknown severity %q (expected off, warning or error)", name)
+}
+
+// Diagnostic is a problem found by a rule.
+type Diagnostic struct {
+	Rule     string
+	Severity Severity
+	Line     int
+	Column   int
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%d:%d: %s: %s (%s)", d.Line, d.Column, d.Severity, d.Message, d.Rule)
+}
+
+// Rule is a check run over whole programs.
+type Rule struct {
+	Name     string
+	Doc      string
+	Severity Severity // unless configured otherwise
+	Check    func(pass *Pass)
+}
+
+var rules = map[string]*Rule{}
+
+func register(rule *Rule) {
+	rules[rule.Name] = rule
+}
+
+// Rules returns the registered rules, sorted by name.
+func Rules() []*Rule {
+	result := []*Rule{}
+	for _, rule := range rules {
+		result = append(result, rule)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// Config sets the severity of rules by name, overriding their defaults. It
+// can be used as a flag taking comma-separated rule=severity settings.
+type Config map[string]Severity
+
+func (c Config) String() string {
+	settings := []string{}
+	for name, severity := range c {
+		settings = append(settings, name+"="+severity.String())
+	}
+	sort.Strings(settings)
+	return strings.Join(settings, ",")
+}
+
+func (c Config) Set(value string) error {
+	for _, setting := range strings.Split(value, ",") {
+		parts := strings.SplitN(strings.TrimSpace(setting), "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("expected rule=severity, got %q", setting)
+		}
+		if _, ok := rules[parts[0]]; !ok {
+			return fmt.Errorf("unknown rule %q", parts[0])
+		}
+		severity, err := ParseSeverity(parts[1])
+		if err != nil {
+			return err
+		}
+		c[parts[0]] = severity
+	}
+	return nil
+}
+
+func (c Config) severity(rule *Rule) Severity {
+	if severity, ok := c[rule.Name]; ok {
+		return severity
+	}
+	return rule.Severity
+}
+
+// Pass is what a rule checks: a program, with its names resolved.
+type Pass struct {
+	Program *ast.Program
+	Scope   *scope.Info
+
+	rule        *Rule
+	severity    Severity
+	diagnostics []Diagnostic
+}
+
+// Report adds a diagnostic at tok.
+func (p *Pass) Report(tok token.Token, format string, a ...interface{}) {
+	p.diagnostics = append(p.diagnostics, Diagnostic{
+		Rule:     p.rule.Name,
+		Severity: p.severity,
+		Line:     tok.Line,
+		Column:   tok.Column,
+		Message:  fmt.Sprintf(format, a...),
+	})
+}
+
+// Check runs the rules that are not off in config over program, parsed from
+// source with comments, and returns their diagnostics sorted by position.
+func Check(program *ast.Program, source string, comments []token.Token, config Config) []Diagnostic {
+	pass := &Pass{Program: program, Scope: scope.Resolve(program)}
+	for _, rule := range Rules() {
+		pass.rule = rule
+		pass.severity = config.severity(rule)
+		if pass.severity != OFF {
+			rule.Check(pass)
+		}
+	}
+
+	ignored := ignores(source, comments)
+	diagnostics := []Diagnostic{}
+	for _, d := range pass.diagnostics {
+		if !ignored.suppresses(d) {
+			diagnostics = append(diagnostics, d)
+		}
+	}
+
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		a, b := diagnostics[i], diagnostics[j]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return diagnostics
+}
+
+// suppressions holds the rules ignored on each line, with "" standing for
+// all of them.
+type suppressions map[int]map[string]bool
+
+const IGNORE_DIRECTIVE = "lint:ignore"
+
+// ignores finds the directives in comments. One after code on a line is
+// about that line, and one alone on its line is about the next.
+func ignores(source string, comments []token.Token) suppressions {
+	s := suppressions{}
+	for _, comment := range comments {
+		text := strings.TrimSpace(strings.TrimPrefix(comment.Literal, "//"))
+		if !strings.HasPrefix(text, IGNORE_DIRECTIVE) {
+			continue
+		}
+		rest := text[len(IGNORE_DIRECTIVE):]
+		if rest != "" && rest[0] != ' ' && rest[0] != '\t' {
+			continue
+		}
+
+		names := strings.FieldsFunc(rest, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})
+		if len(names) == 0 {
+			names = []string{""}
+		}
+
+		line := comment.Line
+		lineStart := strings.LastIndexByte(source[:comment.Offset], '\n') + 1
+		if strings.TrimSpace(source[lineStart:comment.Offset]) == "" {
+			line++
+		}
+
+		if s[line] == nil {
+			s[line] = map[string]bool{}
+		}
+		for _, name := range names {
+			s[line][name] = true
+		}
+	}
+	return s
+}
+
+func (s suppressions) suppresses(d Diagnostic) bool {
+	return s[d.Line][""] || s[d.Line][d.Rule]
+}
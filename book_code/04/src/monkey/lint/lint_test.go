@@ -0,0 +1,162 @@
+package lint
+
+import (
+	"monkey/lexer"
+	"monkey/parser"
+	"testing"
+)
+
+func check(t *testing.T, input string, config Config) []Diagnostic {
+	t.Helper()
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return Check(program, input, l.Comments(), config)
+}
+
+func testDiagnostics(t *testing.T, input string, config Config, expected []string) {
+	t.Helper()
+
+	diagnostics := check(t, input, config)
+	if len(diagnostics) != len(expected) {
+		t.Errorf("%q: wrong number of diagnostics. expected=%q, got=%q",
+			input, expected, diagnostics)
+		return
+	}
+	for i, d := range diagnostics {
+		if d.String() != expected[i] {
+			t.Errorf("%q: diagnostic %d wrong. expected=%q, got=%q",
+				input, i, expected[i], d.String())
+		}
+	}
+}
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{
+			"let f = fn() { let x = 1; let _y = 2; };",
+			[]string{"1:20: warning: x is bound but never used (unused-let)"},
+		},
+		{
+			"let x = 1; let f = fn() { 2 }; let g = fn() { f() }; g()",
+			[]string{"1:5: warning: x is bound but never used (unused-let)"},
+		},
+		{
+			"let [a, b] = [1, 2]; b",
+			[]string{"1:6: warning: a is bound but never used (unused-let)"},
+		},
+		{
+			"let len = 1; fn(puts) { puts }; len",
+			[]string{
+				"1:5: warning: let len shadows the builtin of the same name (shadowed-builtin)",
+				"1:17: warning: parameter puts shadows the builtin of the same name (shadowed-builtin)",
+			},
+		},
+		{
+			"fn() { return 1; let x = 2; x; }; return 2; 3",
+			[]string{
+				"1:18: warning: unreachable code after return (unreachable)",
+				"1:45: warning: unreachable code after return (unreachable)",
+			},
+		},
+		{
+			"if (true) { 1 }; if (1 + 1 == 3) { 2 }; if (!null) { 3 }; if ([]) { 4 }",
+			[]string{
+				"1:1: warning: if condition is always true (constant-condition)",
+				"1:18: warning: if condition is always false (constant-condition)",
+				"1:41: warning: if condition is always true (constant-condition)",
+				"1:59: warning: if condition is always true (constant-condition)",
+			},
+		},
+		{
+			"let x = 1; if (x > 1) { 1 }; if (1 / 0) { 2 }; if (f()) { 3 }",
+			[]string{},
+		},
+		{
+			`{"a": 1, "b": 2, "a": 3, 1: 1, true: 1, true: 2, x: 1, x: 2}`,
+			[]string{
+				`1:18: error: duplicate key "a" in hash literal (duplicate-key)`,
+				"1:41: error: duplicate key true in hash literal (duplicate-key)",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		testDiagnostics(t, tt.input, Config{}, tt.expected)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	config := Config{}
+	if err := config.Set("unused-let=off,duplicate-key=warning"); err != nil {
+		t.Fatalf("config.Set failed: %s", err)
+	}
+
+	input := `let x = {"a": 1, "a": 2};`
+	testDiagnostics(t, input, config, []string{
+		`1:18: warning: duplicate key "a" in hash literal (duplicate-key)`,
+	})
+
+	for _, bad := range []string{"unused-let", "nope=off", "unused-let=loud"} {
+		if err := (Config{}).Set(bad); err == nil {
+			t.Errorf("expected an error setting %q", bad)
+		}
+	}
+}
+
+func TestSuppression(t *testing.T) {
+	input := `let a = 1; // lint:ignore unused-let
+// lint:ignore shadowed-builtin, unused-let
+let len = 2;
+// lint:ignore
+let c = {"k": 1, "k": 2};
+// lint:ignore shadowed-builtin
+let d = 3;
+`
+	testDiagnostics(t, input, Config{}, []string{
+		"7:5: warning: d is bound but never used (unused-let)",
+	})
+}
+
+func TestTrailingSuppression(t *testing.T) {
+	input := `let used = 1; // lint:ignore unused-let
+let g = fn() { let inner = 2; 3 };
+let c = 3; // lint:ignored unused-let
+	// lint:ignore unused-let
+let d = 4;
+`
+	testDiagnostics(t, input, Config{}, []string{
+		"2:20: warning: inner is bound but never used (unused-let)",
+		"3:5: warning: c is bound but never used (unused-let)",
+	})
+}
+
+func TestRulesRegistered(t *testing.T) {
+	expected := []string{
+		"constant-condition",
+		"duplicate-key",
+		"shadowed-builtin",
+		"unreachable",
+		"unused-let",
+	}
+
+	registered := Rules()
+	if len(registered) != len(expected) {
+		t.Fatalf("wrong number of rules. expected=%d, got=%d", len(expected), len(registered))
+	}
+	for i, rule := range registered {
+		if rule.Name != expected[i] {
+			t.Errorf("rule %d wrong. expected=%s, got=%s", i, expected[i], rule.Name)
+		}
+		if rule.Doc == "" || rule.Check == nil {
+			t.Errorf("rule %s is missing its doc or check", rule.Name)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package lint
+
+import (
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/object"
+)
+
+func init() {
+	register(&Rule{
+		Name:     "unreachable",
+		Doc:      "statements after a return statement, which never run",
+		Severity: WARNING,
+		Check:    checkUnreachable,
+	})
+	register(&Rule{
+		Name:     "constant-condition",
+		Doc:      "an if whose condition is always true or always false",
+		Severity: WARNING,
+		Check:    checkConstantCondition,
+	})
+}
+
+func checkUnreachable(pass *Pass) {
+	check := func(stmts []ast.Statement) {
+		for i, stmt := range stmts[:len(stmts)-1] {
+			if _, ok := stmt.(*ast.ReturnStatement); ok {
+				pass.Report(ast.StatementToken(stmts[i+1]), "unreachable code after return")
+				return
+			}
+		}
+	}
+
+	ast.Inspect(pass.Program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.Program:
+			if len(node.Statements) > 0 {
+				check(node.Statements)
+			}
+		case *ast.BlockStatement:
+			if len(node.Statements) > 0 {
+				check(node.Statements)
+			}
+		}
+		return true
+	})
+}
+
+func checkConstantCondition(pass *Pass) {
+	ast.Inspect(pass.Program, func(node ast.Node) bool {
+		if ie, ok := node.(*ast.IfExpression); ok {
+			if truthy, ok := constantTruth(ie.Condition); ok {
+				if truthy {
+					pass.Report(ie.Token, "if condition is always true")
+				} else {
+					pass.Report(ie.Token, "if condition is always false")
+				}
+			}
+		}
+		return true
+	})
+}
+
+// constantTruth reports whether the truth of e is known without running the
+// program, and if so what it is.
+func constantTruth(e ast.Expression) (truthy bool, ok bool) {
+	switch e := e.(type) {
+	case *ast.Boolean:
+		return e.Value, true
+	case *ast.Null:
+		return false, true
+	case *ast.IntegerLiteral, *ast.StringLiteral, *ast.TemplateLiteral,
+		*ast.FunctionLiteral, *ast.ArrayLiteral, *ast.HashLiteral, *ast.SetLiteral:
+		// values other than false and null are all truthy
+		return true, true
+	case *ast.PrefixExpression, *ast.InfixExpression:
+		if !isLiteral(e) {
+			return false, false
+		}
+		switch obj := evaluator.Eval(e, object.NewEnvironment()).(type) {
+		case *object.Error:
+			return false, false
+		case *object.Boolean:
+			return obj.Value, true
+		case *object.Null:
+			return false, true
+		default:
+			return true, true
+		}
+	}
+	return false, false
+}
+
+// isLiteral reports whether e is made of literals only, so evaluating it
+// has no effects and always gives the same value.
+func isLiteral(e ast.Expression) bool {
+	literal := true
+	ast.Inspect(e, func(node ast.Node) bool {
+		switch node.(type) {
+		case *ast.Identifier, *ast.CallExpression, *ast.FunctionLiteral,
+			*ast.IfExpression, *ast.MatchExpression:
+			literal = false
+		}
+		return literal
+	})
+	return literal
+}
@@ -0,0 +1,54 @@
+package lint
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/token"
+)
+
+func init() {
+	register(&Rule{
+		Name:     "duplicate-key",
+		Doc:      "a hash literal with the same literal key twice, where the last one wins",
+		Severity: ERROR,
+		Check:    checkDuplicateKey,
+	})
+}
+
+func checkDuplicateKey(pass *Pass) {
+	ast.Inspect(pass.Program, func(node ast.Node) bool {
+		hl, ok := node.(*ast.HashLiteral)
+		if !ok {
+			return true
+		}
+
+		seen := map[string]bool{}
+		for _, key := range hl.Keys {
+			tok, name, ok := literalKey(key)
+			if !ok {
+				continue
+			}
+			if seen[name] {
+				pass.Report(tok, "duplicate key %s in hash literal", name)
+			}
+			seen[name] = true
+		}
+		return true
+	})
+}
+
+// literalKey returns the token and the printed value of a key written as a
+// literal.
+func literalKey(key ast.Expression) (token.Token, string, bool) {
+	switch key := key.(type) {
+	case *ast.StringLiteral:
+		return key.Token, fmt.Sprintf("%q", key.Value), true
+	case *ast.IntegerLiteral:
+		return key.Token, key.String(), true
+	case *ast.Boolean:
+		return key.Token, key.String(), true
+	case *ast.Null:
+		return key.Token, key.String(), true
+	}
+	return token.Token{}, "", false
+}
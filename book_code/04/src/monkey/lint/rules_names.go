@@ -0,0 +1,49 @@
+package lint
+
+import (
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/scope"
+	"strings"
+)
+
+func init() {
+	register(&Rule{
+		Name: "unused-let",
+		Doc: "a let binding that is never used, other than a name starting with _ " +
+			"or a function bound at the top level for other files to use",
+		Severity: WARNING,
+		Check:    checkUnusedLet,
+	})
+	register(&Rule{
+		Name:     "shadowed-builtin",
+		Doc:      "a binding that hides a builtin function such as len",
+		Severity: WARNING,
+		Check:    checkShadowedBuiltin,
+	})
+}
+
+func checkUnusedLet(pass *Pass) {
+	for _, b := range pass.Scope.Bindings {
+		if b.Kind != scope.LET || len(b.Uses) > 0 || strings.HasPrefix(b.Name, "_") {
+			continue
+		}
+		if _, ok := b.Value.(*ast.FunctionLiteral); ok && b.TopLevel {
+			continue
+		}
+		pass.Report(b.Ident.Token, "%s is bound but never used", b.Name)
+	}
+}
+
+func checkShadowedBuiltin(pass *Pass) {
+	builtins := map[string]bool{}
+	for _, name := range evaluator.BuiltinNames() {
+		builtins[name] = true
+	}
+
+	for _, b := range pass.Scope.Bindings {
+		if builtins[b.Name] {
+			pass.Report(b.Ident.Token, "%s %s shadows the builtin of the same name", b.Kind, b.Name)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"monkey/lsp"
+	"os"
+)
+
+// runLSP runs the lsp subcommand with args, the arguments after "lsp",
+// serving the Language Server Protocol on standard input and output until
+// the editor exits it.
+func runLSP(args []string) int {
+	flags := flag.NewFlagSet("lsp", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: monkey lsp\n\n"+
+			"Serves the Language Server Protocol on standard input and output.\n")
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if err := lsp.NewServer(os.Stdin, os.Stdout).Run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,220 @@
+package lsp
+
+import (
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/scope"
+	"monkey/token"
+	"sort"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// document is an open text document with what the server knows about it.
+type document struct {
+	uri     string
+	version int
+	text    string
+
+	lineStarts []int         // byte offset of the start of each line
+	tokens     []token.Token // in source order, with the comments
+	ends       map[int]int   // end offset of each token, by start offset
+
+	program     *ast.Program
+	diagnostics []Diagnostic
+
+	// set only when the document parses
+	info   *scope.Info
+	idents []*ast.Identifier // the identifiers in the text, by offset
+}
+
+func newDocument(uri string, version int, text string) *document {
+	d := &document{uri: uri, version: version, text: text, ends: map[int]int{}}
+
+	d.lineStarts = []int{0}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			d.lineStarts = append(d.lineStarts, i+1)
+		}
+	}
+
+	p := parser.New(lexer.New(text))
+	d.program = p.ParseProgram()
+
+	// the parser may stop early, so the tokens come from a lexer of their own
+	tl := lexer.New(text)
+	for tok := tl.NextToken(); tok.Type != token.EOF; tok = tl.NextToken() {
+		d.tokens = append(d.tokens, tok)
+	}
+	d.tokens = append(d.tokens, tl.Comments()...)
+	sort.SliceStable(d.tokens, func(i, j int) bool { return d.tokens[i].Offset < d.tokens[j].Offset })
+
+	// a token runs up to the next one, less the whitespace in between
+	for i, tok := range d.tokens {
+		end := len(text)
+		if i+1 < len(d.tokens) {
+			end = d.tokens[i+1].Offset
+		}
+		d.ends[tok.Offset] = tok.Offset + len(strings.TrimRight(text[tok.Offset:end], " \t\r\n"))
+	}
+
+	for i, msg := range p.Errors() {
+		d.diagnostics = append(d.diagnostics, Diagnostic{
+			Range:    d.tokenRange(p.ErrorTokens()[i]),
+			Severity: SEVERITY_ERROR,
+			Source:   "monkey",
+			Message:  msg,
+		})
+	}
+	if len(p.Errors()) > 0 {
+		return d
+	}
+
+	d.info = scope.Resolve(d.program)
+	ast.Inspect(d.program, func(node ast.Node) bool {
+		if ident, ok := node.(*ast.Identifier); ok {
+			d.idents = append(d.idents, ident)
+		}
+		return true
+	})
+	sort.SliceStable(d.idents, func(i, j int) bool {
+		return d.idents[i].Token.Offset < d.idents[j].Token.Offset
+	})
+
+	return d
+}
+
+// position returns the position of the byte at offset.
+func (d *document) position(offset int) Position {
+	line := sort.Search(len(d.lineStarts), func(i int) bool { return d.lineStarts[i] > offset }) - 1
+	if line < 0 {
+		line = 0
+	}
+	return Position{Line: line, Character: utf16Length(d.text[d.lineStarts[line]:offset])}
+}
+
+// offset returns the byte offset of pos, clamped to the text.
+func (d *document) offset(pos Position) int {
+	if pos.Line < 0 {
+		return 0
+	}
+	if pos.Line >= len(d.lineStarts) {
+		return len(d.text)
+	}
+
+	offset := d.lineStarts[pos.Line]
+	for units := 0; offset < len(d.text) && d.text[offset] != '\n'; {
+		r, size := utf8.DecodeRuneInString(d.text[offset:])
+		units += len(utf16.Encode([]rune{r}))
+		if units > pos.Character {
+			break
+		}
+		offset += size
+	}
+	return offset
+}
+
+func utf16Length(s string) int {
+	return len(utf16.Encode([]rune(s)))
+}
+
+func (d *document) span(start, end int) Range {
+	return Range{Start: d.position(start), End: d.position(end)}
+}
+
+// tokenRange returns the range of tok in the text. The EOF token, which
+// errors at the end of the text are about, is empty.
+func (d *document) tokenRange(tok token.Token) Range {
+	if end, ok := d.ends[tok.Offset]; ok && tok.Type != token.EOF {
+		return d.span(tok.Offset, end)
+	}
+	offset := tok.Offset
+	if offset > len(d.text) {
+		offset = len(d.text)
+	}
+	return d.span(offset, offset)
+}
+
+// location returns where ident is. Identifiers in interpolated strings are
+// inside a string token, so their range comes from their own length.
+func (d *document) location(ident *ast.Identifier) Location {
+	start := ident.Token.Offset
+	return Location{URI: d.uri, Range: d.span(start, start+len(ident.Value))}
+}
+
+// identAt returns the identifier at pos, or nil. A position just after an
+// identifier counts as on it, as a cursor at the end of a word is.
+func (d *document) identAt(pos Position) *ast.Identifier {
+	offset := d.offset(pos)
+	i := sort.Search(len(d.idents), func(i int) bool {
+		return d.idents[i].Token.Offset > offset
+	}) - 1
+	if i < 0 {
+		return nil
+	}
+
+	ident := d.idents[i]
+	if offset > ident.Token.Offset+len(ident.Value) {
+		return nil
+	}
+	return ident
+}
+
+// wordBefore returns the part of an identifier that ends at pos.
+func (d *document) wordBefore(pos Position) string {
+	offset := d.offset(pos)
+	start := offset
+	for start > 0 && isWordByte(d.text[start-1]) {
+		start -= 1
+	}
+	return d.text[start:offset]
+}
+
+func isWordByte(ch byte) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+}
+
+// statementRanges returns the range of each of stmts, which are followed by
+// end: a statement runs from its first token to the last token before the
+// next statement.
+func (d *document) statementRanges(stmts []ast.Statement, end int) []Range {
+	ranges := []Range{}
+	for i, stmt := range stmts {
+		start := ast.StatementToken(stmt).Offset
+		next := end
+		if i+1 < len(stmts) {
+			next = ast.StatementToken(stmts[i+1]).Offset
+		}
+
+		last := start
+		for _, tok := range d.tokens {
+			if tok.Offset >= start && tok.Offset < next && tok.Type != token.COMMENT {
+				last = tok.Offset
+			}
+		}
+		ranges = append(ranges, d.span(start, d.ends[last]))
+	}
+	return ranges
+}
+
+// closing returns the offset of the brace closing the one at offset.
+func (d *document) closing(offset int) int {
+	depth := 0
+	for _, tok := range d.tokens {
+		if tok.Offset < offset {
+			continue
+		}
+		switch tok.Type {
+		case token.LBRACE, token.SET_LBRACE:
+			depth += 1
+		case token.RBRACE:
+			depth -= 1
+			if depth == 0 {
+				return tok.Offset
+			}
+		}
+	}
+	return len(d.text)
+}
@@ -0,0 +1,370 @@
+package lsp
+
+import (
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/object"
+	"monkey/scope"
+	"monkey/token"
+	"sort"
+	"strings"
+)
+
+var builtins = map[string]bool{}
+
+func init() {
+	for _, name := range evaluator.BuiltinNames() {
+		builtins[name] = true
+	}
+}
+
+// The semantic token types and modifiers, in the order of the legend.
+var (
+	semanticTypes = []string{
+		"keyword", "variable", "parameter", "function", "struct",
+		"number", "string", "operator", "comment",
+	}
+	semanticModifiers = []string{"declaration", "defaultLibrary"}
+)
+
+func semanticIndex(name string) int {
+	for i, t := range semanticTypes {
+		if t == name {
+			return i
+		}
+	}
+	return -1
+}
+
+const (
+	MODIFIER_DECLARATION     = 1 << 0
+	MODIFIER_DEFAULT_LIBRARY = 1 << 1
+)
+
+var tokenSemantics = map[token.TokenType]string{
+	token.INT:      "number",
+	token.STRING:   "string",
+	token.TEMPLATE: "string",
+	token.COMMENT:  "comment",
+
+	token.ASSIGN: "operator", token.PLUS: "operator", token.MINUS: "operator",
+	token.BANG: "operator", token.ASTERISK: "operator", token.SLASH: "operator",
+	token.LT: "operator", token.GT: "operator", token.EQ: "operator",
+	token.NOT_EQ: "operator", token.AND: "operator", token.OR: "operator",
+	token.NULLISH: "operator", token.ARROW: "operator", token.ELLIPSIS: "operator",
+}
+
+// semanticTokens classifies the tokens of d by their kind, and identifiers
+// further by what they are bound to.
+func (d *document) semanticTokens() SemanticTokens {
+	data := []int{}
+	previous := Position{}
+
+	for _, tok := range d.tokens {
+		kind, modifiers := d.classify(tok)
+		index := semanticIndex(kind)
+		if index < 0 {
+			continue
+		}
+
+		// tokens may not span lines, so a multi-line string is sent a
+		// line at a time
+		text := d.text[tok.Offset:d.ends[tok.Offset]]
+		offset := tok.Offset
+		for _, line := range strings.SplitAfter(text, "\n") {
+			piece := strings.TrimRight(line, "\r\n")
+			if piece != "" {
+				start := d.position(offset)
+				deltaStart := start.Character
+				if start.Line == previous.Line {
+					deltaStart -= previous.Character
+				}
+				data = append(data, start.Line-previous.Line, deltaStart,
+					utf16Length(piece), index, modifiers)
+				previous = start
+			}
+			offset += len(line)
+		}
+	}
+
+	return SemanticTokens{Data: data}
+}
+
+func (d *document) classify(tok token.Token) (string, int) {
+	if kind, ok := tokenSemantics[tok.Type]; ok {
+		return kind, 0
+	}
+	if tok.Type != token.IDENT {
+		if token.LookupIdent(tok.Literal) == tok.Type {
+			return "keyword", 0
+		}
+		return "", 0
+	}
+
+	ident := d.identAt(d.position(tok.Offset))
+	if ident == nil || ident.Token.Offset != tok.Offset {
+		return "variable", 0
+	}
+
+	b := d.info.BindingAt(ident)
+	if b == nil {
+		if builtins[ident.Value] {
+			return "function", MODIFIER_DEFAULT_LIBRARY
+		}
+		return "variable", 0
+	}
+
+	modifiers := 0
+	if b.Ident == ident {
+		modifiers |= MODIFIER_DECLARATION
+	}
+	switch {
+	case b.Kind == scope.STRUCT:
+		return "struct", modifiers
+	case b.Kind == scope.PARAMETER:
+		return "parameter", modifiers
+	case isFunction(b.Value):
+		return "function", modifiers
+	default:
+		return "variable", modifiers
+	}
+}
+
+func isFunction(e ast.Expression) bool {
+	_, ok := e.(*ast.FunctionLiteral)
+	return ok
+}
+
+// hover describes the binding of the identifier at pos, with the kind of
+// value it holds when that can be told from the source.
+func (d *document) hover(pos Position) *Hover {
+	ident := d.identAt(pos)
+	if ident == nil {
+		return nil
+	}
+
+	var text string
+	if b := d.info.BindingAt(ident); b != nil {
+		text = describe(b, d.info)
+	} else if builtins[ident.Value] {
+		text = "builtin " + ident.Value
+	} else {
+		return nil
+	}
+
+	return &Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: "```monkey\n" + text + "\n```"},
+		Range:    d.tokenRange(ident.Token),
+	}
+}
+
+func describe(b *scope.Binding, info *scope.Info) string {
+	switch b.Kind {
+	case scope.STRUCT:
+		return "struct " + b.Name
+	case scope.PATTERN:
+		return "pattern " + b.Name
+	}
+
+	text := b.Kind.String() + " " + b.Name
+	if kind := inferKind(b.Value, info, 0); kind != "" {
+		text += ": " + string(kind)
+	}
+	if fn, ok := b.Value.(*ast.FunctionLiteral); ok {
+		text += " fn(" + ast.ParametersString(fn.Parameters, fn.Defaults, fn.Rest) + ")"
+	}
+	return text
+}
+
+// MAX_INFERENCE_DEPTH bounds following bindings to the bindings they are
+// made from, which could be circular.
+const MAX_INFERENCE_DEPTH = 10
+
+// inferKind returns the object type e evaluates to, or "" if that depends
+// on more than the source.
+func inferKind(e ast.Expression, info *scope.Info, depth int) object.ObjectType {
+	if depth > MAX_INFERENCE_DEPTH {
+		return ""
+	}
+
+	switch e := e.(type) {
+	case *ast.IntegerLiteral:
+		if e.Big != nil {
+			return object.BIG_INTEGER_OBJ
+		}
+		return object.INTEGER_OBJ
+	case *ast.StringLiteral, *ast.TemplateLiteral:
+		return object.STRING_OBJ
+	case *ast.Boolean:
+		return object.BOOLEAN_OBJ
+	case *ast.Null:
+		return object.NULL_OBJ
+	case *ast.ArrayLiteral:
+		return object.ARRAY_OBJ
+	case *ast.HashLiteral:
+		return object.HASH_OBJ
+	case *ast.SetLiteral:
+		return object.SET_OBJ
+	case *ast.FunctionLiteral:
+		return object.FUNCTION_OBJ
+
+	case *ast.Identifier:
+		if b := info.Uses[e]; b != nil && b.Kind == scope.LET {
+			return inferKind(b.Value, info, depth+1)
+		}
+		if b := info.Uses[e]; b != nil && b.Kind == scope.STRUCT {
+			return object.STRUCT_OBJ
+		}
+
+	case *ast.PrefixExpression:
+		if e.Operator == "!" {
+			return object.BOOLEAN_OBJ
+		}
+		return inferKind(e.Right, info, depth+1)
+
+	case *ast.InfixExpression:
+		switch e.Operator {
+		case "==", "!=", "<", ">", "in":
+			return object.BOOLEAN_OBJ
+		case "+", "-", "*", "/":
+			left := inferKind(e.Left, info, depth+1)
+			if left == inferKind(e.Right, info, depth+1) &&
+				(left == object.STRING_OBJ && e.Operator == "+" || left == object.INTEGER_OBJ) {
+				return left
+			}
+		}
+
+	case *ast.CallExpression:
+		// calling a struct makes an instance of it
+		if ident, ok := e.Function.(*ast.Identifier); ok {
+			if b := info.Uses[ident]; b != nil && b.Kind == scope.STRUCT {
+				return object.ObjectType(b.Name)
+			}
+		}
+	}
+
+	return ""
+}
+
+// symbols returns the outline of d: its top-level bindings and structs,
+// with the bindings in functions and the members of structs under them.
+func (d *document) symbols() []DocumentSymbol {
+	return d.statementSymbols(d.program.Statements, len(d.text))
+}
+
+func (d *document) statementSymbols(stmts []ast.Statement, end int) []DocumentSymbol {
+	symbols := []DocumentSymbol{}
+	ranges := d.statementRanges(stmts, end)
+
+	for i, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *ast.LetStatement:
+			if stmt.Pattern != nil {
+				ast.Inspect(stmt.Pattern, func(node ast.Node) bool {
+					if ident, ok := node.(*ast.Identifier); ok {
+						symbols = append(symbols, DocumentSymbol{
+							Name: ident.Value, Kind: SYMBOL_VARIABLE,
+							Range: ranges[i], SelectionRange: d.tokenRange(ident.Token),
+						})
+					}
+					return true
+				})
+				continue
+			}
+
+			symbol := DocumentSymbol{
+				Name: stmt.Name.Value, Kind: SYMBOL_VARIABLE,
+				Range: ranges[i], SelectionRange: d.tokenRange(stmt.Name.Token),
+			}
+			if kind := inferKind(stmt.Value, d.info, 0); kind != "" {
+				symbol.Detail = string(kind)
+			}
+			if fn, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+				symbol.Kind = SYMBOL_FUNCTION
+				symbol.Detail = "fn(" + ast.ParametersString(fn.Parameters, fn.Defaults, fn.Rest) + ")"
+				symbol.Children = d.functionSymbols(fn)
+			}
+			symbols = append(symbols, symbol)
+
+		case *ast.StructStatement:
+			symbol := DocumentSymbol{
+				Name: stmt.Name.Value, Kind: SYMBOL_STRUCT,
+				Range: ranges[i], SelectionRange: d.tokenRange(stmt.Name.Token),
+				Children: []DocumentSymbol{},
+			}
+			for _, field := range stmt.Fields {
+				symbol.Children = append(symbol.Children, DocumentSymbol{
+					Name: field.Value, Kind: SYMBOL_FIELD,
+					Range: d.tokenRange(field.Token), SelectionRange: d.tokenRange(field.Token),
+				})
+			}
+			for _, method := range stmt.Methods {
+				fn := method.Function
+				symbol.Children = append(symbol.Children, DocumentSymbol{
+					Name: method.Name.Value, Kind: SYMBOL_METHOD,
+					Detail:         "fn(" + ast.ParametersString(fn.Parameters, fn.Defaults, fn.Rest) + ")",
+					Range:          d.span(fn.Token.Offset, d.ends[d.closing(fn.Body.Token.Offset)]),
+					SelectionRange: d.tokenRange(method.Name.Token),
+					Children:       d.functionSymbols(fn),
+				})
+			}
+			symbols = append(symbols, symbol)
+		}
+	}
+
+	return symbols
+}
+
+func (d *document) functionSymbols(fn *ast.FunctionLiteral) []DocumentSymbol {
+	return d.statementSymbols(fn.Body.Statements, d.closing(fn.Body.Token.Offset))
+}
+
+// completions returns the keywords, builtins and names bound in d that
+// start with the word before pos.
+func (d *document) completions(pos Position) []CompletionItem {
+	word := d.wordBefore(pos)
+	items := map[string]CompletionItem{}
+
+	add := func(item CompletionItem) {
+		if _, seen := items[item.Label]; !seen && strings.HasPrefix(item.Label, word) {
+			items[item.Label] = item
+		}
+	}
+
+	for _, keyword := range token.Keywords() {
+		add(CompletionItem{Label: keyword, Kind: COMPLETION_KEYWORD})
+	}
+	for name := range builtins {
+		add(CompletionItem{Label: name, Kind: COMPLETION_FUNCTION, Detail: "builtin"})
+	}
+
+	if d.info != nil {
+		for _, b := range d.info.Bindings {
+			// the identifier being typed is not a completion of itself
+			if b.Ident.Token.Offset+len(b.Name) == d.offset(pos) {
+				continue
+			}
+			item := CompletionItem{Label: b.Name, Kind: COMPLETION_VARIABLE, Detail: describe(b, d.info)}
+			if b.Kind == scope.STRUCT {
+				item.Kind = COMPLETION_STRUCT
+			} else if isFunction(b.Value) {
+				item.Kind = COMPLETION_FUNCTION
+			}
+			add(item)
+		}
+	} else {
+		// without a parse, any identifier in the text will do
+		for _, tok := range d.tokens {
+			if tok.Type == token.IDENT && d.ends[tok.Offset] != d.offset(pos) {
+				add(CompletionItem{Label: tok.Literal, Kind: COMPLETION_VARIABLE})
+			}
+		}
+	}
+
+	result := []CompletionItem{}
+	for _, item := range items {
+		result = append(result, item)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Label < result[j].Label })
+	return result
+}
@@ -0,0 +1,135 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"sync"
+)
+
+const JSONRPC_VERSION = "2.0"
+
+// Error codes defined by JSON-RPC and the Language Server Protocol.
+const (
+	PARSE_ERROR      = -32700
+	INVALID_REQUEST  = -32600
+	METHOD_NOT_FOUND = -32601
+	INVALID_PARAMS   = -32602
+	INTERNAL_ERROR   = -32603
+)
+
+// Message is a JSON-RPC request, response or notification. A request has a
+// Method and an ID, a notification a Method only, and a response an ID and
+// a Result or an Error.
+type Message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  json.RawMessage  `json:"result,omitempty"`
+	Error   *ResponseError   `json:"error,omitempty"`
+}
+
+type ResponseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("%s (%d)", e.Message, e.Code)
+}
+
+// Conn reads and writes JSON-RPC messages, each preceded by a header giving
+// its length, the framing LSP uses over a byte stream.
+type Conn struct {
+	reader *textproto.Reader
+
+	mu     sync.Mutex // guards writer
+	writer io.Writer
+}
+
+func NewConn(r io.Reader, w io.Writer) *Conn {
+	return &Conn{reader: textproto.NewReader(bufio.NewReader(r)), writer: w}
+}
+
+// Read reads the next message.
+func (c *Conn) Read() (*Message, error) {
+	header, err := c.reader.ReadMIMEHeader()
+	if err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil || length < 0 {
+		return nil, errors.New("missing or invalid Content-Length header")
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(c.reader.R, body); err != nil {
+		return nil, err
+	}
+
+	msg := &Message{}
+	if err := json.Unmarshal(body, msg); err != nil {
+		return nil, &ResponseError{Code: PARSE_ERROR, Message: err.Error()}
+	}
+	return msg, nil
+}
+
+// Write writes msg. It is safe to call from several goroutines.
+func (c *Conn) Write(msg *Message) error {
+	msg.JSONRPC = JSONRPC_VERSION
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, err := fmt.Fprintf(c.writer, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = c.writer.Write(body)
+	return err
+}
+
+// Notify writes a notification.
+func (c *Conn) Notify(method string, params interface{}) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return c.Write(&Message{Method: method, Params: raw})
+}
+
+// Call writes a request with the given id.
+func (c *Conn) Call(id int, method string, params interface{}) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	rawID := json.RawMessage(strconv.Itoa(id))
+	return c.Write(&Message{ID: &rawID, Method: method, Params: raw})
+}
+
+// Reply writes the response to the request with the given id. A nil result
+// is sent as null, which is a valid result.
+func (c *Conn) Reply(id *json.RawMessage, result interface{}, respErr *ResponseError) error {
+	msg := &Message{ID: id, Error: respErr}
+	if respErr == nil {
+		raw, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		msg.Result = raw
+	}
+	return c.Write(msg)
+}
@@ -0,0 +1,188 @@
+package lsp
+
+// The parts of the Language Server Protocol the server uses. Positions count
+// lines from 0 and characters in UTF-16 code units, as the protocol does.
+
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+type Location struct {
+	URI   string `json:"uri"`
+	Range Range  `json:"range"`
+}
+
+type TextDocumentIdentifier struct {
+	URI string `json:"uri"`
+}
+
+type TextDocumentItem struct {
+	URI        string `json:"uri"`
+	LanguageID string `json:"languageId"`
+	Version    int    `json:"version"`
+	Text       string `json:"text"`
+}
+
+type VersionedTextDocumentIdentifier struct {
+	URI     string `json:"uri"`
+	Version int    `json:"version"`
+}
+
+// TextDocumentParams are the params of requests about a whole document.
+type TextDocumentParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+type TextDocumentPositionParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Position     Position               `json:"position"`
+}
+
+type DidOpenTextDocumentParams struct {
+	TextDocument TextDocumentItem `json:"textDocument"`
+}
+
+// TextDocumentContentChangeEvent holds the whole new text of a document,
+// since the server asks for full synchronization.
+type TextDocumentContentChangeEvent struct {
+	Text string `json:"text"`
+}
+
+type DidChangeTextDocumentParams struct {
+	TextDocument   VersionedTextDocumentIdentifier  `json:"textDocument"`
+	ContentChanges []TextDocumentContentChangeEvent `json:"contentChanges"`
+}
+
+type DidCloseTextDocumentParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+type DiagnosticSeverity int
+
+const (
+	SEVERITY_ERROR       DiagnosticSeverity = 1
+	SEVERITY_WARNING     DiagnosticSeverity = 2
+	SEVERITY_INFORMATION DiagnosticSeverity = 3
+	SEVERITY_HINT        DiagnosticSeverity = 4
+)
+
+type Diagnostic struct {
+	Range    Range              `json:"range"`
+	Severity DiagnosticSeverity `json:"severity"`
+	Source   string             `json:"source"`
+	Message  string             `json:"message"`
+}
+
+type PublishDiagnosticsParams struct {
+	URI         string       `json:"uri"`
+	Version     int          `json:"version"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+type ReferenceParams struct {
+	TextDocumentPositionParams
+	Context struct {
+		IncludeDeclaration bool `json:"includeDeclaration"`
+	} `json:"context"`
+}
+
+type MarkupContent struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+type Hover struct {
+	Contents MarkupContent `json:"contents"`
+	Range    Range         `json:"range"`
+}
+
+type SymbolKind int
+
+const (
+	SYMBOL_METHOD   SymbolKind = 6
+	SYMBOL_FIELD    SymbolKind = 8
+	SYMBOL_FUNCTION SymbolKind = 12
+	SYMBOL_VARIABLE SymbolKind = 13
+	SYMBOL_STRUCT   SymbolKind = 23
+)
+
+type DocumentSymbol struct {
+	Name           string           `json:"name"`
+	Detail         string           `json:"detail,omitempty"`
+	Kind           SymbolKind       `json:"kind"`
+	Range          Range            `json:"range"`
+	SelectionRange Range            `json:"selectionRange"`
+	Children       []DocumentSymbol `json:"children,omitempty"`
+}
+
+type CompletionItemKind int
+
+const (
+	COMPLETION_FUNCTION CompletionItemKind = 3
+	COMPLETION_VARIABLE CompletionItemKind = 6
+	COMPLETION_KEYWORD  CompletionItemKind = 14
+	COMPLETION_STRUCT   CompletionItemKind = 22
+)
+
+type CompletionItem struct {
+	Label  string             `json:"label"`
+	Kind   CompletionItemKind `json:"kind"`
+	Detail string             `json:"detail,omitempty"`
+}
+
+type CompletionList struct {
+	IsIncomplete bool             `json:"isIncomplete"`
+	Items        []CompletionItem `json:"items"`
+}
+
+type TextEdit struct {
+	Range   Range  `json:"range"`
+	NewText string `json:"newText"`
+}
+
+type SemanticTokensLegend struct {
+	TokenTypes     []string `json:"tokenTypes"`
+	TokenModifiers []string `json:"tokenModifiers"`
+}
+
+// SemanticTokens holds five numbers per token: its line and start relative
+// to the token before it, its length, its type and its modifiers, as
+// indexes into the legend.
+type SemanticTokens struct {
+	Data []int `json:"data"`
+}
+
+type TextDocumentSyncKind int
+
+const TEXT_DOCUMENT_SYNC_FULL TextDocumentSyncKind = 1
+
+type ServerCapabilities struct {
+	TextDocumentSync       TextDocumentSyncKind `json:"textDocumentSync"`
+	DefinitionProvider     bool                 `json:"definitionProvider"`
+	ReferencesProvider     bool                 `json:"referencesProvider"`
+	HoverProvider          bool                 `json:"hoverProvider"`
+	DocumentSymbolProvider bool                 `json:"documentSymbolProvider"`
+	CompletionProvider     struct {
+		TriggerCharacters []string `json:"triggerCharacters,omitempty"`
+	} `json:"completionProvider"`
+	DocumentFormattingProvider bool `json:"documentFormattingProvider"`
+	SemanticTokensProvider     struct {
+		Legend SemanticTokensLegend `json:"legend"`
+		Full   bool                 `json:"full"`
+	} `json:"semanticTokensProvider"`
+}
+
+type ServerInfo struct {
+	Name string `json:"name"`
+}
+
+type InitializeResult struct {
+	Capabilities ServerCapabilities `json:"capabilities"`
+	ServerInfo   ServerInfo         `json:"serverInfo"`
+}
@@ -0,0 +1,346 @@
+// Package lsp is a Language Server Protocol server for Monkey, giving
+// editors diagnostics, semantic highlighting, navigation between bindings
+// and their uses, hovers, an outline, completion and formatting.
+package lsp
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"monkey/format"
+	"sync"
+)
+
+// Server answers the requests of one client over a Conn.
+type Server struct {
+	conn *Conn
+
+	mu       sync.Mutex // guards the fields below
+	docs     map[string]*document
+	shutdown bool
+}
+
+func NewServer(r io.Reader, w io.Writer) *Server {
+	return &Server{conn: NewConn(r, w), docs: map[string]*document{}}
+}
+
+// handler answers a request or takes a notification, whose params are
+// decoded into a new value of the type the handler takes.
+type handler struct {
+	params func() interface{}
+	handle func(s *Server, params interface{}) (interface{}, *ResponseError)
+}
+
+var handlers = map[string]handler{}
+
+func init() {
+	handlers["initialize"] = handler{
+		func() interface{} { return &struct{}{} },
+		(*Server).initialize,
+	}
+	handlers["initialized"] = handler{
+		func() interface{} { return &struct{}{} },
+		func(*Server, interface{}) (interface{}, *ResponseError) { return nil, nil },
+	}
+	handlers["shutdown"] = handler{
+		func() interface{} { return &struct{}{} },
+		(*Server).shutdownRequest,
+	}
+	handlers["textDocument/didOpen"] = handler{
+		func() interface{} { return &DidOpenTextDocumentParams{} },
+		(*Server).didOpen,
+	}
+	handlers["textDocument/didChange"] = handler{
+		func() interface{} { return &DidChangeTextDocumentParams{} },
+		(*Server).didChange,
+	}
+	handlers["textDocument/didClose"] = handler{
+		func() interface{} { return &DidCloseTextDocumentParams{} },
+		(*Server).didClose,
+	}
+	handlers["textDocument/semanticTokens/full"] = handler{
+		func() interface{} { return &TextDocumentParams{} },
+		(*Server).semanticTokens,
+	}
+	handlers["textDocument/definition"] = handler{
+		func() interface{} { return &TextDocumentPositionParams{} },
+		(*Server).definition,
+	}
+	handlers["textDocument/references"] = handler{
+		func() interface{} { return &ReferenceParams{} },
+		(*Server).references,
+	}
+	handlers["textDocument/hover"] = handler{
+		func() interface{} { return &TextDocumentPositionParams{} },
+		(*Server).hover,
+	}
+	handlers["textDocument/documentSymbol"] = handler{
+		func() interface{} { return &TextDocumentParams{} },
+		(*Server).documentSymbol,
+	}
+	handlers["textDocument/completion"] = handler{
+		func() interface{} { return &TextDocumentPositionParams{} },
+		(*Server).completion,
+	}
+	handlers["textDocument/formatting"] = handler{
+		func() interface{} { return &TextDocumentParams{} },
+		(*Server).formatting,
+	}
+}
+
+// Run serves requests until the client sends exit or closes the
+// connection. It returns nil if the client asked for a shutdown first, as
+// the protocol says it should.
+func (s *Server) Run() error {
+	for {
+		msg, err := s.conn.Read()
+		if err != nil {
+			if respErr, ok := err.(*ResponseError); ok {
+				s.conn.Reply(nil, nil, respErr)
+				continue
+			}
+			return err
+		}
+
+		if msg.Method == "exit" {
+			s.mu.Lock()
+			shutdown := s.shutdown
+			s.mu.Unlock()
+
+			if !shutdown {
+				return errors.New("exit without shutdown")
+			}
+			return nil
+		}
+
+		s.serve(msg)
+	}
+}
+
+func (s *Server) serve(msg *Message) {
+	isRequest := msg.ID != nil
+
+	h, ok := handlers[msg.Method]
+	if !ok {
+		// notifications the server does not know are ignored
+		if isRequest {
+			s.conn.Reply(msg.ID, nil, &ResponseError{
+				Code: METHOD_NOT_FOUND, Message: "unknown method " + msg.Method,
+			})
+		}
+		return
+	}
+
+	s.mu.Lock()
+	shutdown := s.shutdown
+	s.mu.Unlock()
+	if shutdown && isRequest {
+		s.conn.Reply(msg.ID, nil, &ResponseError{
+			Code: INVALID_REQUEST, Message: "the server is shutting down",
+		})
+		return
+	}
+
+	params := h.params()
+	if len(msg.Params) > 0 {
+		if err := json.Unmarshal(msg.Params, params); err != nil {
+			if isRequest {
+				s.conn.Reply(msg.ID, nil, &ResponseError{Code: INVALID_PARAMS, Message: err.Error()})
+			}
+			return
+		}
+	}
+
+	result, respErr := h.handle(s, params)
+	if isRequest {
+		s.conn.Reply(msg.ID, result, respErr)
+	}
+}
+
+func (s *Server) initialize(interface{}) (interface{}, *ResponseError) {
+	result := InitializeResult{ServerInfo: ServerInfo{Name: "monkey"}}
+
+	caps := &result.Capabilities
+	caps.TextDocumentSync = TEXT_DOCUMENT_SYNC_FULL
+	caps.DefinitionProvider = true
+	caps.ReferencesProvider = true
+	caps.HoverProvider = true
+	caps.DocumentSymbolProvider = true
+	caps.DocumentFormattingProvider = true
+	caps.SemanticTokensProvider.Legend = SemanticTokensLegend{
+		TokenTypes:     semanticTypes,
+		TokenModifiers: semanticModifiers,
+	}
+	caps.SemanticTokensProvider.Full = true
+
+	return result, nil
+}
+
+func (s *Server) shutdownRequest(interface{}) (interface{}, *ResponseError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.shutdown = true
+	return nil, nil
+}
+
+// update replaces the document at uri and publishes its diagnostics.
+func (s *Server) update(uri string, version int, text string) {
+	d := newDocument(uri, version, text)
+
+	s.mu.Lock()
+	s.docs[uri] = d
+	s.mu.Unlock()
+
+	diagnostics := d.diagnostics
+	if diagnostics == nil {
+		diagnostics = []Diagnostic{}
+	}
+	s.conn.Notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI: uri, Version: version, Diagnostics: diagnostics,
+	})
+}
+
+func (s *Server) didOpen(params interface{}) (interface{}, *ResponseError) {
+	item := params.(*DidOpenTextDocumentParams).TextDocument
+	s.update(item.URI, item.Version, item.Text)
+	return nil, nil
+}
+
+func (s *Server) didChange(params interface{}) (interface{}, *ResponseError) {
+	p := params.(*DidChangeTextDocumentParams)
+	if len(p.ContentChanges) > 0 {
+		text := p.ContentChanges[len(p.ContentChanges)-1].Text
+		s.update(p.TextDocument.URI, p.TextDocument.Version, text)
+	}
+	return nil, nil
+}
+
+func (s *Server) didClose(params interface{}) (interface{}, *ResponseError) {
+	uri := params.(*DidCloseTextDocumentParams).TextDocument.URI
+
+	s.mu.Lock()
+	delete(s.docs, uri)
+	s.mu.Unlock()
+
+	s.conn.Notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI: uri, Diagnostics: []Diagnostic{},
+	})
+	return nil, nil
+}
+
+func (s *Server) document(uri string) (*document, *ResponseError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d, ok := s.docs[uri]
+	if !ok {
+		return nil, &ResponseError{Code: INVALID_PARAMS, Message: "unknown document " + uri}
+	}
+	return d, nil
+}
+
+func (s *Server) semanticTokens(params interface{}) (interface{}, *ResponseError) {
+	uri := params.(*TextDocumentParams).TextDocument.URI
+	d, err := s.document(uri)
+	if err != nil {
+		return nil, err
+	}
+	return d.semanticTokens(), nil
+}
+
+func (s *Server) definition(params interface{}) (interface{}, *ResponseError) {
+	p := params.(*TextDocumentPositionParams)
+	d, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	ident := d.identAt(p.Position)
+	if ident == nil {
+		return nil, nil
+	}
+	b := d.info.BindingAt(ident)
+	if b == nil {
+		return nil, nil
+	}
+	return d.location(b.Ident), nil
+}
+
+func (s *Server) references(params interface{}) (interface{}, *ResponseError) {
+	p := params.(*ReferenceParams)
+	d, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	locations := []Location{}
+	ident := d.identAt(p.Position)
+	if ident == nil {
+		return locations, nil
+	}
+	b := d.info.BindingAt(ident)
+	if b == nil {
+		return locations, nil
+	}
+
+	if p.Context.IncludeDeclaration {
+		locations = append(locations, d.location(b.Ident))
+	}
+	for _, use := range b.Uses {
+		locations = append(locations, d.location(use))
+	}
+	return locations, nil
+}
+
+func (s *Server) hover(params interface{}) (interface{}, *ResponseError) {
+	p := params.(*TextDocumentPositionParams)
+	d, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	if h := d.hover(p.Position); h != nil {
+		return h, nil
+	}
+	return nil, nil
+}
+
+func (s *Server) documentSymbol(params interface{}) (interface{}, *ResponseError) {
+	uri := params.(*TextDocumentParams).TextDocument.URI
+	d, err := s.document(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.info == nil {
+		return []DocumentSymbol{}, nil
+	}
+	return d.symbols(), nil
+}
+
+func (s *Server) completion(params interface{}) (interface{}, *ResponseError) {
+	p := params.(*TextDocumentPositionParams)
+	d, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	return CompletionList{Items: d.completions(p.Position)}, nil
+}
+
+// formatting replaces the whole text with its formatted version. A text
+// that does not parse is left alone.
+func (s *Server) formatting(params interface{}) (interface{}, *ResponseError) {
+	uri := params.(*TextDocumentParams).TextDocument.URI
+	d, err := s.document(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	formatted, fmtErr := format.Source([]byte(d.text))
+	if fmtErr != nil || string(formatted) == d.text {
+		return []TextEdit{}, nil
+	}
+	return []TextEdit{{Range: d.span(0, len(d.text)), NewText: string(formatted)}}, nil
+}
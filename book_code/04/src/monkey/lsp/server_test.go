@@ -0,0 +1,536 @@
+package lsp
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+const TEST_URI = "file:///test.code"
+
+// client talks to a Server running in the same process over pipes.
+type client struct {
+	t    *testing.T
+	conn *Conn
+
+	nextID        int
+	responses     chan *Message
+	notifications chan *Message
+	done          chan error // the result of the server's Run
+}
+
+func newClient(t *testing.T) *client {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+
+	c := &client{
+		t:             t,
+		conn:          NewConn(clientIn, clientOut),
+		responses:     make(chan *Message, 16),
+		notifications: make(chan *Message, 16),
+		done:          make(chan error, 1),
+	}
+
+	go func() {
+		c.done <- NewServer(serverIn, serverOut).Run()
+		serverOut.Close()
+	}()
+	go func() {
+		for {
+			msg, err := c.conn.Read()
+			if err != nil {
+				return
+			}
+			if msg.ID != nil {
+				c.responses <- msg
+			} else {
+				c.notifications <- msg
+			}
+		}
+	}()
+
+	c.call("initialize", map[string]interface{}{}, nil)
+	c.notify("initialized", map[string]interface{}{})
+	return c
+}
+
+// call sends a request and decodes its result into result, failing the
+// test if the server answers with an error.
+func (c *client) call(method string, params interface{}, result interface{}) {
+	c.t.Helper()
+
+	if respErr := c.request(method, params, result); respErr != nil {
+		c.t.Fatalf("%s failed: %s", method, respErr)
+	}
+}
+
+func (c *client) request(method string, params interface{}, result interface{}) *ResponseError {
+	c.t.Helper()
+
+	c.nextID += 1
+	if err := c.conn.Call(c.nextID, method, params); err != nil {
+		c.t.Fatalf("sending %s failed: %s", method, err)
+	}
+
+	select {
+	case msg := <-c.responses:
+		if string(*msg.ID) != strings.TrimSpace(string(mustMarshal(c.t, c.nextID))) {
+			c.t.Fatalf("response to %s has the wrong id %s", method, *msg.ID)
+		}
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if result != nil {
+			if err := json.Unmarshal(msg.Result, result); err != nil {
+				c.t.Fatalf("malformed result of %s: %s (%s)", method, err, msg.Result)
+			}
+		}
+		return nil
+	case <-time.After(5 * time.Second):
+		c.t.Fatalf("no response to %s", method)
+		return nil
+	}
+}
+
+func (c *client) notify(method string, params interface{}) {
+	c.t.Helper()
+
+	if err := c.conn.Notify(method, params); err != nil {
+		c.t.Fatalf("sending %s failed: %s", method, err)
+	}
+}
+
+func (c *client) diagnostics() PublishDiagnosticsParams {
+	c.t.Helper()
+
+	select {
+	case msg := <-c.notifications:
+		if msg.Method != "textDocument/publishDiagnostics" {
+			c.t.Fatalf("unexpected notification %s", msg.Method)
+		}
+		params := PublishDiagnosticsParams{}
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			c.t.Fatalf("malformed diagnostics: %s", err)
+		}
+		return params
+	case <-time.After(5 * time.Second):
+		c.t.Fatalf("no diagnostics published")
+		return PublishDiagnosticsParams{}
+	}
+}
+
+// open opens a document with text and returns its diagnostics.
+func (c *client) open(text string) []Diagnostic {
+	c.t.Helper()
+
+	c.notify("textDocument/didOpen", DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: TEST_URI, LanguageID: "monkey", Version: 1, Text: text},
+	})
+	return c.diagnostics().Diagnostics
+}
+
+func (c *client) close() {
+	c.t.Helper()
+
+	c.call("shutdown", nil, nil)
+	c.notify("exit", nil)
+	select {
+	case err := <-c.done:
+		if err != nil {
+			c.t.Errorf("server did not exit cleanly: %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		c.t.Fatalf("server did not exit")
+	}
+}
+
+func mustMarshal(t *testing.T, v interface{}) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func at(line, character int) TextDocumentPositionParams {
+	return TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: TEST_URI},
+		Position:     Position{Line: line, Character: character},
+	}
+}
+
+func span(line, start, end int) Range {
+	return Range{Start: Position{line, start}, End: Position{line, end}}
+}
+
+func TestInitialize(t *testing.T) {
+	c := newClient(t)
+	defer c.close()
+
+	result := InitializeResult{}
+	c.call("initialize", map[string]interface{}{}, &result)
+
+	caps := result.Capabilities
+	if caps.TextDocumentSync != TEXT_DOCUMENT_SYNC_FULL || !caps.HoverProvider ||
+		!caps.DefinitionProvider || !caps.ReferencesProvider || !caps.DocumentSymbolProvider ||
+		!caps.DocumentFormattingProvider || !caps.SemanticTokensProvider.Full {
+		t.Errorf("missing capabilities: %+v", caps)
+	}
+	if len(caps.SemanticTokensProvider.Legend.TokenTypes) != len(semanticTypes) {
+		t.Errorf("wrong legend: %+v", caps.SemanticTokensProvider.Legend)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	c := newClient(t)
+	defer c.close()
+
+	diagnostics := c.open("let x = 1;\nlet = 2;")
+	if len(diagnostics) == 0 {
+		t.Fatalf("expected diagnostics")
+	}
+	d := diagnostics[0]
+	if d.Range != span(1, 4, 5) || d.Severity != SEVERITY_ERROR ||
+		d.Message != "expected next token to be IDENT, got = instead" {
+		t.Errorf("wrong diagnostic: %+v", d)
+	}
+
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   VersionedTextDocumentIdentifier{URI: TEST_URI, Version: 2},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "let x = 1;\nlet y = 2;"}},
+	})
+	published := c.diagnostics()
+	if published.Version != 2 || len(published.Diagnostics) != 0 {
+		t.Errorf("expected no diagnostics for version 2. got=%+v", published)
+	}
+
+	c.notify("textDocument/didClose", DidCloseTextDocumentParams{
+		TextDocument: TextDocumentIdentifier{URI: TEST_URI},
+	})
+	if published := c.diagnostics(); len(published.Diagnostics) != 0 {
+		t.Errorf("expected diagnostics to be cleared on close. got=%+v", published)
+	}
+}
+
+const program = `let add = fn(a, b) {
+  a + b
+};
+let total = add(1, 2);
+add(total, total)
+`
+
+func TestDefinition(t *testing.T) {
+	c := newClient(t)
+	defer c.close()
+	c.open(program)
+
+	tests := []struct {
+		position TextDocumentPositionParams
+		expected Range
+	}{
+		{at(1, 2), span(0, 13, 14)},
+		{at(1, 7), span(0, 16, 17)},
+		{at(4, 1), span(0, 4, 7)},
+		{at(4, 12), span(3, 4, 9)},
+		{at(3, 9), span(3, 4, 9)}, // just after the name
+	}
+
+	for _, tt := range tests {
+		location := Location{}
+		c.call("textDocument/definition", tt.position, &location)
+		if location.URI != TEST_URI || location.Range != tt.expected {
+			t.Errorf("definition at %+v wrong. expected=%+v, got=%+v",
+				tt.position.Position, tt.expected, location)
+		}
+	}
+
+	var none *Location
+	c.call("textDocument/definition", at(1, 4), &none)
+	if none != nil {
+		t.Errorf("expected no definition for an operator. got=%+v", none)
+	}
+}
+
+func TestReferences(t *testing.T) {
+	c := newClient(t)
+	defer c.close()
+	c.open(program)
+
+	params := ReferenceParams{TextDocumentPositionParams: at(4, 1)}
+	params.Context.IncludeDeclaration = true
+
+	locations := []Location{}
+	c.call("textDocument/references", params, &locations)
+
+	expected := []Range{span(0, 4, 7), span(3, 12, 15), span(4, 0, 3)}
+	if len(locations) != len(expected) {
+		t.Fatalf("wrong number of references. expected=%d, got=%+v", len(expected), locations)
+	}
+	for i, e := range expected {
+		if locations[i].Range != e {
+			t.Errorf("reference %d wrong. expected=%+v, got=%+v", i, e, locations[i].Range)
+		}
+	}
+
+	params = ReferenceParams{TextDocumentPositionParams: at(0, 13)}
+	c.call("textDocument/references", params, &locations)
+	if len(locations) != 1 || locations[0].Range != span(1, 2, 3) {
+		t.Errorf("wrong references of a. got=%+v", locations)
+	}
+}
+
+func TestInterpolatedReferences(t *testing.T) {
+	c := newClient(t)
+	defer c.close()
+	c.open("let name = 1;\nputs(\"hi ${name}!\");\n")
+
+	params := ReferenceParams{TextDocumentPositionParams: at(1, 13)}
+	params.Context.IncludeDeclaration = true
+
+	locations := []Location{}
+	c.call("textDocument/references", params, &locations)
+
+	expected := []Range{span(0, 4, 8), span(1, 11, 15)}
+	if len(locations) != len(expected) {
+		t.Fatalf("wrong number of references. expected=%d, got=%+v", len(expected), locations)
+	}
+	for i, e := range expected {
+		if locations[i].Range != e {
+			t.Errorf("reference %d wrong. expected=%+v, got=%+v", i, e, locations[i].Range)
+		}
+	}
+}
+
+func TestHover(t *testing.T) {
+	c := newClient(t)
+	defer c.close()
+	c.open(`let n = 1 + 2;
+let s = "a";
+let f = fn(x, y = 2) { x };
+struct Point { x, y }
+let p = Point(n, len(s));`)
+
+	tests := []struct {
+		position TextDocumentPositionParams
+		expected string
+	}{
+		{at(0, 4), "let n: INTEGER"},
+		{at(1, 4), "let s: STRING"},
+		{at(2, 4), "let f: FUNCTION fn(x, y = 2)"},
+		{at(2, 23), "parameter x"},
+		{at(3, 7), "struct Point"},
+		{at(4, 4), "let p: Point"},
+		{at(4, 17), "builtin len"},
+	}
+
+	for _, tt := range tests {
+		hover := Hover{}
+		c.call("textDocument/hover", tt.position, &hover)
+		expected := "```monkey\n" + tt.expected + "\n```"
+		if hover.Contents.Value != expected {
+			t.Errorf("hover at %+v wrong. expected=%q, got=%q",
+				tt.position.Position, expected, hover.Contents.Value)
+		}
+	}
+}
+
+func TestDocumentSymbols(t *testing.T) {
+	c := newClient(t)
+	defer c.close()
+	c.open(`let f = fn() {
+  let inner = 1;
+  inner
+};
+struct Point {
+  x, y;
+  fn norm() { self.x }
+}
+let [a, b] = [1, 2];`)
+
+	symbols := []DocumentSymbol{}
+	c.call("textDocument/documentSymbol", TextDocumentParams{TextDocumentIdentifier{TEST_URI}}, &symbols)
+
+	if len(symbols) != 4 {
+		t.Fatalf("wrong number of symbols. got=%+v", symbols)
+	}
+
+	f := symbols[0]
+	if f.Name != "f" || f.Kind != SYMBOL_FUNCTION || f.Range != (Range{Position{0, 0}, Position{3, 2}}) ||
+		f.SelectionRange != span(0, 4, 5) {
+		t.Errorf("wrong symbol for f: %+v", f)
+	}
+	if len(f.Children) != 1 || f.Children[0].Name != "inner" || f.Children[0].Detail != "INTEGER" ||
+		f.Children[0].Range != span(1, 2, 16) {
+		t.Errorf("wrong children of f: %+v", f.Children)
+	}
+
+	point := symbols[1]
+	if point.Name != "Point" || point.Kind != SYMBOL_STRUCT || len(point.Children) != 3 {
+		t.Fatalf("wrong symbol for Point: %+v", point)
+	}
+	if point.Children[0].Kind != SYMBOL_FIELD || point.Children[2].Name != "norm" ||
+		point.Children[2].Kind != SYMBOL_METHOD || point.Children[2].Range != span(6, 2, 22) {
+		t.Errorf("wrong children of Point: %+v", point.Children)
+	}
+
+	if symbols[2].Name != "a" || symbols[3].Name != "b" {
+		t.Errorf("wrong symbols for a destructuring let: %+v", symbols[2:])
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	c := newClient(t)
+	defer c.close()
+	c.open("let apple = 1;\nlet apply = fn(f) { f };\nap")
+
+	list := CompletionList{}
+	c.call("textDocument/completion", at(2, 2), &list)
+
+	labels := []string{}
+	for _, item := range list.Items {
+		labels = append(labels, item.Label)
+		if !strings.HasPrefix(item.Label, "ap") {
+			t.Errorf("completion %q does not start with ap", item.Label)
+		}
+	}
+	for _, expected := range []string{"apple", "apply"} {
+		found := false
+		for _, label := range labels {
+			found = found || label == expected
+		}
+		if !found {
+			t.Errorf("missing completion %s in %v", expected, labels)
+		}
+	}
+
+	c.call("textDocument/completion", at(1, 13), &list)
+	for _, item := range list.Items {
+		if item.Label == "fn" && item.Kind != COMPLETION_KEYWORD {
+			t.Errorf("fn completed as %d", item.Kind)
+		}
+	}
+}
+
+func TestFormatting(t *testing.T) {
+	c := newClient(t)
+	defer c.close()
+	c.open("let x=1\nlet y =  x")
+
+	edits := []TextEdit{}
+	c.call("textDocument/formatting", TextDocumentParams{TextDocumentIdentifier{TEST_URI}}, &edits)
+
+	if len(edits) != 1 {
+		t.Fatalf("expected one edit. got=%+v", edits)
+	}
+	if edits[0].Range != (Range{Position{0, 0}, Position{1, 10}}) ||
+		edits[0].NewText != "let x = 1;\nlet y = x;\n" {
+		t.Errorf("wrong edit: %+v", edits[0])
+	}
+}
+
+func TestSemanticTokens(t *testing.T) {
+	c := newClient(t)
+	defer c.close()
+	c.open("let x = 1; // c\nlen(x, `a\nb`)")
+
+	tokens := SemanticTokens{}
+	c.call("textDocument/semanticTokens/full", TextDocumentParams{TextDocumentIdentifier{TEST_URI}}, &tokens)
+
+	keyword, variable, function := semanticIndex("keyword"), semanticIndex("variable"), semanticIndex("function")
+	number, str, operator, comment := semanticIndex("number"), semanticIndex("string"),
+		semanticIndex("operator"), semanticIndex("comment")
+
+	expected := []int{
+		0, 0, 3, keyword, 0,
+		0, 4, 1, variable, MODIFIER_DECLARATION,
+		0, 2, 1, operator, 0,
+		0, 2, 1, number, 0,
+		0, 3, 4, comment, 0,
+		1, 0, 3, function, MODIFIER_DEFAULT_LIBRARY,
+		0, 4, 1, variable, 0,
+		0, 3, 2, str, 0,
+		1, 0, 2, str, 0,
+	}
+	if len(tokens.Data) != len(expected) {
+		t.Fatalf("wrong semantic tokens. expected=%v, got=%v", expected, tokens.Data)
+	}
+	for i := range expected {
+		if tokens.Data[i] != expected[i] {
+			t.Fatalf("wrong semantic tokens. expected=%v, got=%v", expected, tokens.Data)
+		}
+	}
+}
+
+func TestUnknownDocumentAndMethod(t *testing.T) {
+	c := newClient(t)
+	defer c.close()
+
+	if err := c.request("textDocument/hover", at(0, 0), nil); err == nil || err.Code != INVALID_PARAMS {
+		t.Errorf("expected an invalid params error for an unknown document. got=%v", err)
+	}
+	if err := c.request("workspace/nothing", nil, nil); err == nil || err.Code != METHOD_NOT_FOUND {
+		t.Errorf("expected a method not found error. got=%v", err)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	c := newClient(t)
+
+	c.call("shutdown", nil, nil)
+	if err := c.request("textDocument/hover", at(0, 0), nil); err == nil || err.Code != INVALID_REQUEST {
+		t.Errorf("expected requests after shutdown to fail. got=%v", err)
+	}
+
+	c.notify("exit", nil)
+	select {
+	case err := <-c.done:
+		if err != nil {
+			t.Errorf("expected a clean exit. got=%s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("server did not exit")
+	}
+}
+
+func TestExitWithoutShutdown(t *testing.T) {
+	c := newClient(t)
+
+	c.notify("exit", nil)
+	select {
+	case err := <-c.done:
+		if err == nil {
+			t.Errorf("expected an error exiting without shutdown")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("server did not exit")
+	}
+}
+
+func TestPositions(t *testing.T) {
+	d := newDocument(TEST_URI, 1, "let s = \"ü😀\"; s\nlet t = 1;")
+
+	tests := []struct {
+		offset   int
+		expected Position
+	}{
+		{0, Position{0, 0}},
+		{8, Position{0, 8}},
+		{9, Position{0, 9}},   // ü, two bytes and one UTF-16 unit
+		{11, Position{0, 10}}, // 😀, four bytes and two UTF-16 units
+		{15, Position{0, 12}},
+		{18, Position{0, 15}},
+		{19, Position{0, 16}},
+		{20, Position{1, 0}},
+	}
+
+	for _, tt := range tests {
+		if pos := d.position(tt.offset); pos != tt.expected {
+			t.Errorf("position(%d) wrong. expected=%+v, got=%+v", tt.offset, tt.expected, pos)
+		}
+		if offset := d.offset(tt.expected); offset != tt.offset {
+			t.Errorf("offset(%+v) wrong. expected=%d, got=%d", tt.expected, tt.offset, offset)
+		}
+	}
+}
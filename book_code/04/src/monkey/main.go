@@ -1,13 +1,70 @@
 package main
 
 import (
+	"bufio"
+	"errors"
+	"flag"
 	"fmt"
+	"io/ioutil"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
 	"monkey/repl"
 	"os"
 	"os/user"
+	"strings"
 )
 
+// listFlag is a flag holding a comma-separated list. It can be given more
+// than once.
+type listFlag struct {
+	list *[]string
+}
+
+func (lf listFlag) String() string {
+	if lf.list == nil {
+		return ""
+	}
+	return strings.Join(*lf.list, ",")
+}
+
+func (lf listFlag) Set(value string) error {
+	items := strings.Split(value, ",")
+	for _, item := range items {
+		if item == "" {
+			return errors.New("empty item in list")
+		}
+	}
+	*lf.list = append(*lf.list, items...)
+	return nil
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "fmt":
+			os.Exit(runFmt(os.Args[2:]))
+		case "lint":
+			os.Exit(runLint(os.Args[2:]))
+		case "lsp":
+			os.Exit(runLSP(os.Args[2:]))
+		case "debug":
+			os.Exit(runDebug(os.Args[2:]))
+		case "dap":
+			os.Exit(runDAP(os.Args[2:]))
+		}
+	}
+
+	ctx := evaluatorFlags(flag.CommandLine)
+	profileFlags(flag.CommandLine)
+	flag.Parse()
+
+	ctx.Stdin = bufio.NewReader(os.Stdin)
+	if flag.NArg() > 0 {
+		os.Exit(runScript(flag.Arg(0), ctx))
+	}
+
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
@@ -15,5 +72,69 @@ func main() {
 	fmt.Printf("Hello %s! This is the Monkey programming language!\n",
 		user.Username)
 	fmt.Printf("Feel free to type in commands\n")
-	repl.Start(os.Stdin, os.Stdout)
+	repl.Start(os.Stdin, os.Stdout, ctx)
+}
+
+// evaluatorFlags defines the evaluation and capability flags on flags and
+// returns the context they fill in.
+func evaluatorFlags(flags *flag.FlagSet) *evaluator.Context {
+	ctx := &evaluator.Context{}
+	flags.BoolVar(&ctx.StrictIndexing, "strict-index", false,
+		"make out-of-range array and string indexes an error instead of null")
+	flags.BoolVar(&ctx.CheckedArithmetic, "checked-arithmetic", false,
+		"make integer overflow an error instead of promoting to big integers")
+	flags.Var(listFlag{&ctx.Allowed.ReadPaths}, "allow-read",
+		"comma-separated `paths` that read_file and list_dir may read")
+	flags.Var(listFlag{&ctx.Allowed.WritePaths}, "allow-write",
+		"comma-separated `paths` that write_file may write")
+	flags.Var(listFlag{&ctx.Allowed.EnvNames}, "allow-env",
+		"comma-separated environment variable `names` that getenv may read, or * for all")
+	flags.BoolVar(&ctx.Allowed.Stdin, "allow-stdin", false,
+		"let read_line read standard input")
+	flags.BoolVar(&ctx.Allowed.Exit, "allow-exit", false,
+		"let exit end the process")
+	return ctx
+}
+
+// printParseErrors reports the errors p found in the file at path, each at
+// the line and column of its token.
+func printParseErrors(path string, p *parser.Parser) {
+	for i, msg := range p.Errors() {
+		tok := p.ErrorTokens()[i]
+		fmt.Fprintf(os.Stderr, "%s:%d:%d: %s\n", path, tok.Line, tok.Column, msg)
+	}
+}
+
+// runScript evaluates the program in the file at path with ctx and returns
+// the exit status for the process.
+func runScript(path string, ctx *evaluator.Context) int {
+	source, err := ioutil.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	p := parser.New(lexer.New(string(source)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		printParseErrors(path, p)
+		return 1
+	}
+
+	var evaluated object.Object
+	if profileOutput != "" {
+		evaluated, err = evalProfiled(path, source, program, ctx)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	} else {
+		evaluated = evaluator.Eval(program, evaluator.NewEnvironment(ctx))
+	}
+	if errObj, ok := evaluated.(*object.Error); ok {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", path, errObj.Message)
+		return 1
+	}
+
+	return 0
 }
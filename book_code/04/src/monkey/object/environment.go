@@ -1,8 +1,11 @@
 package object
 
+import "sort"
+
 func NewEnclosedEnvironment(outer *Environment) *Environment {
 	env := NewEnvironment()
 	env.outer = outer
+	env.context = outer.context
 	return env
 }
 
@@ -12,8 +15,9 @@ func NewEnvironment() *Environment {
 }
 
 type Environment struct {
-	store map[string]Object
-	outer *Environment
+	store   map[string]Object
+	outer   *Environment
+	context interface{}
 }
 
 func (e *Environment) Get(name string) (Object, bool) {
@@ -28,3 +32,50 @@ func (e *Environment) Set(name string, val Object) Object {
 	e.store[name] = val
 	return val
 }
+
+// Names returns the names bound in this environment and the environments
+// enclosing it, sorted.
+func (e *Environment) Names() []string {
+	seen := make(map[string]bool)
+	for env := e; env != nil; env = env.outer {
+		for name := range env.store {
+			seen[name] = true
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// SetContext sets the context of the evaluation e belongs to. Environments
+// enclosed in e afterwards share it. The evaluator decides what it holds.
+func (e *Environment) SetContext(context interface{}) {
+	e.context = context
+}
+
+func (e *Environment) Context() interface{} {
+	return e.context
+}
+
+// Outer returns the environment e is enclosed in, or nil if it is the
+// outermost one.
+func (e *Environment) Outer() *Environment {
+	return e.outer
+}
+
+// LocalNames returns the names bound in this environment itself, without
+// those of the environments enclosing it, sorted.
+func (e *Environment) LocalNames() []string {
+	names := make([]string, 0, len(e.store))
+	for name := range e.store {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,104 @@
+package object
+
+// objectPair is a pair of compound objects being compared by Equal.
+type objectPair struct {
+	a, b Object
+}
+
+// Equal reports whether a and b are structurally equal. Integers, booleans
+// and strings compare by value, and arrays, hashes, sets and struct instances
+// compare by their contents. Everything else is only equal to itself.
+//
+// A pair of objects that is reached again while it is still being compared
+// is taken to be equal, so cyclic structures are compared without looping,
+// whether the cycle runs through values or through hash keys.
+func Equal(a, b Object) bool {
+	return equal(a, b, nil)
+}
+
+func equal(a, b Object, seen map[objectPair]bool) bool {
+	if a == b {
+		return true
+	}
+
+	switch a := a.(type) {
+	case *Integer:
+		b, ok := b.(*Integer)
+		return ok && a.Value == b.Value
+	case *BigInteger:
+		b, ok := b.(*BigInteger)
+		return ok && a.Value.Cmp(b.Value) == 0
+	case *Boolean:
+		b, ok := b.(*Boolean)
+		return ok && a.Value == b.Value
+	case *String:
+		b, ok := b.(*String)
+		return ok && a.Value == b.Value
+	}
+
+	if a.Type() != b.Type() {
+		return false
+	}
+
+	pair := objectPair{a, b}
+	if seen[pair] {
+		return true
+	}
+	if seen == nil {
+		seen = make(map[objectPair]bool)
+	}
+	seen[pair] = true
+	defer delete(seen, pair)
+
+	switch a := a.(type) {
+	case *Array:
+		b := b.(*Array)
+		if len(a.Elements) != len(b.Elements) {
+			return false
+		}
+		for i, e := range a.Elements {
+			if !equal(e, b.Elements[i], seen) {
+				return false
+			}
+		}
+		return true
+	case *Hash:
+		b := b.(*Hash)
+		if a.Len() != b.Len() {
+			return false
+		}
+		for _, pair := range a.Pairs() {
+			key := pair.Key.(Hashable)
+			_, position := b.find(key.HashKey(), key, seen)
+			if position < 0 || !equal(pair.Value, b.pairs[position].Value, seen) {
+				return false
+			}
+		}
+		return true
+	case *Set:
+		b := b.(*Set)
+		if a.Len() != b.Len() {
+			return false
+		}
+		for _, element := range a.Elements() {
+			hashable := element.(Hashable)
+			if _, position := b.elements.find(hashable.HashKey(), hashable, seen); position < 0 {
+				return false
+			}
+		}
+		return true
+	case *Instance:
+		b := b.(*Instance)
+		if a.Struct != b.Struct {
+			return false
+		}
+		for i, value := range a.Values {
+			if !equal(value, b.Values[i], seen) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,158 @@
+package object
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"strings"
+)
+
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+// Hash keeps its pairs in insertion order. Lookups go through buckets of the
+// positions of the keys that share a HashKey, and keys within a bucket are
+// compared by value.
+type Hash struct {
+	Frozen bool
+
+	pairs   []HashPair // deleted pairs leave holes with a nil Key
+	holes   int
+	buckets map[HashKey][]int
+}
+
+func NewHash() *Hash {
+	return &Hash{buckets: make(map[HashKey][]int)}
+}
+
+// find returns the position in the bucket and in pairs of the key equal to
+// key, or -1 for both if there is none. seen is passed on to equal, so that
+// comparing keys inside a comparison keeps its guard against cycles.
+func (h *Hash) find(hashed HashKey, key Hashable, seen map[objectPair]bool) (int, int) {
+	for i, position := range h.buckets[hashed] {
+		if equal(key, h.pairs[position].Key, seen) {
+			return i, position
+		}
+	}
+
+	return -1, -1
+}
+
+// Get returns the pair whose key is equal to key.
+func (h *Hash) Get(key Hashable) (HashPair, bool) {
+	_, position := h.find(key.HashKey(), key, nil)
+	if position < 0 {
+		return HashPair{}, false
+	}
+
+	return h.pairs[position], true
+}
+
+// Set stores value under key. An equal key that is already stored keeps its
+// place in the order and gets the new value.
+func (h *Hash) Set(key Hashable, value Object) {
+	if h.buckets == nil {
+		h.buckets = make(map[HashKey][]int)
+	}
+
+	hashed := key.HashKey()
+	if _, position := h.find(hashed, key, nil); position >= 0 {
+		h.pairs[position].Value = value
+		return
+	}
+
+	h.buckets[hashed] = append(h.buckets[hashed], len(h.pairs))
+	h.pairs = append(h.pairs, HashPair{Key: key, Value: value})
+}
+
+// Delete removes the pair whose key is equal to key and returns it.
+func (h *Hash) Delete(key Hashable) (HashPair, bool) {
+	hashed := key.HashKey()
+	i, position := h.find(hashed, key, nil)
+	if position < 0 {
+		return HashPair{}, false
+	}
+
+	pair := h.pairs[position]
+	h.pairs[position] = HashPair{}
+	h.holes += 1
+
+	bucket := h.buckets[hashed]
+	if len(bucket) == 1 {
+		delete(h.buckets, hashed)
+	} else {
+		h.buckets[hashed] = append(bucket[:i:i], bucket[i+1:]...)
+	}
+
+	if h.holes > len(h.pairs)/2 {
+		h.compact()
+	}
+
+	return pair, true
+}
+
+// compact closes the holes left by Delete and renumbers the buckets.
+func (h *Hash) compact() {
+	pairs := make([]HashPair, 0, len(h.pairs)-h.holes)
+	buckets := make(map[HashKey][]int, len(h.buckets))
+
+	for _, pair := range h.pairs {
+		if pair.Key == nil {
+			continue
+		}
+		hashed := pair.Key.(Hashable).HashKey()
+		buckets[hashed] = append(buckets[hashed], len(pairs))
+		pairs = append(pairs, pair)
+	}
+
+	h.pairs = pairs
+	h.buckets = buckets
+	h.holes = 0
+}
+
+// Pairs returns the pairs in insertion order. The slice must not be
+// modified.
+func (h *Hash) Pairs() []HashPair {
+	if h.holes > 0 {
+		h.compact()
+	}
+
+	return h.pairs
+}
+
+func (h *Hash) Len() int {
+	return len(h.pairs) - h.holes
+}
+
+func (h *Hash) Type() ObjectType { return HASH_OBJ }
+func (h *Hash) Inspect() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for _, pair := range h.Pairs() {
+		pairs = append(pairs, fmt.Sprintf("%s: %s",
+			pair.Key.Inspect(), pair.Value.Inspect()))
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// HashKey adds up the hashes of the pairs, so that it does not depend on
+// their order.
+func (h *Hash) HashKey() HashKey {
+	var sum uint64
+	for _, pair := range h.Pairs() {
+		pairHash := fnv.New64a()
+		writeHashKey(pairHash, pair.Key)
+		writeHashKey(pairHash, pair.Value)
+		sum += pairHash.Sum64()
+	}
+
+	return HashKey{Type: h.Type(), Value: sum}
+}
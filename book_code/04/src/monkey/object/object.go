@@ -2,13 +2,18 @@ package object
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
+	"hash"
 	"hash/fnv"
+	"math/big"
 	"monkey/ast"
 	"strings"
 )
 
-type BuiltinFunction func(args ...Object) Object
+// BuiltinFunction is given the environment it is called from, through which
+// the evaluator finds the settings of the evaluation.
+type BuiltinFunction func(env *Environment, args ...Object) Object
 
 type ObjectType string
 
@@ -16,9 +21,10 @@ const (
 	NULL_OBJ  = "NULL"
 	ERROR_OBJ = "ERROR"
 
-	INTEGER_OBJ = "INTEGER"
-	BOOLEAN_OBJ = "BOOLEAN"
-	STRING_OBJ  = "STRING"
+	INTEGER_OBJ     = "INTEGER"
+	BIG_INTEGER_OBJ = "BIG_INTEGER"
+	BOOLEAN_OBJ     = "BOOLEAN"
+	STRING_OBJ      = "STRING"
 
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
 
@@ -27,6 +33,11 @@ const (
 
 	ARRAY_OBJ = "ARRAY"
 	HASH_OBJ  = "HASH"
+	SET_OBJ   = "SET"
+
+	STRUCT_OBJ       = "STRUCT"
+	INSTANCE_OBJ     = "INSTANCE"
+	BOUND_METHOD_OBJ = "BOUND_METHOD"
 )
 
 type HashKey struct {
@@ -34,10 +45,67 @@ type HashKey struct {
 	Value uint64
 }
 
+// Hashable objects can be used as hash keys. Keys with the same HashKey are
+// told apart by comparing them by value, so HashKey may collide.
 type Hashable interface {
+	Object
 	HashKey() HashKey
 }
 
+// AsHashable returns obj as a Hashable if it can be used as a hash key.
+// Hashes can only be keys once frozen, and arrays, hashes and instances only
+// if they hold no hash that is not frozen, however deeply, so that a key
+// never changes while it is stored. Sets, which can hold themselves, are not
+// looked into.
+func AsHashable(obj Object) (Hashable, bool) {
+	if !immutable(obj) {
+		return nil, false
+	}
+
+	hashable, ok := obj.(Hashable)
+	return hashable, ok
+}
+
+// immutable reports whether obj is no unfrozen hash and holds none.
+func immutable(obj Object) bool {
+	switch obj := obj.(type) {
+	case *Hash:
+		if !obj.Frozen {
+			return false
+		}
+		for _, pair := range obj.Pairs() {
+			if !immutable(pair.Key) || !immutable(pair.Value) {
+				return false
+			}
+		}
+	case *Array:
+		for _, e := range obj.Elements {
+			if !immutable(e) {
+				return false
+			}
+		}
+	case *Instance:
+		for _, value := range obj.Values {
+			if !immutable(value) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// writeHashKey feeds the type of value and, if it is hashable, its hash key
+// into h. Compound objects use it to hash their contents.
+func writeHashKey(h hash.Hash64, value Object) {
+	h.Write([]byte(value.Type()))
+
+	if hashable, ok := AsHashable(value); ok {
+		var buf [8]byte
+		binary.LittleEndian.PutUint64(buf[:], hashable.HashKey().Value)
+		h.Write(buf[:])
+	}
+}
+
 type Object interface {
 	Type() ObjectType
 	Inspect() string
@@ -53,6 +121,21 @@ func (i *Integer) HashKey() HashKey {
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
 
+// BigInteger holds integers that do not fit in an int64. Arithmetic results
+// that fit are always turned back into an Integer.
+type BigInteger struct {
+	Value *big.Int
+}
+
+func (bi *BigInteger) Type() ObjectType { return BIG_INTEGER_OBJ }
+func (bi *BigInteger) Inspect() string  { return bi.Value.String() }
+func (bi *BigInteger) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write([]byte(bi.Value.String()))
+
+	return HashKey{Type: bi.Type(), Value: h.Sum64()}
+}
+
 type Boolean struct {
 	Value bool
 }
@@ -91,7 +174,10 @@ func (e *Error) Type() ObjectType { return ERROR_OBJ }
 func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
 
 type Function struct {
+	Name       string // the name a let or struct gave it, or "" if anonymous
 	Parameters []*ast.Identifier
+	Defaults   map[string]ast.Expression
+	Rest       *ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
 }
@@ -100,14 +186,9 @@ func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
 func (f *Function) Inspect() string {
 	var out bytes.Buffer
 
-	params := []string{}
-	for _, p := range f.Parameters {
-		params = append(params, p.String())
-	}
-
 	out.WriteString("fn")
 	out.WriteString("(")
-	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(ast.ParametersString(f.Parameters, f.Defaults, f.Rest))
 	out.WriteString(") {\n")
 	out.WriteString(f.Body.String())
 	out.WriteString("\n}")
@@ -155,28 +236,79 @@ func (ao *Array) Inspect() string {
 	return out.String()
 }
 
-type HashPair struct {
-	Key   Object
-	Value Object
+func (ao *Array) HashKey() HashKey {
+	h := fnv.New64a()
+	for _, e := range ao.Elements {
+		writeHashKey(h, e)
+	}
+
+	return HashKey{Type: ao.Type(), Value: h.Sum64()}
+}
+
+type Struct struct {
+	Name    string
+	Fields  []string
+	Methods map[string]*Function
+}
+
+func (s *Struct) Type() ObjectType { return STRUCT_OBJ }
+func (s *Struct) Inspect() string {
+	return "struct " + s.Name + " { " + strings.Join(s.Fields, ", ") + " }"
+}
+
+// FieldIndex returns the position of the named field in the struct's
+// declaration, or -1 if it has no such field.
+func (s *Struct) FieldIndex(name string) int {
+	for i, field := range s.Fields {
+		if field == name {
+			return i
+		}
+	}
+	return -1
 }
 
-type Hash struct {
-	Pairs map[HashKey]HashPair
+type Instance struct {
+	Struct *Struct
+	Values []Object // in the order of Struct.Fields
 }
 
-func (h *Hash) Type() ObjectType { return HASH_OBJ }
-func (h *Hash) Inspect() string {
+func (i *Instance) Type() ObjectType { return INSTANCE_OBJ }
+func (i *Instance) Inspect() string {
 	var out bytes.Buffer
 
-	pairs := []string{}
-	for _, pair := range h.Pairs {
-		pairs = append(pairs, fmt.Sprintf("%s: %s",
-			pair.Key.Inspect(), pair.Value.Inspect()))
+	fields := []string{}
+	for idx, name := range i.Struct.Fields {
+		fields = append(fields, fmt.Sprintf("%s: %s", name, i.Values[idx].Inspect()))
 	}
 
+	out.WriteString(i.Struct.Name)
 	out.WriteString("{")
-	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString(strings.Join(fields, ", "))
 	out.WriteString("}")
 
 	return out.String()
 }
+
+// HashKey combines the struct name with the hash keys of the hashable
+// fields, so instances that compare equal always hash alike.
+func (i *Instance) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write([]byte(i.Struct.Name))
+
+	for _, value := range i.Values {
+		writeHashKey(h, value)
+	}
+
+	return HashKey{Type: i.Type(), Value: h.Sum64()}
+}
+
+// BoundMethod is a method looked up on a receiver, waiting to be called.
+type BoundMethod struct {
+	Receiver Object
+	Method   Object // a *Function or *Builtin
+}
+
+func (bm *BoundMethod) Type() ObjectType { return BOUND_METHOD_OBJ }
+func (bm *BoundMethod) Inspect() string {
+	return "bound method of " + bm.Receiver.Inspect()
+}
@@ -1,6 +1,9 @@
 package object
 
-import "testing"
+import (
+	"math/big"
+	"testing"
+)
 
 func TestStringHashKey(t *testing.T) {
 	hello1 := &String{Value: "Hello World"}
@@ -58,3 +61,239 @@ func TestIntegerHashKey(t *testing.T) {
 		t.Errorf("integers with twoerent content have same hash keys")
 	}
 }
+
+func TestBigIntegerHashKey(t *testing.T) {
+	value1, _ := new(big.Int).SetString("99999999999999999999", 10)
+	value2, _ := new(big.Int).SetString("99999999999999999999", 10)
+	value3, _ := new(big.Int).SetString("99999999999999999998", 10)
+
+	big1 := &BigInteger{Value: value1}
+	big2 := &BigInteger{Value: value2}
+	big3 := &BigInteger{Value: value3}
+
+	if big1.HashKey() != big2.HashKey() {
+		t.Errorf("big integers with same content have different hash keys")
+	}
+
+	if big1.HashKey() == big3.HashKey() {
+		t.Errorf("big integers with different content have same hash keys")
+	}
+}
+
+func TestInstanceHashKey(t *testing.T) {
+	point := &Struct{Name: "Point", Fields: []string{"x", "y"}}
+	other := &Struct{Name: "Other", Fields: []string{"x", "y"}}
+
+	p1 := &Instance{Struct: point, Values: []Object{&Integer{Value: 1}, &String{Value: "a"}}}
+	p2 := &Instance{Struct: point, Values: []Object{&Integer{Value: 1}, &String{Value: "a"}}}
+	p3 := &Instance{Struct: point, Values: []Object{&Integer{Value: 2}, &String{Value: "a"}}}
+	o1 := &Instance{Struct: other, Values: []Object{&Integer{Value: 1}, &String{Value: "a"}}}
+
+	if p1.HashKey() != p2.HashKey() {
+		t.Errorf("instances with same content have different hash keys")
+	}
+
+	if p1.HashKey() == p3.HashKey() {
+		t.Errorf("instances with different content have same hash keys")
+	}
+
+	if p1.HashKey() == o1.HashKey() {
+		t.Errorf("instances of different structs have same hash keys")
+	}
+}
+
+// collidingKey is a string whose hash key always collides with every other
+// collidingKey.
+type collidingKey struct {
+	*String
+}
+
+func (ck collidingKey) HashKey() HashKey {
+	return HashKey{Type: STRING_OBJ, Value: 42}
+}
+
+func TestHashCollisions(t *testing.T) {
+	hash := NewHash()
+	first := collidingKey{&String{Value: "first"}}
+	second := collidingKey{&String{Value: "second"}}
+
+	hash.Set(first, &Integer{Value: 1})
+	hash.Set(second, &Integer{Value: 2})
+
+	if hash.Len() != 2 {
+		t.Fatalf("colliding keys overwrote each other. got len=%d", hash.Len())
+	}
+
+	for key, expected := range map[Hashable]int64{first: 1, second: 2} {
+		pair, ok := hash.Get(key)
+		if !ok {
+			t.Fatalf("no pair for key %s", key.Inspect())
+		}
+		if pair.Value.(*Integer).Value != expected {
+			t.Errorf("wrong value for key %s. got=%s", key.Inspect(), pair.Value.Inspect())
+		}
+	}
+
+	hash.Set(second, &Integer{Value: 3})
+	if hash.Len() != 2 {
+		t.Errorf("setting an existing key added a pair. got len=%d", hash.Len())
+	}
+	if pair, _ := hash.Get(second); pair.Value.(*Integer).Value != 3 {
+		t.Errorf("setting an existing key did not replace its value")
+	}
+}
+
+func TestHashKeysCompareByValue(t *testing.T) {
+	hash := NewHash()
+	hash.Set(&String{Value: "name"}, &Integer{Value: 1})
+	hash.Set(&Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "a"}}}, &Integer{Value: 2})
+
+	if _, ok := hash.Get(&String{Value: "name"}); !ok {
+		t.Errorf("equal string key not found")
+	}
+
+	if _, ok := hash.Get(&Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "a"}}}); !ok {
+		t.Errorf("equal array key not found")
+	}
+
+	if _, ok := hash.Get(&Array{Elements: []Object{&String{Value: "a"}, &Integer{Value: 1}}}); ok {
+		t.Errorf("array key with different order found")
+	}
+}
+
+func TestArrayHashKey(t *testing.T) {
+	array1 := &Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "a"}}}
+	array2 := &Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "a"}}}
+	array3 := &Array{Elements: []Object{&String{Value: "a"}, &Integer{Value: 1}}}
+
+	if array1.HashKey() != array2.HashKey() {
+		t.Errorf("arrays with same content have different hash keys")
+	}
+
+	if array1.HashKey() == array3.HashKey() {
+		t.Errorf("arrays with different content have same hash keys")
+	}
+}
+
+func TestFrozenHashKey(t *testing.T) {
+	hash1 := NewHash()
+	hash1.Set(&String{Value: "a"}, &Integer{Value: 1})
+	hash1.Set(&String{Value: "b"}, &Integer{Value: 2})
+
+	hash2 := NewHash()
+	hash2.Set(&String{Value: "b"}, &Integer{Value: 2})
+	hash2.Set(&String{Value: "a"}, &Integer{Value: 1})
+
+	if _, ok := AsHashable(hash1); ok {
+		t.Errorf("unfrozen hash is usable as hash key")
+	}
+
+	hash1.Frozen = true
+	if _, ok := AsHashable(hash1); !ok {
+		t.Errorf("frozen hash is not usable as hash key")
+	}
+
+	if hash1.HashKey() != hash2.HashKey() {
+		t.Errorf("hashes with same content have different hash keys")
+	}
+
+	array := &Array{Elements: []Object{&Integer{Value: 1}, hash2}}
+	if _, ok := AsHashable(array); ok {
+		t.Errorf("array holding an unfrozen hash is usable as hash key")
+	}
+	hash2.Frozen = true
+	if _, ok := AsHashable(array); !ok {
+		t.Errorf("array holding a frozen hash is not usable as hash key")
+	}
+}
+
+func TestHashOrder(t *testing.T) {
+	hash := NewHash()
+	names := []string{"e", "d", "c", "b", "a"}
+	for i, name := range names {
+		hash.Set(&String{Value: name}, &Integer{Value: int64(i)})
+	}
+
+	hash.Delete(&String{Value: "d"})
+	hash.Delete(&String{Value: "b"})
+	hash.Delete(&String{Value: "a"})
+	hash.Set(&String{Value: "b"}, &Integer{Value: 5})
+	hash.Set(&String{Value: "e"}, &Integer{Value: 6})
+
+	expected := "{e: 6, c: 2, b: 5}"
+	if hash.Inspect() != expected {
+		t.Errorf("wrong order. expected=%q, got=%q", expected, hash.Inspect())
+	}
+
+	if hash.Len() != 3 {
+		t.Errorf("wrong length. expected=3, got=%d", hash.Len())
+	}
+
+	for _, name := range []string{"e", "c", "b"} {
+		if _, ok := hash.Get(&String{Value: name}); !ok {
+			t.Errorf("no pair for key %s after deletions", name)
+		}
+	}
+
+	if _, ok := hash.Delete(&String{Value: "d"}); ok {
+		t.Errorf("deleted a key that was already deleted")
+	}
+}
+
+func TestHashDeleteCollidingKeys(t *testing.T) {
+	hash := NewHash()
+	first := collidingKey{&String{Value: "first"}}
+	second := collidingKey{&String{Value: "second"}}
+
+	hash.Set(first, &Integer{Value: 1})
+	hash.Set(second, &Integer{Value: 2})
+	hash.Delete(first)
+
+	if _, ok := hash.Get(first); ok {
+		t.Errorf("deleted key still found")
+	}
+
+	if pair, ok := hash.Get(second); !ok || pair.Value.(*Integer).Value != 2 {
+		t.Errorf("colliding key lost after deleting its neighbour")
+	}
+}
+
+func TestEqualCyclicStructures(t *testing.T) {
+	array1 := &Array{Elements: []Object{&Integer{Value: 1}, nil}}
+	array1.Elements[1] = array1
+	array2 := &Array{Elements: []Object{&Integer{Value: 1}, nil}}
+	array2.Elements[1] = array2
+	array3 := &Array{Elements: []Object{&Integer{Value: 2}, nil}}
+	array3.Elements[1] = array3
+
+	if !Equal(array1, array2) {
+		t.Errorf("cyclic arrays with same content are not equal")
+	}
+
+	if Equal(array1, array3) {
+		t.Errorf("cyclic arrays with different content are equal")
+	}
+
+	hash1 := NewHash()
+	hash1.Set(&String{Value: "self"}, hash1)
+	hash2 := NewHash()
+	hash2.Set(&String{Value: "self"}, hash2)
+
+	if !Equal(hash1, hash2) {
+		t.Errorf("cyclic hashes with same content are not equal")
+	}
+
+	// cycles through keys: each hash is keyed by an array holding it
+	keyed1, keyed2, keyed3 := NewHash(), NewHash(), NewHash()
+	keyed1.Set(&Array{Elements: []Object{keyed1}}, &Integer{Value: 1})
+	keyed2.Set(&Array{Elements: []Object{keyed2}}, &Integer{Value: 1})
+	keyed3.Set(&Array{Elements: []Object{keyed3}}, &Integer{Value: 2})
+
+	if !Equal(keyed1, keyed2) {
+		t.Errorf("hashes with cyclic keys and same content are not equal")
+	}
+
+	if Equal(keyed1, keyed3) {
+		t.Errorf("hashes with cyclic keys and different values are equal")
+	}
+}
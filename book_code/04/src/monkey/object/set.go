@@ -0,0 +1,78 @@
+package object
+
+import (
+	"bytes"
+	"strings"
+)
+
+// Set holds distinct hashable elements in insertion order. The elements are
+// stored as the keys of a Hash.
+type Set struct {
+	elements   *Hash
+	inspecting bool // set while Inspect runs, to spot a set that contains itself
+}
+
+func NewSet() *Set {
+	return &Set{elements: NewHash()}
+}
+
+// Add adds element unless an equal element is already in the set.
+func (s *Set) Add(element Hashable) {
+	if _, ok := s.elements.Get(element); !ok {
+		s.elements.Set(element, element)
+	}
+}
+
+// Remove removes the element equal to element and reports whether there
+// was one.
+func (s *Set) Remove(element Hashable) bool {
+	_, ok := s.elements.Delete(element)
+	return ok
+}
+
+func (s *Set) Contains(element Hashable) bool {
+	_, ok := s.elements.Get(element)
+	return ok
+}
+
+// Elements returns the elements in insertion order.
+func (s *Set) Elements() []Object {
+	pairs := s.elements.Pairs()
+
+	elements := make([]Object, len(pairs))
+	for i, pair := range pairs {
+		elements[i] = pair.Key
+	}
+
+	return elements
+}
+
+func (s *Set) Len() int {
+	return s.elements.Len()
+}
+
+func (s *Set) Type() ObjectType { return SET_OBJ }
+
+// Inspect shows a set met again inside itself as #{...}. Sets are the only
+// collections that can be changed after they are made, so every cycle runs
+// through one.
+func (s *Set) Inspect() string {
+	if s.inspecting {
+		return "#{...}"
+	}
+	s.inspecting = true
+	defer func() { s.inspecting = false }()
+
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, e := range s.Elements() {
+		elements = append(elements, e.Inspect())
+	}
+
+	out.WriteString("#{")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
@@ -349,6 +349,46 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a * b[2], b[1], 2 * [1, 2][1])",
 			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
 		},
+		{
+			"a || b && c",
+			"(a || (b && c))",
+		},
+		{
+			"p.x + q.y * 2",
+			"((p.x) + ((q.y) * 2))",
+		},
+		{
+			"a.b.c(1)[0]",
+			"(((a.b).c)(1)[0])",
+		},
+		{
+			"a && b || c && d",
+			"((a && b) || (c && d))",
+		},
+		{
+			"a == b && c < d",
+			"((a == b) && (c < d))",
+		},
+		{
+			"a ?? b || c",
+			"(a ?? (b || c))",
+		},
+		{
+			"a ?? null ?? b",
+			"((a ?? null) ?? b)",
+		},
+		{
+			"!a && b",
+			"((!a) && b)",
+		},
+		{
+			"a + 1 in b == c",
+			"(((a + 1) in b) == c)",
+		},
+		{
+			"!a in #{1, 2 * 3}",
+			"((!a) in #{1, (2 * 3)})",
+		},
 	}
 
 	for _, tt := range tests {
@@ -586,6 +626,109 @@ func TestFunctionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestFunctionDefaultAndRestParameterParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(x, y = 10) {};", "fn(x, y = 10) "},
+		{"fn(x = 1, y = x * 2) {};", "fn(x = 1, y = (x * 2)) "},
+		{"fn(...args) {};", "fn(...args) "},
+		{"fn(x, y = 2, ...rest) {};", "fn(x, y = 2, ...rest) "},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		function := stmt.Expression.(*ast.FunctionLiteral)
+
+		if function.String() != tt.expected {
+			t.Errorf("function.String() wrong. want=%q, got=%q",
+				tt.expected, function.String())
+		}
+	}
+}
+
+func TestFunctionParameterErrors(t *testing.T) {
+	tests := []string{
+		"fn(x = 1, y) {};",
+		"fn(...rest, x) {};",
+		"fn(1) {};",
+		"fn(x,) {};",
+	}
+
+	for _, input := range tests {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q", input)
+		}
+	}
+}
+
+func TestDestructuringLetStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [a, b] = arr;", "let [a, b] = arr;"},
+		{"let [first, _, ...rest] = arr;", "let [first, _, ...rest] = arr;"},
+		{`let {"x": x, "y": [y, z]} = h;`, "let {x: x, y: [y, z]} = h;"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.LetStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not *ast.LetStatement. got=%T",
+				program.Statements[0])
+		}
+		if stmt.Pattern == nil {
+			t.Fatalf("stmt.Pattern is nil")
+		}
+
+		if stmt.String() != tt.expected {
+			t.Errorf("stmt.String() wrong. want=%q, got=%q", tt.expected, stmt.String())
+		}
+	}
+}
+
+func TestSpreadExpressionParsing(t *testing.T) {
+	input := "f(1, ...xs, ...g(y));"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	call := stmt.Expression.(*ast.CallExpression)
+
+	if len(call.Arguments) != 3 {
+		t.Fatalf("wrong length of arguments. got=%d", len(call.Arguments))
+	}
+
+	spread, ok := call.Arguments[1].(*ast.SpreadExpression)
+	if !ok {
+		t.Fatalf("call.Arguments[1] is not *ast.SpreadExpression. got=%T",
+			call.Arguments[1])
+	}
+	testIdentifier(t, spread.Value, "xs")
+
+	if call.String() != "f(1, ...xs, ...g(y))" {
+		t.Errorf("call.String() wrong. got=%q", call.String())
+	}
+}
+
 func TestCallExpressionParsing(t *testing.T) {
 	input := "add(1, 2 * 3, 4 + 5);"
 
@@ -697,6 +840,84 @@ func TestStringLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestTemplateLiteralExpression(t *testing.T) {
+	input := `"sum: ${1 + 2}, name: ${name}";`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	template, ok := stmt.Expression.(*ast.TemplateLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.TemplateLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(template.Parts) != 4 {
+		t.Fatalf("template.Parts has wrong length. got=%d", len(template.Parts))
+	}
+
+	text, ok := template.Parts[0].(*ast.StringLiteral)
+	if !ok || text.Value != "sum: " {
+		t.Errorf("template.Parts[0] is not %q. got=%s", "sum: ", template.Parts[0])
+	}
+	testInfixExpression(t, template.Parts[1], 1, "+", 2)
+	testIdentifier(t, template.Parts[3], "name")
+}
+
+func TestTemplateLiteralPositions(t *testing.T) {
+	input := "let a = 1;\n  puts(\"x\n\t${a[5]} ${\"${b}\"}\");"
+
+	program := New(lexer.New(input)).ParseProgram()
+	idents := []*ast.Identifier{}
+	ast.Inspect(program, func(node ast.Node) bool {
+		if ident, ok := node.(*ast.Identifier); ok {
+			idents = append(idents, ident)
+		}
+		return true
+	})
+
+	expected := []struct {
+		name   string
+		line   int
+		column int
+	}{
+		{"a", 1, 5},
+		{"puts", 2, 3},
+		{"a", 3, 6},
+		{"b", 3, 17},
+	}
+	if len(idents) != len(expected) {
+		t.Fatalf("wrong number of identifiers. expected=%d, got=%d", len(expected), len(idents))
+	}
+	for i, e := range expected {
+		tok := idents[i].Token
+		if tok.Literal != e.name || tok.Line != e.line || tok.Column != e.column ||
+			input[tok.Offset:tok.Offset+len(e.name)] != e.name {
+			t.Errorf("identifier %d wrong. expected=%s at %d:%d, got=%s at %d:%d (offset %d)",
+				i, e.name, e.line, e.column, tok.Literal, tok.Line, tok.Column, tok.Offset)
+		}
+	}
+}
+
+func TestTemplateLiteralErrors(t *testing.T) {
+	tests := []string{
+		`"${}"`,
+		`"${1 +}"`,
+		`"${let x = 1}"`,
+	}
+
+	for _, input := range tests {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %s", input)
+		}
+	}
+}
+
 func TestParsingEmptyArrayLiterals(t *testing.T) {
 	input := "[]"
 
@@ -739,6 +960,63 @@ func TestParsingArrayLiterals(t *testing.T) {
 	testInfixExpression(t, array.Elements[2], 3, "+", 3)
 }
 
+func TestParsingSetLiterals(t *testing.T) {
+	input := "#{1, 2 * 2, ...xs}"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	set, ok := stmt.Expression.(*ast.SetLiteral)
+	if !ok {
+		t.Fatalf("exp not ast.SetLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(set.Elements) != 3 {
+		t.Fatalf("len(set.Elements) not 3. got=%d", len(set.Elements))
+	}
+
+	testIntegerLiteral(t, set.Elements[0], 1)
+	testInfixExpression(t, set.Elements[1], 2, "*", 2)
+	if _, ok := set.Elements[2].(*ast.SpreadExpression); !ok {
+		t.Errorf("set.Elements[2] not ast.SpreadExpression. got=%T", set.Elements[2])
+	}
+}
+
+func TestTrailingCommas(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2,]", "[1, 2]"},
+		{"[\n  1,\n  2, // two\n]", "[1, 2]"},
+		{"#{1,}", "#{1}"},
+		{"add(1, 2,)", "add(1, 2)"},
+		{`{"a": 1,}`, "{a:1}"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("%q: expected=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+
+	for _, input := range []string{"[,]", "[1,,]", "add(,)"} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("%q: expected parser errors", input)
+		}
+	}
+}
+
 func TestParsingIndexExpressions(t *testing.T) {
 	input := "myArray[1 + 1]"
 
@@ -762,6 +1040,40 @@ func TestParsingIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestParsingSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a[1:2]", "(a[1:2])"},
+		{"a[:2]", "(a[:2])"},
+		{"a[1:]", "(a[1:])"},
+		{"a[:]", "(a[:])"},
+		{"a[i + 1:-1]", "(a[(i + 1):(-1)])"},
+		{"a[1:][0]", "((a[1:])[0])"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		if stmt.Expression.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, stmt.Expression.String())
+		}
+	}
+
+	for _, input := range []string{"a[1:2:3]", "a[1:2"} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q", input)
+		}
+	}
+}
+
 func TestParsingEmptyHashLiteral(t *testing.T) {
 	input := "{}"
 
@@ -936,6 +1248,203 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 	}
 }
 
+func TestMatchExpressionParsing(t *testing.T) {
+	input := `match (x) {
+  1 => "one",
+  -1 => "minus one",
+  [a, _] => a,
+  {"kind": k, "size": [w, h]} => k,
+  n if n > 5 => n * 2,
+  _ => null,
+}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statements. got=%d",
+			len(program.Statements))
+	}
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	match, ok := stmt.Expression.(*ast.MatchExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.MatchExpression. got=%T", stmt.Expression)
+	}
+
+	if !testIdentifier(t, match.Subject, "x") {
+		return
+	}
+
+	if len(match.Arms) != 6 {
+		t.Fatalf("match.Arms has wrong length. got=%d", len(match.Arms))
+	}
+
+	expectedPatterns := []string{"1", "(-1)", "[a, _]", "{kind: k, size: [w, h]}", "n", "_"}
+	for i, arm := range match.Arms {
+		if arm.Pattern.String() != expectedPatterns[i] {
+			t.Errorf("match.Arms[%d].Pattern wrong. expected=%q, got=%q",
+				i, expectedPatterns[i], arm.Pattern.String())
+		}
+	}
+
+	if _, ok := match.Arms[3].Pattern.(*ast.HashPattern); !ok {
+		t.Errorf("match.Arms[3].Pattern not *ast.HashPattern. got=%T",
+			match.Arms[3].Pattern)
+	}
+
+	if !testInfixExpression(t, match.Arms[4].Guard, "n", ">", 5) {
+		return
+	}
+	if !testInfixExpression(t, match.Arms[4].Body, "n", "*", 2) {
+		return
+	}
+
+	expected := `match (x) { 1 => one, (-1) => minus one, [a, _] => a, ` +
+		`{kind: k, size: [w, h]} => k, n if (n > 5) => (n * 2), _ => null }`
+	if match.String() != expected {
+		t.Errorf("match.String() wrong. expected=%q, got=%q", expected, match.String())
+	}
+}
+
+func TestMatchExpressionErrors(t *testing.T) {
+	tests := []string{
+		"match x { _ => 1 }",
+		"match (x) { 1 + 2 => 1 }",
+		"match (x) { fn => 1 }",
+		"match (x) { {a: 1} => 1 }",
+		"match (x) { 1 => 1 2 => 2 }",
+		"match (x) { 1 2 }",
+	}
+
+	for _, input := range tests {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q", input)
+		}
+	}
+}
+
+func TestStructStatementParsing(t *testing.T) {
+	input := `struct Point {
+  x, y;
+  fn dist() { self.x * self.x + self.y * self.y }
+  fn shift(dx, dy = 0) { Point(self.x + dx, self.y + dy) }
+}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statements. got=%d",
+			len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.StructStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.StructStatement. got=%T",
+			program.Statements[0])
+	}
+
+	if stmt.Name.Value != "Point" {
+		t.Errorf("stmt.Name.Value not %q. got=%q", "Point", stmt.Name.Value)
+	}
+
+	if len(stmt.Fields) != 2 {
+		t.Fatalf("stmt.Fields has wrong length. got=%d", len(stmt.Fields))
+	}
+	testIdentifier(t, stmt.Fields[0], "x")
+	testIdentifier(t, stmt.Fields[1], "y")
+
+	if len(stmt.Methods) != 2 {
+		t.Fatalf("stmt.Methods has wrong length. got=%d", len(stmt.Methods))
+	}
+	if stmt.Methods[1].Name.Value != "shift" {
+		t.Errorf("method name not %q. got=%q", "shift", stmt.Methods[1].Name.Value)
+	}
+
+	expected := "struct Point { x, y; fn dist() { (((self.x) * (self.x)) + ((self.y) * (self.y))) } " +
+		"fn shift(dx, dy = 0) { Point(((self.x) + dx), ((self.y) + dy)) } }"
+	if stmt.String() != expected {
+		t.Errorf("stmt.String() wrong.\nwant=%q\ngot= %q", expected, stmt.String())
+	}
+}
+
+func TestStructStatementSemicolon(t *testing.T) {
+	p := New(lexer.New("struct P { x }; P(1)"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d",
+			len(program.Statements))
+	}
+	if _, ok := program.Statements[0].(*ast.StructStatement); !ok {
+		t.Errorf("program.Statements[0] is not *ast.StructStatement. got=%T",
+			program.Statements[0])
+	}
+}
+
+func TestStructStatementErrors(t *testing.T) {
+	tests := []string{
+		"struct { x }",
+		"struct P { x, x }",
+		"struct P { x; fn x() { 1 } }",
+		"struct P { x; fn () { 1 } }",
+		"struct P { 1 }",
+		"p.1",
+	}
+
+	for _, input := range tests {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q", input)
+		}
+	}
+}
+
+func TestErrorTokens(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedLine   int
+		expectedColumn int
+		expectedToken  string
+	}{
+		{"let = 5;", 1, 5, "="},
+		{"let x = 1;\n  let y 2;", 2, 9, "2"},
+		{"struct P { x, x }", 1, 15, "x"},
+		{"let x = 1 +;", 1, 12, ";"},
+		{"let x = 1;\nputs(\"a ${1 +}\");", 2, 14, ""},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 || len(p.ErrorTokens()) != len(p.Errors()) {
+			t.Errorf("expected one token per error for %q. got %d errors, %d tokens",
+				tt.input, len(p.Errors()), len(p.ErrorTokens()))
+			continue
+		}
+
+		tok := p.ErrorTokens()[0]
+		if tok.Line != tt.expectedLine || tok.Column != tt.expectedColumn ||
+			tok.Literal != tt.expectedToken {
+			t.Errorf("error token for %q wrong. expected=%d:%d %q, got=%d:%d %q",
+				tt.input, tt.expectedLine, tt.expectedColumn, tt.expectedToken,
+				tok.Line, tok.Column, tok.Literal)
+		}
+	}
+}
+
 func testLetStatement(t *testing.T, s ast.Statement, name string) bool {
 	if s.TokenLiteral() != "let" {
 		t.Errorf("s.TokenLiteral not 'let'. got=%q", s.TokenLiteral())
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/object"
+	"monkey/profile"
+	"os"
+)
+
+// profileOutput is where --profile writes the pprof profile of a script,
+// or "" for no profiling, and profileTop how many functions and lines the
+// report lists.
+var (
+	profileOutput string
+	profileTop    int
+)
+
+func profileFlags(flags *flag.FlagSet) {
+	flags.StringVar(&profileOutput, "profile", "",
+		"profile the script, reporting where the time went on standard error and writing a pprof profile to `file`")
+	flags.IntVar(&profileTop, "profile-top", 10,
+		"the number of functions and lines the profile report lists")
+}
+
+// evalProfiled evaluates program, which was read from the file at path, with
+// ctx under a profiler, then writes its report and profile.
+func evalProfiled(
+	path string,
+	source []byte,
+	program *ast.Program,
+	ctx *evaluator.Context,
+) (object.Object, error) {
+	p := profile.New(path)
+	traced := p.Start(ctx)
+	evaluated := evaluator.Eval(program, evaluator.NewEnvironment(traced))
+	p.Stop()
+
+	p.WriteReport(os.Stderr, string(source), profileTop)
+
+	out, err := os.Create(profileOutput)
+	if err != nil {
+		return evaluated, err
+	}
+	if err := p.WritePprof(out); err != nil {
+		out.Close()
+		return evaluated, err
+	}
+	return evaluated, out.Close()
+}
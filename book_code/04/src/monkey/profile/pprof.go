@@ -0,0 +1,195 @@
+package profile
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"sort"
+)
+
+// The field numbers of the messages of pprof's profile.proto that the
+// output uses.
+const (
+	PROFILE_SAMPLE_TYPE         = 1
+	PROFILE_SAMPLE              = 2
+	PROFILE_LOCATION            = 4
+	PROFILE_FUNCTION            = 5
+	PROFILE_STRING_TABLE        = 6
+	PROFILE_TIME_NANOS          = 9
+	PROFILE_DURATION_NANOS      = 10
+	PROFILE_DEFAULT_SAMPLE_TYPE = 14
+
+	VALUE_TYPE_TYPE = 1
+	VALUE_TYPE_UNIT = 2
+
+	SAMPLE_LOCATION_ID = 1
+	SAMPLE_VALUE       = 2
+
+	LOCATION_ID   = 1
+	LOCATION_LINE = 4
+
+	LINE_FUNCTION_ID = 1
+	LINE_LINE        = 2
+
+	FUNCTION_ID          = 1
+	FUNCTION_NAME        = 2
+	FUNCTION_SYSTEM_NAME = 3
+	FUNCTION_FILENAME    = 4
+	FUNCTION_START_LINE  = 5
+)
+
+// The wire types of protocol buffer fields.
+const (
+	WIRE_VARINT = 0
+	WIRE_BYTES  = 2
+)
+
+// protobuf encodes the fields of a protocol buffer message.
+type protobuf struct {
+	bytes.Buffer
+}
+
+func (b *protobuf) varint(v uint64) {
+	for v >= 0x80 {
+		b.WriteByte(byte(v) | 0x80)
+		v >>= 7
+	}
+	b.WriteByte(byte(v))
+}
+
+func (b *protobuf) key(field, wireType int) {
+	b.varint(uint64(field<<3 | wireType))
+}
+
+func (b *protobuf) uint(field int, v uint64) {
+	b.key(field, WIRE_VARINT)
+	b.varint(v)
+}
+
+func (b *protobuf) int(field int, v int64) {
+	b.uint(field, uint64(v))
+}
+
+func (b *protobuf) bytes(field int, data []byte) {
+	b.key(field, WIRE_BYTES)
+	b.varint(uint64(len(data)))
+	b.Write(data)
+}
+
+func (b *protobuf) string(field int, s string) {
+	b.bytes(field, []byte(s))
+}
+
+// packed encodes a repeated varint field as one run.
+func (b *protobuf) packed(field int, vs []uint64) {
+	run := &protobuf{}
+	for _, v := range vs {
+		run.varint(v)
+	}
+	b.bytes(field, run.Bytes())
+}
+
+func (b *protobuf) message(field int, encode func(m *protobuf)) {
+	m := &protobuf{}
+	encode(m)
+	b.bytes(field, m.Bytes())
+}
+
+// stringTable numbers strings for the profile's string table, which must
+// begin with the empty string.
+type stringTable struct {
+	strings []string
+	index   map[string]int64
+}
+
+func newStringTable() *stringTable {
+	return &stringTable{strings: []string{""}, index: map[string]int64{"": 0}}
+}
+
+func (t *stringTable) add(s string) int64 {
+	if i, ok := t.index[s]; ok {
+		return i
+	}
+	t.index[s] = int64(len(t.strings))
+	t.strings = append(t.strings, s)
+	return t.index[s]
+}
+
+// WritePprof writes the profile in pprof's gzipped protocol buffer format.
+// Each stack of Monkey calls is a sample whose values are the calls made
+// with that stack and the time spent in it, so go tool pprof can show where
+// the time went.
+func (p *Profiler) WritePprof(w io.Writer) error {
+	strs := newStringTable()
+	out := &protobuf{}
+
+	valueType := func(typ, unit string) func(m *protobuf) {
+		return func(m *protobuf) {
+			m.int(VALUE_TYPE_TYPE, strs.add(typ))
+			m.int(VALUE_TYPE_UNIT, strs.add(unit))
+		}
+	}
+	out.message(PROFILE_SAMPLE_TYPE, valueType("calls", "count"))
+	out.message(PROFILE_SAMPLE_TYPE, valueType("time", "nanoseconds"))
+
+	samples := []*sample{}
+	for _, s := range p.samples {
+		samples = append(samples, s)
+	}
+	sort.Slice(samples, func(i, j int) bool {
+		a, b := samples[i].locations, samples[j].locations
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+	for _, s := range samples {
+		out.message(PROFILE_SAMPLE, func(m *protobuf) {
+			m.packed(SAMPLE_LOCATION_ID, s.locations)
+			m.packed(SAMPLE_VALUE, []uint64{uint64(s.calls), uint64(s.time)})
+		})
+	}
+
+	locations := make([]location, len(p.locations))
+	for loc, id := range p.locations {
+		locations[id-1] = loc
+	}
+	for i, loc := range locations {
+		out.message(PROFILE_LOCATION, func(m *protobuf) {
+			m.uint(LOCATION_ID, uint64(i+1))
+			m.message(LOCATION_LINE, func(l *protobuf) {
+				l.uint(LINE_FUNCTION_ID, loc.function.id)
+				l.int(LINE_LINE, int64(loc.line))
+			})
+		})
+	}
+
+	functions := p.Functions()
+	sort.Slice(functions, func(i, j int) bool { return functions[i].id < functions[j].id })
+	for _, f := range functions {
+		out.message(PROFILE_FUNCTION, func(m *protobuf) {
+			m.uint(FUNCTION_ID, f.id)
+			m.int(FUNCTION_NAME, strs.add(f.Name))
+			m.int(FUNCTION_SYSTEM_NAME, strs.add(f.Name))
+			m.int(FUNCTION_FILENAME, strs.add(p.filename))
+			m.int(FUNCTION_START_LINE, int64(f.StartLine))
+		})
+	}
+
+	out.int(PROFILE_TIME_NANOS, p.start.UnixNano())
+	out.int(PROFILE_DURATION_NANOS, int64(p.total))
+	out.int(PROFILE_DEFAULT_SAMPLE_TYPE, strs.add("time"))
+
+	// the string table goes last, once everything has added its strings
+	for _, s := range strs.strings {
+		out.string(PROFILE_STRING_TABLE, s)
+	}
+
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(out.Bytes()); err != nil {
+		return err
+	}
+	return zw.Close()
+}
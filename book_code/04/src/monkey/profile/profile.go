@@ -0,0 +1,241 @@
+// Package profile measures where a Monkey program spends its time. A
+// Profiler follows evaluation as the Tracer of its context and charges the time
+// between one statement, call or return and the next to the line and
+// function running then, so times are exact rather than sampled.
+package profile
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/object"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Function holds what was measured of one function. Flat is the time spent
+// in its own statements, Cumulative also counts the functions it called.
+type Function struct {
+	Name       string
+	StartLine  int
+	Calls      int
+	Flat       time.Duration
+	Cumulative time.Duration
+
+	id     uint64 // in the pprof output
+	active int    // how many calls of it are in progress
+}
+
+// Line holds what was measured of one line. Hits counts the statements on
+// it that were evaluated.
+type Line struct {
+	Line int
+	Hits int
+	Flat time.Duration
+}
+
+// location is a line of a function, a frame of the stacks in the pprof
+// output.
+type location struct {
+	function *Function
+	line     int
+}
+
+type frame struct {
+	function *Function
+	line     int
+	start    time.Duration // the total when it was called
+}
+
+// sample is what was measured with one stack of frames.
+type sample struct {
+	locations []uint64 // innermost first
+	calls     int64
+	time      time.Duration
+}
+
+// Profiler measures one program. The program itself is a function named
+// after its file.
+type Profiler struct {
+	now      func() time.Time
+	filename string
+
+	functions map[*ast.BlockStatement]*Function // by body, shared by closures
+	program   *Function
+	lines     map[int]*Line
+	locations map[location]uint64
+	samples   map[string]*sample // by the locations of their stacks
+
+	stack []*frame
+	start time.Time
+	last  time.Time
+	total time.Duration
+}
+
+// New returns a Profiler for the program in the file at filename.
+func New(filename string) *Profiler {
+	p := &Profiler{
+		now:       time.Now,
+		filename:  filename,
+		functions: map[*ast.BlockStatement]*Function{},
+		program:   &Function{Name: filepath.Base(filename), StartLine: 1, id: 1},
+		lines:     map[int]*Line{},
+		locations: map[location]uint64{},
+		samples:   map[string]*sample{},
+	}
+	return p
+}
+
+// Start starts the clock and returns a copy of ctx with p as its Tracer,
+// for evaluating the program with.
+func (p *Profiler) Start(ctx *evaluator.Context) *evaluator.Context {
+	traced := *ctx
+	traced.Tracer = p
+
+	p.start = p.now()
+	p.last = p.start
+	p.program.Calls = 1
+	p.stack = []*frame{{function: p.program, line: 1}}
+	return &traced
+}
+
+// Stop stops the clock once the program is done.
+func (p *Profiler) Stop() {
+	p.charge(p.now())
+	p.program.Cumulative = p.total
+}
+
+// Total is how long the program ran, less the time spent profiling it.
+func (p *Profiler) Total() time.Duration {
+	return p.total
+}
+
+// charge charges the time since the last event was handled to the line
+// and the stack running since then. The handling itself is not charged, so
+// the profile is of the program rather than of the profiler.
+func (p *Profiler) charge(now time.Time) {
+	elapsed := now.Sub(p.last)
+	p.total += elapsed
+
+	top := p.stack[len(p.stack)-1]
+	top.function.Flat += elapsed
+	if line, ok := p.lines[top.line]; ok {
+		line.Flat += elapsed
+	}
+	p.sample().time += elapsed
+}
+
+// sample returns the sample of the current stack.
+func (p *Profiler) sample() *sample {
+	ids := make([]uint64, len(p.stack))
+	var key strings.Builder
+	for i := range p.stack {
+		f := p.stack[len(p.stack)-1-i]
+		loc := location{f.function, f.line}
+		id, ok := p.locations[loc]
+		if !ok {
+			id = uint64(len(p.locations) + 1)
+			p.locations[loc] = id
+		}
+		ids[i] = id
+		key.WriteString(strconv.FormatUint(id, 10))
+		key.WriteByte(' ')
+	}
+
+	s, ok := p.samples[key.String()]
+	if !ok {
+		s = &sample{locations: ids}
+		p.samples[key.String()] = s
+	}
+	return s
+}
+
+func (p *Profiler) Statement(stmt ast.Statement, env *object.Environment) {
+	p.charge(p.now())
+
+	line := ast.StatementToken(stmt).Line
+	p.stack[len(p.stack)-1].line = line
+	if _, ok := p.lines[line]; !ok {
+		p.lines[line] = &Line{Line: line}
+	}
+	p.lines[line].Hits++
+
+	p.last = p.now()
+}
+
+func (p *Profiler) Call(fn *object.Function, env *object.Environment) {
+	p.charge(p.now())
+
+	f := p.function(fn)
+	f.Calls++
+	f.active++
+	p.stack = append(p.stack, &frame{function: f, line: f.StartLine, start: p.total})
+	p.sample().calls++
+
+	p.last = p.now()
+}
+
+func (p *Profiler) Return(fn *object.Function, result object.Object) {
+	p.charge(p.now())
+
+	top := p.stack[len(p.stack)-1]
+	p.stack = p.stack[:len(p.stack)-1]
+
+	// the time of a recursive call is already in that of the call that
+	// made it
+	top.function.active--
+	if top.function.active == 0 {
+		top.function.Cumulative += p.total - top.start
+	}
+
+	p.last = p.now()
+}
+
+func (p *Profiler) function(fn *object.Function) *Function {
+	if f, ok := p.functions[fn.Body]; ok {
+		return f
+	}
+
+	line := fn.Body.Token.Line
+	name := fn.Name
+	if name == "" {
+		name = fmt.Sprintf("fn@%d", line)
+	}
+	f := &Function{Name: name, StartLine: line, id: uint64(len(p.functions) + 2)}
+	p.functions[fn.Body] = f
+	return f
+}
+
+// Functions returns the functions that ran, the program first and the rest
+// by flat time, most first.
+func (p *Profiler) Functions() []*Function {
+	functions := []*Function{}
+	for _, f := range p.functions {
+		functions = append(functions, f)
+	}
+	sort.Slice(functions, func(i, j int) bool {
+		if functions[i].Flat != functions[j].Flat {
+			return functions[i].Flat > functions[j].Flat
+		}
+		return functions[i].StartLine < functions[j].StartLine
+	})
+	return append([]*Function{p.program}, functions...)
+}
+
+// Lines returns the lines that ran, by flat time, most first.
+func (p *Profiler) Lines() []*Line {
+	lines := []*Line{}
+	for _, l := range p.lines {
+		lines = append(lines, l)
+	}
+	sort.Slice(lines, func(i, j int) bool {
+		if lines[i].Flat != lines[j].Flat {
+			return lines[i].Flat > lines[j].Flat
+		}
+		return lines[i].Line < lines[j].Line
+	})
+	return lines
+}
@@ -0,0 +1,260 @@
+package profile
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/parser"
+	"strings"
+	"testing"
+	"time"
+)
+
+const program = `let double = fn(x) {
+  x * 2
+};
+let a = double(1);
+double(a)`
+
+// profile profiles source with a clock that moves a millisecond each time
+// it is read, so every statement, call and return is charged a millisecond.
+func profile(t *testing.T, source string) *Profiler {
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	clock := time.Unix(0, 0)
+	profiler := New("test/script.code")
+	profiler.now = func() time.Time {
+		clock = clock.Add(time.Millisecond)
+		return clock
+	}
+
+	ctx := profiler.Start(&evaluator.Context{})
+	evaluator.Eval(program, evaluator.NewEnvironment(ctx))
+	profiler.Stop()
+	return profiler
+}
+
+func TestFunctions(t *testing.T) {
+	p := profile(t, program)
+
+	if p.Total() != 10*time.Millisecond {
+		t.Errorf("wrong total. expected=10ms, got=%s", p.Total())
+	}
+
+	expected := []Function{
+		{Name: "script.code", StartLine: 1, Calls: 1, Flat: 6 * time.Millisecond, Cumulative: 10 * time.Millisecond},
+		{Name: "double", StartLine: 1, Calls: 2, Flat: 4 * time.Millisecond, Cumulative: 4 * time.Millisecond},
+	}
+
+	functions := p.Functions()
+	if len(functions) != len(expected) {
+		t.Fatalf("wrong number of functions. expected=%d, got=%d", len(expected), len(functions))
+	}
+	for i, e := range expected {
+		f := functions[i]
+		if f.Name != e.Name || f.StartLine != e.StartLine || f.Calls != e.Calls ||
+			f.Flat != e.Flat || f.Cumulative != e.Cumulative {
+			t.Errorf("function %d wrong. expected=%+v, got=%+v", i, e, *f)
+		}
+	}
+}
+
+func TestLines(t *testing.T) {
+	p := profile(t, program)
+
+	expected := []Line{
+		{Line: 1, Hits: 1, Flat: 3 * time.Millisecond},
+		{Line: 2, Hits: 2, Flat: 2 * time.Millisecond},
+		{Line: 4, Hits: 1, Flat: 2 * time.Millisecond},
+		{Line: 5, Hits: 1, Flat: 2 * time.Millisecond},
+	}
+
+	lines := p.Lines()
+	if len(lines) != len(expected) {
+		t.Fatalf("wrong number of lines. expected=%d, got=%d", len(expected), len(lines))
+	}
+	for i, e := range expected {
+		if *lines[i] != e {
+			t.Errorf("line %d wrong. expected=%+v, got=%+v", i, e, *lines[i])
+		}
+	}
+}
+
+func TestRecursionAndClosures(t *testing.T) {
+	p := profile(t, `let count = fn(n) { if (n > 0) { count(n - 1) } else { 0 } };
+count(3);
+map([1, 2], fn(x) { x });
+map([3], fn(x) { x });`)
+
+	functions := map[string]*Function{}
+	for _, f := range p.Functions() {
+		functions[f.Name] = f
+	}
+
+	count := functions["count"]
+	if count == nil || count.Calls != 4 || count.Cumulative != count.Flat {
+		t.Errorf("recursive calls counted wrong: %+v", count)
+	}
+	if f := functions["fn@3"]; f == nil || f.Calls != 2 {
+		t.Errorf("wrong anonymous function on line 3: %+v", f)
+	}
+	if f := functions["fn@4"]; f == nil || f.Calls != 1 {
+		t.Errorf("wrong anonymous function on line 4: %+v", f)
+	}
+}
+
+func TestReport(t *testing.T) {
+	p := profile(t, program)
+
+	out := &bytes.Buffer{}
+	p.WriteReport(out, program, 3)
+
+	expected := []string{
+		"total time 10.000ms\n",
+		"   6.000ms  60.0%   10.000ms 100.0%        1  script.code (line 1)\n",
+		"   4.000ms  40.0%    4.000ms  40.0%        2  double (line 1)\n",
+		"   3.000ms  30.0%        1     1 | let double = fn(x) {\n",
+		"   2.000ms  20.0%        2     2 | x * 2\n",
+		"   2.000ms  20.0%        1     4 | let a = double(1);\n",
+	}
+	for _, e := range expected {
+		if !strings.Contains(out.String(), e) {
+			t.Errorf("report is missing %q:\n%s", e, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "double(a)") {
+		t.Errorf("report lists more than 3 lines:\n%s", out.String())
+	}
+}
+
+// field is a field of an encoded protocol buffer message.
+type field struct {
+	number int
+	varint uint64
+	bytes  []byte
+}
+
+func decode(t *testing.T, data []byte) []field {
+	fields := []field{}
+	for len(data) > 0 {
+		key, n := readVarint(data)
+		data = data[n:]
+
+		f := field{number: int(key >> 3)}
+		switch key & 7 {
+		case WIRE_VARINT:
+			f.varint, n = readVarint(data)
+			data = data[n:]
+		case WIRE_BYTES:
+			length, n := readVarint(data)
+			f.bytes = data[n : n+int(length)]
+			data = data[n+int(length):]
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+func readVarint(data []byte) (uint64, int) {
+	var v uint64
+	for i, b := range data {
+		v |= uint64(b&0x7f) << (7 * uint(i))
+		if b < 0x80 {
+			return v, i + 1
+		}
+	}
+	return v, len(data)
+}
+
+func readPacked(data []byte) []uint64 {
+	vs := []uint64{}
+	for len(data) > 0 {
+		v, n := readVarint(data)
+		vs = append(vs, v)
+		data = data[n:]
+	}
+	return vs
+}
+
+func TestPprof(t *testing.T) {
+	p := profile(t, program)
+
+	out := &bytes.Buffer{}
+	if err := p.WritePprof(out); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := gzip.NewReader(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	strs := []string{}
+	var calls, nanoseconds uint64
+	locations, functions := 0, map[uint64]bool{}
+	deepest := 0
+	for _, f := range decode(t, data) {
+		switch f.number {
+		case PROFILE_STRING_TABLE:
+			strs = append(strs, string(f.bytes))
+		case PROFILE_SAMPLE:
+			for _, sf := range decode(t, f.bytes) {
+				switch sf.number {
+				case SAMPLE_VALUE:
+					values := readPacked(sf.bytes)
+					calls += values[0]
+					nanoseconds += values[1]
+				case SAMPLE_LOCATION_ID:
+					if n := len(readPacked(sf.bytes)); n > deepest {
+						deepest = n
+					}
+				}
+			}
+		case PROFILE_LOCATION:
+			locations++
+		case PROFILE_FUNCTION:
+			for _, ff := range decode(t, f.bytes) {
+				if ff.number == FUNCTION_ID {
+					functions[ff.varint] = true
+				}
+			}
+		}
+	}
+
+	if len(strs) == 0 || strs[0] != "" {
+		t.Fatalf("the string table must start with the empty string. got=%q", strs)
+	}
+	for _, s := range []string{"calls", "count", "time", "nanoseconds", "double", "script.code", "test/script.code"} {
+		found := false
+		for _, str := range strs {
+			found = found || str == s
+		}
+		if !found {
+			t.Errorf("string table is missing %q: %q", s, strs)
+		}
+	}
+
+	if calls != 2 || time.Duration(nanoseconds) != 10*time.Millisecond {
+		t.Errorf("wrong sample totals. expected 2 calls and 10ms, got %d calls and %s",
+			calls, time.Duration(nanoseconds))
+	}
+	if len(functions) != 2 || deepest != 2 {
+		t.Errorf("expected 2 functions and stacks 2 deep. got %d functions, stacks %d deep",
+			len(functions), deepest)
+	}
+	// the program at lines 1, 4 and 5, double at lines 1 and 2
+	if locations != 5 {
+		t.Errorf("wrong number of locations. expected=5, got=%d", locations)
+	}
+}
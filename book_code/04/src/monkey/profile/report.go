@@ -0,0 +1,50 @@
+package profile
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// WriteReport writes the n functions and the n lines that took the most
+// time, showing each line's text from source.
+func (p *Profiler) WriteReport(w io.Writer, source string, n int) {
+	fmt.Fprintf(w, "total time %s\n\n", milliseconds(p.total))
+
+	fmt.Fprintf(w, "%10s %6s %10s %6s %8s  %s\n", "flat", "flat%", "cum", "cum%", "calls", "function")
+	for i, f := range p.Functions() {
+		if i == n {
+			break
+		}
+		fmt.Fprintf(w, "%10s %6s %10s %6s %8d  %s (line %d)\n",
+			milliseconds(f.Flat), p.percent(f.Flat),
+			milliseconds(f.Cumulative), p.percent(f.Cumulative),
+			f.Calls, f.Name, f.StartLine)
+	}
+
+	lines := strings.Split(source, "\n")
+	fmt.Fprintf(w, "\n%10s %6s %8s  %s\n", "flat", "flat%", "hits", "line")
+	for i, l := range p.Lines() {
+		if i == n {
+			break
+		}
+		text := ""
+		if l.Line >= 1 && l.Line <= len(lines) {
+			text = strings.TrimSpace(lines[l.Line-1])
+		}
+		fmt.Fprintf(w, "%10s %6s %8d  %4d | %s\n",
+			milliseconds(l.Flat), p.percent(l.Flat), l.Hits, l.Line, text)
+	}
+}
+
+func milliseconds(d time.Duration) string {
+	return fmt.Sprintf("%.3fms", float64(d)/float64(time.Millisecond))
+}
+
+func (p *Profiler) percent(d time.Duration) string {
+	if p.total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", float64(d)*100/float64(p.total))
+}
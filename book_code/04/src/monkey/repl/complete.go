@@ -0,0 +1,39 @@
+package repl
+
+import (
+	"monkey/evaluator"
+	"monkey/object"
+	"monkey/token"
+	"sort"
+	"strings"
+)
+
+// completer returns a completion function for the environment env returns.
+func completer(env func() *object.Environment) func(string) []string {
+	return func(word string) []string {
+		return Completions(env(), word)
+	}
+}
+
+// Completions returns the keywords, the builtins and the names bound in env
+// that start with word, sorted.
+func Completions(env *object.Environment, word string) []string {
+	if word == "" {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	completions := []string{}
+
+	candidates := append(token.Keywords(), evaluator.BuiltinNames()...)
+	candidates = append(candidates, env.Names()...)
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, word) && !seen[candidate] {
+			seen[candidate] = true
+			completions = append(completions, candidate)
+		}
+	}
+
+	sort.Strings(completions)
+	return completions
+}
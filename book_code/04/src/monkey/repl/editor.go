@@ -0,0 +1,459 @@
+package repl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+const MAX_HISTORY = 1000
+
+type key int
+
+const (
+	keyRune key = iota
+	keyEnter
+	keyTab
+	keyBackspace
+	keyDelete
+	keyLeft
+	keyRight
+	keyUp
+	keyDown
+	keyHome
+	keyEnd
+	keyInterrupt     // Ctrl-C
+	keyEndOfInput    // Ctrl-D
+	keyCancel        // Ctrl-G
+	keyKillToEnd     // Ctrl-K
+	keyKillToStart   // Ctrl-U
+	keyKillWord      // Ctrl-W
+	keyReverseSearch // Ctrl-R
+	keyUnknown
+)
+
+var controlKeys = map[rune]key{
+	1:   keyHome,          // Ctrl-A
+	2:   keyLeft,          // Ctrl-B
+	3:   keyInterrupt,     // Ctrl-C
+	4:   keyEndOfInput,    // Ctrl-D
+	5:   keyEnd,           // Ctrl-E
+	6:   keyRight,         // Ctrl-F
+	7:   keyCancel,        // Ctrl-G
+	8:   keyBackspace,     // Ctrl-H
+	9:   keyTab,           // Ctrl-I
+	10:  keyEnter,         // Ctrl-J
+	11:  keyKillToEnd,     // Ctrl-K
+	13:  keyEnter,         // Ctrl-M
+	14:  keyDown,          // Ctrl-N
+	16:  keyUp,            // Ctrl-P
+	18:  keyReverseSearch, // Ctrl-R
+	21:  keyKillToStart,   // Ctrl-U
+	23:  keyKillWord,      // Ctrl-W
+	127: keyBackspace,
+}
+
+// escapeKeys maps the final part of the escape sequences terminals send for
+// special keys, after "\x1b[" or "\x1bO".
+var escapeKeys = map[string]key{
+	"A":  keyUp,
+	"B":  keyDown,
+	"C":  keyRight,
+	"D":  keyLeft,
+	"H":  keyHome,
+	"F":  keyEnd,
+	"1~": keyHome,
+	"3~": keyDelete,
+	"4~": keyEnd,
+	"7~": keyHome,
+	"8~": keyEnd,
+}
+
+// Editor reads lines from a terminal with cursor movement, history, reverse
+// search and tab completion.
+type Editor struct {
+	// Complete returns the completions of the word before the cursor,
+	// each starting with the word.
+	Complete func(word string) []string
+	// Highlight returns the line to show in place of the one typed, with
+	// the same characters but for added escape sequences.
+	Highlight func(line string) string
+
+	in  *bufio.Reader
+	out io.Writer
+	fd  int // the terminal switched to raw mode while reading, or -1
+
+	history     []string
+	historyFile string
+
+	prompt       string
+	line         []rune
+	cursor       int
+	historyIndex int
+	typed        []rune // the line being typed while browsing history
+}
+
+// NewEditor returns an editor reading keys from in. If fd is not -1, the
+// terminal it refers to is put in raw mode while a line is read.
+func NewEditor(in io.Reader, out io.Writer, fd int) *Editor {
+	return &Editor{in: bufio.NewReader(in), out: out, fd: fd}
+}
+
+// LoadHistory reads the history from path, one entry per line, and appends
+// new entries to it from then on. A missing file is not an error.
+func (e *Editor) LoadHistory(path string) error {
+	e.historyFile = path
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		e.history = append(e.history, scanner.Text())
+	}
+	if len(e.history) > MAX_HISTORY {
+		e.history = e.history[len(e.history)-MAX_HISTORY:]
+	}
+
+	return scanner.Err()
+}
+
+// AddHistory records line as the newest history entry, unless it is blank
+// or repeats the previous entry.
+func (e *Editor) AddHistory(line string) {
+	if strings.TrimSpace(line) == "" ||
+		(len(e.history) > 0 && e.history[len(e.history)-1] == line) {
+		return
+	}
+
+	e.history = append(e.history, line)
+	if len(e.history) > MAX_HISTORY {
+		e.history = e.history[1:]
+	}
+
+	if e.historyFile == "" {
+		return
+	}
+	file, err := os.OpenFile(e.historyFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	fmt.Fprintln(file, line)
+}
+
+// ReadLine shows prompt and reads a line, which is added to the history. It
+// returns errInterrupted on Ctrl-C and io.EOF on Ctrl-D at an empty line or
+// at the end of the input.
+func (e *Editor) ReadLine(prompt string) (string, error) {
+	if e.fd >= 0 {
+		restore, err := makeRaw(e.fd)
+		if err != nil {
+			return "", err
+		}
+		defer restore()
+	}
+
+	e.prompt = prompt
+	e.line = e.line[:0]
+	e.cursor = 0
+	e.historyIndex = len(e.history)
+	e.render()
+
+	for {
+		k, r, err := e.readKey()
+		if err != nil {
+			if err == io.EOF && len(e.line) > 0 {
+				return e.accept(), nil
+			}
+			return "", err
+		}
+
+		if k == keyReverseSearch {
+			k, err = e.reverseSearch()
+			if err != nil {
+				return "", err
+			}
+		}
+
+		switch k {
+		case keyRune:
+			e.insert([]rune{r})
+		case keyEnter:
+			return e.accept(), nil
+		case keyInterrupt:
+			io.WriteString(e.out, "^C\n")
+			return "", errInterrupted
+		case keyEndOfInput:
+			if len(e.line) == 0 {
+				io.WriteString(e.out, "\n")
+				return "", io.EOF
+			}
+			e.deleteRange(e.cursor, e.cursor+1)
+		case keyTab:
+			e.complete()
+		case keyBackspace:
+			e.deleteRange(e.cursor-1, e.cursor)
+		case keyDelete:
+			e.deleteRange(e.cursor, e.cursor+1)
+		case keyKillToEnd:
+			e.deleteRange(e.cursor, len(e.line))
+		case keyKillToStart:
+			e.deleteRange(0, e.cursor)
+		case keyKillWord:
+			e.deleteRange(e.wordStart(), e.cursor)
+		case keyLeft:
+			if e.cursor > 0 {
+				e.cursor -= 1
+			}
+		case keyRight:
+			if e.cursor < len(e.line) {
+				e.cursor += 1
+			}
+		case keyHome:
+			e.cursor = 0
+		case keyEnd:
+			e.cursor = len(e.line)
+		case keyUp:
+			e.browseHistory(-1)
+		case keyDown:
+			e.browseHistory(1)
+		}
+
+		e.render()
+	}
+}
+
+// accept finishes the current line and records it in the history.
+func (e *Editor) accept() string {
+	io.WriteString(e.out, "\n")
+
+	line := string(e.line)
+	e.AddHistory(line)
+	return line
+}
+
+// readKey reads one key press. Printable characters are returned as
+// keyRune with the character.
+func (e *Editor) readKey() (key, rune, error) {
+	r, _, err := e.in.ReadRune()
+	if err != nil {
+		return keyUnknown, 0, err
+	}
+
+	if r == 27 {
+		return e.readEscapeSequence()
+	}
+	if k, ok := controlKeys[r]; ok {
+		return k, r, nil
+	}
+	if unicode.IsControl(r) {
+		return keyUnknown, r, nil
+	}
+
+	return keyRune, r, nil
+}
+
+func (e *Editor) readEscapeSequence() (key, rune, error) {
+	introducer, _, err := e.in.ReadRune()
+	if err != nil {
+		return keyUnknown, 0, err
+	}
+	if introducer != '[' && introducer != 'O' {
+		return keyUnknown, introducer, nil
+	}
+
+	var sequence strings.Builder
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return keyUnknown, 0, err
+		}
+		sequence.WriteRune(r)
+
+		// parameters are digits and semicolons, and anything else ends
+		// the sequence
+		if !('0' <= r && r <= '9') && r != ';' {
+			break
+		}
+	}
+
+	if k, ok := escapeKeys[sequence.String()]; ok {
+		return k, 0, nil
+	}
+	return keyUnknown, 0, nil
+}
+
+// render redraws the prompt and line and puts the cursor in place.
+func (e *Editor) render() {
+	line := string(e.line)
+	if e.Highlight != nil {
+		line = e.Highlight(line)
+	}
+
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K", e.prompt, line)
+	if back := len(e.line) - e.cursor; back > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dD", back)
+	}
+}
+
+func (e *Editor) insert(runes []rune) {
+	line := make([]rune, 0, len(e.line)+len(runes))
+	line = append(line, e.line[:e.cursor]...)
+	line = append(line, runes...)
+	line = append(line, e.line[e.cursor:]...)
+
+	e.line = line
+	e.cursor += len(runes)
+}
+
+// deleteRange removes the characters from start up to end, clamped to the
+// line, and leaves the cursor at start.
+func (e *Editor) deleteRange(start, end int) {
+	if start < 0 {
+		start = 0
+	}
+	if end > len(e.line) {
+		end = len(e.line)
+	}
+	if start >= end {
+		return
+	}
+
+	e.line = append(e.line[:start], e.line[end:]...)
+	e.cursor = start
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// wordStart returns where the word ending at the cursor starts.
+func (e *Editor) wordStart() int {
+	start := e.cursor
+	for start > 0 && isWordRune(e.line[start-1]) {
+		start -= 1
+	}
+	return start
+}
+
+// browseHistory moves by delta through the history. Moving past the newest
+// entry brings back the line that was being typed.
+func (e *Editor) browseHistory(delta int) {
+	index := e.historyIndex + delta
+	if index < 0 || index > len(e.history) {
+		return
+	}
+
+	if e.historyIndex == len(e.history) {
+		e.typed = append(e.typed[:0], e.line...)
+	}
+	e.historyIndex = index
+
+	if index == len(e.history) {
+		e.line = append([]rune{}, e.typed...)
+	} else {
+		e.line = []rune(e.history[index])
+	}
+	e.cursor = len(e.line)
+}
+
+// complete completes the word before the cursor. A single completion is
+// inserted; otherwise their common prefix is, and if that adds nothing the
+// completions are listed below the line.
+func (e *Editor) complete() {
+	if e.Complete == nil {
+		return
+	}
+
+	start := e.wordStart()
+	word := string(e.line[start:e.cursor])
+	completions := e.Complete(word)
+
+	switch len(completions) {
+	case 0:
+		io.WriteString(e.out, "\a")
+	case 1:
+		e.insert([]rune(completions[0][len(word):]))
+	default:
+		prefix := commonPrefix(completions)
+		if len(prefix) > len(word) {
+			e.insert([]rune(prefix[len(word):]))
+			return
+		}
+		fmt.Fprintf(e.out, "\n%s\n", strings.Join(completions, "  "))
+	}
+}
+
+func commonPrefix(words []string) string {
+	prefix := words[0]
+	for _, word := range words[1:] {
+		for !strings.HasPrefix(word, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
+
+// reverseSearch searches the history for entries containing what is typed,
+// newest first, with Ctrl-R moving to older matches. It returns keyEnter if
+// the match was accepted with Enter, keyInterrupt on Ctrl-C, and otherwise
+// keyUnknown with the match, or the original line on Ctrl-G, left in the
+// editor.
+func (e *Editor) reverseSearch() (key, error) {
+	original := append([]rune{}, e.line...)
+	query := []rune{}
+	match := len(e.history)
+
+	// find looks for the newest entry containing the query, starting at
+	// from and going back
+	find := func(from int) {
+		for i := from; i >= 0; i-- {
+			if i < len(e.history) && strings.Contains(e.history[i], string(query)) {
+				match = i
+				e.line = []rune(e.history[i])
+				e.cursor = len(e.line)
+				return
+			}
+		}
+	}
+
+	for {
+		fmt.Fprintf(e.out, "\r(reverse-i-search)`%s': %s\x1b[K", string(query), string(e.line))
+
+		k, r, err := e.readKey()
+		if err != nil {
+			return keyUnknown, err
+		}
+
+		switch k {
+		case keyRune:
+			query = append(query, r)
+			find(match)
+		case keyBackspace:
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				find(len(e.history) - 1)
+			}
+		case keyReverseSearch:
+			find(match - 1)
+		case keyCancel:
+			e.line = original
+			e.cursor = len(e.line)
+			return keyUnknown, nil
+		case keyEnter, keyInterrupt:
+			return k, nil
+		default:
+			return keyUnknown, nil
+		}
+	}
+}
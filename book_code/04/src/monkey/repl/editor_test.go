@@ -0,0 +1,212 @@
+package repl
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"monkey/object"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	UP     = "\x1b[A"
+	DOWN   = "\x1b[B"
+	RIGHT  = "\x1b[C"
+	LEFT   = "\x1b[D"
+	HOME   = "\x1b[H"
+	END    = "\x1bOF"
+	DELETE = "\x1b[3~"
+)
+
+// readEditorLines reads lines from an editor fed with keys until the keys run
+// out, returning the lines and the error that ended each read.
+func readEditorLines(editor *Editor) ([]string, []error) {
+	lines := []string{}
+	errs := []error{}
+
+	for {
+		line, err := editor.ReadLine(PROMPT)
+		if err == io.EOF {
+			return lines, errs
+		}
+		lines = append(lines, line)
+		errs = append(errs, err)
+	}
+}
+
+func TestEditorEditing(t *testing.T) {
+	tests := []struct {
+		keys     string
+		expected string
+	}{
+		{"hello\r", "hello"},
+		{"ad" + LEFT + "bc\r", "abcd"},
+		{"abc" + HOME + "x" + END + "\x7f\r", "xab"},
+		{"abc" + LEFT + LEFT + DELETE + "\r", "ac"},
+		{"abc" + "\x01" + RIGHT + "\x0b\r", "a"},
+		{"abc def" + LEFT + "\x15\r", "f"},
+		{"let foo_bar\x17baz\r", "let baz"},
+		{"héllo" + LEFT + LEFT + LEFT + LEFT + "\x7f\r", "éllo"},
+		{"abc" + LEFT + "\x04\r", "ab"},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		editor := NewEditor(strings.NewReader(tt.keys), &out, -1)
+
+		line, err := editor.ReadLine(PROMPT)
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", tt.keys, err)
+			continue
+		}
+		if line != tt.expected {
+			t.Errorf("%q: wrong line. expected=%q, got=%q", tt.keys, tt.expected, line)
+		}
+	}
+}
+
+func TestEditorInterruptAndEndOfInput(t *testing.T) {
+	var out bytes.Buffer
+	editor := NewEditor(strings.NewReader("abc\x03\x04"), &out, -1)
+
+	if _, err := editor.ReadLine(PROMPT); err != errInterrupted {
+		t.Errorf("Ctrl-C did not interrupt. got err=%v", err)
+	}
+
+	if _, err := editor.ReadLine(PROMPT); err != io.EOF {
+		t.Errorf("Ctrl-D on an empty line did not end the input. got err=%v", err)
+	}
+}
+
+func TestEditorHistory(t *testing.T) {
+	var out bytes.Buffer
+	keys := "one\r" +
+		"two\r" +
+		"two\r" +
+		UP + UP + "\r" +
+		"typed" + UP + DOWN + "\r" +
+		UP + UP + UP + UP + UP + "!\r"
+	editor := NewEditor(strings.NewReader(keys), &out, -1)
+
+	lines, _ := readEditorLines(editor)
+
+	expected := []string{"one", "two", "two", "one", "typed", "one!"}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("wrong lines. expected=%q, got=%q", expected, lines)
+	}
+}
+
+func TestEditorReverseSearch(t *testing.T) {
+	tests := []struct {
+		keys     string
+		expected string
+	}{
+		{"\x12let\r", "let y = 2"},
+		{"\x12let\x12\r", "let x = 1"},
+		{"\x12let\x12\x12\r", "let x = 1"},
+		{"\x12put\r", "puts(x)"},
+		{"\x12lex\x7f\r", "let y = 2"},
+		{"\x12put" + RIGHT + "s\r", "puts(x)s"},
+		{"abc\x12let\x07\r", "abc"},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		editor := NewEditor(strings.NewReader(tt.keys), &out, -1)
+		editor.history = []string{"let x = 1", "puts(x)", "let y = 2"}
+
+		line, err := editor.ReadLine(PROMPT)
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", tt.keys, err)
+			continue
+		}
+		if line != tt.expected {
+			t.Errorf("%q: wrong line. expected=%q, got=%q", tt.keys, tt.expected, line)
+		}
+	}
+}
+
+func TestEditorCompletion(t *testing.T) {
+	complete := func(word string) []string {
+		completions := []string{}
+		for _, candidate := range []string{"puts", "read_file", "read_line"} {
+			if strings.HasPrefix(candidate, word) {
+				completions = append(completions, candidate)
+			}
+		}
+		return completions
+	}
+
+	tests := []struct {
+		keys           string
+		expected       string
+		expectedOutput string
+	}{
+		{"pu\t(1)\r", "puts(1)", ""},
+		{"x = re\t\r", "x = read_", ""},
+		{"re\t\t\r", "read_", "\nread_file  read_line\n"},
+		{"zz\t\r", "zz", "\a"},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		editor := NewEditor(strings.NewReader(tt.keys), &out, -1)
+		editor.Complete = complete
+
+		line, _ := editor.ReadLine(PROMPT)
+		if line != tt.expected {
+			t.Errorf("%q: wrong line. expected=%q, got=%q", tt.keys, tt.expected, line)
+		}
+		if !strings.Contains(out.String(), tt.expectedOutput) {
+			t.Errorf("%q: output %q does not contain %q", tt.keys, out.String(), tt.expectedOutput)
+		}
+	}
+}
+
+func TestEditorPersistentHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, HISTORY_FILE)
+
+	var out bytes.Buffer
+	first := NewEditor(strings.NewReader("let a = 1;\r\rputs(a)\r"), &out, -1)
+	if err := first.LoadHistory(path); err != nil {
+		t.Fatalf("loading a missing history file failed: %v", err)
+	}
+	readEditorLines(first)
+
+	second := NewEditor(strings.NewReader(UP+UP+"\r"), &out, -1)
+	if err := second.LoadHistory(path); err != nil {
+		t.Fatalf("loading the history file failed: %v", err)
+	}
+
+	line, _ := second.ReadLine(PROMPT)
+	if line != "let a = 1;" {
+		t.Errorf("history not restored. expected=%q, got=%q", "let a = 1;", line)
+	}
+}
+
+func TestCompleter(t *testing.T) {
+	env := object.NewEnvironment()
+	env.Set("lemon", &object.Integer{Value: 1})
+	inner := object.NewEnclosedEnvironment(env)
+	inner.Set("length", &object.Integer{Value: 2})
+
+	complete := completer(func() *object.Environment { return inner })
+
+	expected := []string{"lemon", "len", "length", "let"}
+	if actual := complete("le"); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("wrong completions. expected=%q, got=%q", expected, actual)
+	}
+
+	if actual := complete(""); len(actual) != 0 {
+		t.Errorf("empty word has completions: %q", actual)
+	}
+}
@@ -0,0 +1,113 @@
+package repl
+
+import (
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/token"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ANSI escape sequences for the styles used in the REPL.
+const (
+	styleReset   = "\x1b[0m"
+	styleBold    = "\x1b[1m"
+	styleDim     = "\x1b[2m"
+	styleRed     = "\x1b[31m"
+	styleGreen   = "\x1b[32m"
+	styleYellow  = "\x1b[33m"
+	styleBlue    = "\x1b[34m"
+	styleMagenta = "\x1b[35m"
+	styleCyan    = "\x1b[36m"
+
+	styleKeyword = styleMagenta
+	styleString  = styleGreen
+	styleNumber  = styleCyan
+	styleLiteral = styleYellow // true, false and null
+	styleBuiltin = styleBlue
+	styleIllegal = styleRed
+	styleComment = styleDim
+	styleError   = styleBold + styleRed
+)
+
+// colorEnabled reports whether output to the terminal fd should be coloured,
+// which it is unless NO_COLOR is set (see https://no-color.org).
+func colorEnabled(fd int) bool {
+	return os.Getenv("NO_COLOR") == "" && isTerminal(fd)
+}
+
+func paint(style, text string) string {
+	return style + text + styleReset
+}
+
+var tokenStyles = map[token.TokenType]string{
+	token.STRING:   styleString,
+	token.TEMPLATE: styleString,
+	token.INT:      styleNumber,
+	token.TRUE:     styleLiteral,
+	token.FALSE:    styleLiteral,
+	token.NULL:     styleLiteral,
+	token.ILLEGAL:  styleIllegal,
+	token.COMMENT:  styleComment,
+}
+
+// tokenStyle returns the style for tok, or "" if it is shown as it is.
+func tokenStyle(tok token.Token, builtins map[string]bool) string {
+	if style, ok := tokenStyles[tok.Type]; ok {
+		return style
+	}
+	if tok.Type == token.IDENT {
+		if builtins[tok.Literal] {
+			return styleBuiltin
+		}
+		return ""
+	}
+	if token.LookupIdent(tok.Literal) == tok.Type {
+		return styleKeyword
+	}
+	return ""
+}
+
+// highlight colours the tokens and comments of line by their kind. Each
+// token runs up to the next one, less the whitespace in between, so strings
+// keep their quotes and escapes.
+func highlight(line string) string {
+	builtins := map[string]bool{}
+	for _, name := range evaluator.BuiltinNames() {
+		builtins[name] = true
+	}
+
+	l := lexer.New(line)
+	tokens := []token.Token{}
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		tokens = append(tokens, tok)
+	}
+	tokens = append(tokens, l.Comments()...)
+	sort.SliceStable(tokens, func(i, j int) bool { return tokens[i].Offset < tokens[j].Offset })
+
+	if len(tokens) == 0 {
+		return line
+	}
+
+	var out strings.Builder
+	out.WriteString(line[:tokens[0].Offset])
+
+	for i, tok := range tokens {
+		end := len(line)
+		if i+1 < len(tokens) {
+			end = tokens[i+1].Offset
+		}
+
+		text := line[tok.Offset:end]
+		trimmed := strings.TrimRight(text, " \t\r\n")
+		if style := tokenStyle(tok, builtins); style != "" {
+			out.WriteString(paint(style, trimmed))
+		} else {
+			out.WriteString(trimmed)
+		}
+		out.WriteString(text[len(trimmed):])
+	}
+
+	return out.String()
+}
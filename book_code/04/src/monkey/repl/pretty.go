@@ -0,0 +1,225 @@
+package repl
+
+import (
+	"fmt"
+	"io"
+	"monkey/object"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	DEFAULT_WIDTH = 80
+	MAX_ITEMS     = 100  // elements of a collection shown before eliding the rest
+	MAX_STRING    = 1000 // characters of a string shown before eliding the rest
+)
+
+// printer prints values in the REPL. Arrays, hashes, sets and instances that
+// do not fit in the width are broken over several lines and indented, and
+// huge values are cut short.
+type printer struct {
+	color bool
+	width int
+}
+
+// layout is a value prepared for printing: either a leaf with its text, or a
+// group of items between open and close that is printed on one line if it
+// fits and with an item per line otherwise.
+type layout struct {
+	key *layout // shown before the value with ": ", for hash and instance entries
+
+	text  string
+	style string
+
+	group       bool
+	open, close string
+	items       []*layout
+}
+
+func (p *printer) print(out io.Writer, obj object.Object) {
+	if errObj, ok := obj.(*object.Error); ok {
+		if p.color {
+			fmt.Fprintf(out, "%s %s\n", p.paint(styleError, "ERROR:"), p.paint(styleRed, errObj.Message))
+		} else {
+			fmt.Fprintf(out, "%s\n", errObj.Inspect())
+		}
+		return
+	}
+
+	var sb strings.Builder
+	p.render(&sb, p.layout(obj, map[object.Object]bool{}), 0, 0, 0)
+	sb.WriteString("\n")
+	io.WriteString(out, sb.String())
+}
+
+func (p *printer) paint(style, text string) string {
+	if !p.color || style == "" {
+		return text
+	}
+	return paint(style, text)
+}
+
+// layout prepares obj for printing. seen holds the collections obj is inside
+// of, so that a collection containing itself is shown elided.
+func (p *printer) layout(obj object.Object, seen map[object.Object]bool) *layout {
+	switch obj := obj.(type) {
+	case *object.Integer, *object.BigInteger:
+		return &layout{text: obj.Inspect(), style: styleNumber}
+	case *object.Boolean, *object.Null:
+		return &layout{text: obj.Inspect(), style: styleLiteral}
+	case *object.String:
+		return &layout{text: truncateString(obj.Value), style: styleString}
+	case *object.Error:
+		return &layout{text: obj.Inspect(), style: styleError}
+	case *object.Array:
+		return p.group(obj, seen, "[", "]", func() []*layout {
+			return p.layoutElements(obj.Elements, seen)
+		})
+	case *object.Set:
+		return p.group(obj, seen, "#{", "}", func() []*layout {
+			return p.layoutElements(obj.Elements(), seen)
+		})
+	case *object.Hash:
+		return p.group(obj, seen, "{", "}", func() []*layout {
+			pairs := obj.Pairs()
+			items := []*layout{}
+			for i, pair := range pairs {
+				if i == MAX_ITEMS {
+					items = append(items, elided(len(pairs)-i))
+					break
+				}
+				item := p.layout(pair.Value, seen)
+				item.key = p.layout(pair.Key, seen)
+				items = append(items, item)
+			}
+			return items
+		})
+	case *object.Instance:
+		return p.group(obj, seen, obj.Struct.Name+"{", "}", func() []*layout {
+			items := []*layout{}
+			for i, name := range obj.Struct.Fields {
+				item := p.layout(obj.Values[i], seen)
+				item.key = &layout{text: name}
+				items = append(items, item)
+			}
+			return items
+		})
+	default:
+		return &layout{text: obj.Inspect()}
+	}
+}
+
+// group lays out a collection whose items are made by items, unless it is
+// already being laid out further up.
+func (p *printer) group(obj object.Object, seen map[object.Object]bool, open, close string, items func() []*layout) *layout {
+	if seen[obj] {
+		return &layout{text: open + "..." + close, style: styleDim}
+	}
+
+	seen[obj] = true
+	defer delete(seen, obj)
+
+	return &layout{group: true, open: open, close: close, items: items()}
+}
+
+func (p *printer) layoutElements(elements []object.Object, seen map[object.Object]bool) []*layout {
+	items := []*layout{}
+	for i, element := range elements {
+		if i == MAX_ITEMS {
+			items = append(items, elided(len(elements)-i))
+			break
+		}
+		items = append(items, p.layout(element, seen))
+	}
+	return items
+}
+
+func elided(count int) *layout {
+	return &layout{text: fmt.Sprintf("... %d more", count), style: styleDim}
+}
+
+func truncateString(s string) string {
+	count := utf8.RuneCountInString(s)
+	if count <= MAX_STRING {
+		return s
+	}
+
+	runes := []rune(s)
+	return fmt.Sprintf("%s... (%d more characters)", string(runes[:MAX_STRING]), count-MAX_STRING)
+}
+
+// flatWidth returns the number of characters l takes on one line.
+func flatWidth(l *layout) int {
+	width := 0
+	if l.key != nil {
+		width += flatWidth(l.key) + len(": ")
+	}
+
+	if !l.group {
+		return width + utf8.RuneCountInString(l.text)
+	}
+
+	width += utf8.RuneCountInString(l.open) + utf8.RuneCountInString(l.close)
+	for i, item := range l.items {
+		if i > 0 {
+			width += len(", ")
+		}
+		width += flatWidth(item)
+	}
+	return width
+}
+
+// render writes l starting at column, breaking groups that would run past the
+// width and indenting their items from indent. trailing is the number of
+// characters that follow l on its last line. A leaf is never broken.
+func (p *printer) render(out *strings.Builder, l *layout, column, indent, trailing int) {
+	if l.key != nil {
+		p.renderFlat(out, l.key)
+		out.WriteString(": ")
+		column += flatWidth(l.key) + len(": ")
+	}
+
+	if !l.group {
+		out.WriteString(p.paint(l.style, l.text))
+		return
+	}
+
+	keyless := *l
+	keyless.key = nil
+	if len(l.items) == 0 || column+flatWidth(&keyless)+trailing <= p.width {
+		p.renderFlat(out, &keyless)
+		return
+	}
+
+	out.WriteString(l.open)
+	out.WriteString("\n")
+	for _, item := range l.items {
+		itemIndent := indent + 2
+		out.WriteString(strings.Repeat(" ", itemIndent))
+		p.render(out, item, itemIndent, itemIndent, len(","))
+		out.WriteString(",\n")
+	}
+	out.WriteString(strings.Repeat(" ", indent))
+	out.WriteString(l.close)
+}
+
+func (p *printer) renderFlat(out *strings.Builder, l *layout) {
+	if l.key != nil {
+		p.renderFlat(out, l.key)
+		out.WriteString(": ")
+	}
+
+	if !l.group {
+		out.WriteString(p.paint(l.style, l.text))
+		return
+	}
+
+	out.WriteString(l.open)
+	for i, item := range l.items {
+		if i > 0 {
+			out.WriteString(", ")
+		}
+		p.renderFlat(out, item)
+	}
+	out.WriteString(l.close)
+}
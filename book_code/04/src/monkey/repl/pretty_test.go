@@ -0,0 +1,157 @@
+package repl
+
+import (
+	"bytes"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"strings"
+	"testing"
+)
+
+func evalForPrinting(t *testing.T, input string) object.Object {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return evaluator.Eval(program, object.NewEnvironment())
+}
+
+func TestPrinterWrapping(t *testing.T) {
+	tests := []struct {
+		input    string
+		width    int
+		expected string
+	}{
+		{`[1, "two", true, null]`, 80, "[1, two, true, null]\n"},
+		{`{"a": [1, 2], "b": #{3}}`, 80, "{a: [1, 2], b: #{3}}\n"},
+		{"[]", 1, "[]\n"},
+		{"[1, 2, 3]", 9, "[1, 2, 3]\n"},
+		{"[1, 2, 3]", 8, "[\n  1,\n  2,\n  3,\n]\n"},
+		{
+			`{"name": "monkey", "tags": ["a", "b", "c"]}`, 20,
+			"{\n" +
+				"  name: monkey,\n" +
+				"  tags: [a, b, c],\n" +
+				"}\n",
+		},
+		{
+			`{"name": "monkey", "tags": ["a", "b", "c"]}`, 16,
+			"{\n" +
+				"  name: monkey,\n" +
+				"  tags: [\n" +
+				"    a,\n" +
+				"    b,\n" +
+				"    c,\n" +
+				"  ],\n" +
+				"}\n",
+		},
+		{
+			"struct Point { x, y } Point(1, [2, 3])", 12,
+			"Point{\n  x: 1,\n  y: [2, 3],\n}\n",
+		},
+		{`"a long string is never broken"`, 5, "a long string is never broken\n"},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		p := &printer{width: tt.width}
+		p.print(&out, evalForPrinting(t, tt.input))
+
+		if out.String() != tt.expected {
+			t.Errorf("wrong output for %s at width %d.\nexpected=%q\ngot=     %q",
+				tt.input, tt.width, tt.expected, out.String())
+		}
+	}
+}
+
+func TestPrinterTruncation(t *testing.T) {
+	elements := make([]object.Object, MAX_ITEMS+5)
+	for i := range elements {
+		elements[i] = &object.Integer{Value: 0}
+	}
+
+	var out bytes.Buffer
+	p := &printer{width: 1 << 20}
+	p.print(&out, &object.Array{Elements: elements})
+
+	expected := "[" + strings.Repeat("0, ", MAX_ITEMS) + "... 5 more]\n"
+	if out.String() != expected {
+		t.Errorf("wrong output for long array. got=%q", out.String())
+	}
+
+	out.Reset()
+	p.print(&out, &object.String{Value: strings.Repeat("é", MAX_STRING+3)})
+
+	expected = strings.Repeat("é", MAX_STRING) + "... (3 more characters)\n"
+	if out.String() != expected {
+		t.Errorf("wrong output for long string. got=%q", out.String())
+	}
+}
+
+func TestPrinterCycles(t *testing.T) {
+	array := &object.Array{}
+	hash := object.NewHash()
+	hash.Set(&object.String{Value: "array"}, array)
+	array.Elements = []object.Object{&object.Integer{Value: 1}, array, hash}
+
+	var out bytes.Buffer
+	p := &printer{width: DEFAULT_WIDTH}
+	p.print(&out, array)
+
+	expected := "[1, [...], {array: [...]}]\n"
+	if out.String() != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=     %q", expected, out.String())
+	}
+}
+
+func TestPrinterColor(t *testing.T) {
+	var out bytes.Buffer
+	p := &printer{color: true, width: DEFAULT_WIDTH}
+
+	p.print(&out, evalForPrinting(t, `[1, "a", null, len]`))
+	expected := "[" + paint(styleNumber, "1") + ", " + paint(styleString, "a") + ", " +
+		paint(styleLiteral, "null") + ", builtin function]\n"
+	if out.String() != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=     %q", expected, out.String())
+	}
+
+	out.Reset()
+	p.print(&out, evalForPrinting(t, "1 + true"))
+	expected = paint(styleError, "ERROR:") + " " +
+		paint(styleRed, "type mismatch: INTEGER + BOOLEAN") + "\n"
+	if out.String() != expected {
+		t.Errorf("wrong error output.\nexpected=%q\ngot=     %q", expected, out.String())
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	input := `let s = len("a\"b") + 12 ;  if (true) { x }`
+
+	expected := paint(styleKeyword, "let") + " s = " +
+		paint(styleBuiltin, "len") + "(" + paint(styleString, `"a\"b"`) + ") + " +
+		paint(styleNumber, "12") + " ;  " + paint(styleKeyword, "if") + " (" +
+		paint(styleLiteral, "true") + ") { x }"
+
+	if actual := highlight(input); actual != expected {
+		t.Errorf("wrong highlighting.\nexpected=%q\ngot=     %q", expected, actual)
+	}
+
+	input = "1 + 2 // sum"
+	expected = paint(styleNumber, "1") + " + " + paint(styleNumber, "2") + " " +
+		paint(styleComment, "// sum")
+	if actual := highlight(input); actual != expected {
+		t.Errorf("wrong highlighting.\nexpected=%q\ngot=     %q", expected, actual)
+	}
+
+	for _, input := range []string{"", "   ", `  "unterminated`, "@"} {
+		actual := highlight(input)
+		plain := strings.NewReplacer(
+			styleReset, "", styleString, "", styleIllegal, "").Replace(actual)
+		if plain != input {
+			t.Errorf("highlight(%q) changed the text: %q", input, actual)
+		}
+	}
+}
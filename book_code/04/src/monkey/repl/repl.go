@@ -2,45 +2,159 @@ package repl
 
 import (
 	"bufio"
-	"fmt"
+	"errors"
 	"io"
 	"monkey/evaluator"
 	"monkey/lexer"
 	"monkey/object"
-	"monkey/parser"
+	"monkey/token"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
 )
 
-const PROMPT = ">> "
+const (
+	PROMPT              = ">> "
+	CONTINUATION_PROMPT = ".. "
 
-func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
+	HISTORY_FILE = ".monkey_history"
+)
+
+// lineReader reads the lines the REPL evaluates. ReadLine returns
+// errInterrupted when the user presses Ctrl-C, and io.EOF at the end of the
+// input.
+type lineReader interface {
+	ReadLine(prompt string) (string, error)
+}
+
+var errInterrupted = errors.New("interrupted")
+
+// Start runs the REPL until in is exhausted. When in is a terminal, lines
+// are read with the line editor; otherwise they are read as they come. An
+// interrupt (Ctrl-C) cancels the input typed so far instead of ending the
+// process. Output to a terminal is coloured, unless NO_COLOR is set, and
+// values are wrapped to its width. Input is evaluated with ctx.
+func Start(in io.Reader, out io.Writer, ctx *evaluator.Context) {
+	s := newSession(out, ctx)
+	if file, ok := out.(*os.File); ok {
+		s.printer.color = colorEnabled(int(file.Fd()))
+		if width := terminalWidth(int(file.Fd())); width > 0 {
+			s.printer.width = width
+		}
+	}
+
+	if file, ok := in.(*os.File); ok && isTerminal(int(file.Fd())) {
+		editor := NewEditor(file, out, int(file.Fd()))
+		editor.Complete = completer(func() *object.Environment { return s.env })
+		if s.printer.color {
+			editor.Highlight = highlight
+		}
+		if home, err := os.UserHomeDir(); err == nil {
+			editor.LoadHistory(filepath.Join(home, HISTORY_FILE))
+		}
+
+		run(editor, s)
+		return
+	}
+
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+
+	run(&scannerReader{out: out, lines: readLines(in), interrupts: interrupts}, s)
+}
+
+func run(reader lineReader, s *session) {
+	pending := []string{}
 
 	for {
-		fmt.Fprintf(out, PROMPT)
-		scanned := scanner.Scan()
-		if !scanned {
-			return
+		prompt := PROMPT
+		if len(pending) > 0 {
+			prompt = CONTINUATION_PROMPT
 		}
 
-		line := scanner.Text()
-		l := lexer.New(line)
-		p := parser.New(l)
+		line, err := reader.ReadLine(prompt)
+		if err == errInterrupted {
+			pending = pending[:0]
+			continue
+		}
+		if err != nil {
+			return
+		}
 
-		program := p.ParseProgram()
-		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
+		pending = append(pending, line)
+		source := strings.Join(pending, "\n")
+		if isIncomplete(source) {
 			continue
 		}
+		pending = pending[:0]
+
+		if strings.HasPrefix(strings.TrimSpace(source), ":") {
+			s.runCommand(strings.TrimSpace(source))
+		} else {
+			s.eval(source)
+		}
+	}
+}
 
-		evaluated := evaluator.Eval(program, env)
-		if evaluated != nil {
-			io.WriteString(out, evaluated.Inspect())
-			io.WriteString(out, "\n")
+// scannerReader reads lines without editing, for input that is not a
+// terminal. Interrupts arrive as signals.
+type scannerReader struct {
+	out        io.Writer
+	lines      <-chan string
+	interrupts <-chan os.Signal
+}
+
+func (sr *scannerReader) ReadLine(prompt string) (string, error) {
+	io.WriteString(sr.out, prompt)
+
+	select {
+	case line, ok := <-sr.lines:
+		if !ok {
+			return "", io.EOF
 		}
+		return line, nil
+	case <-sr.interrupts:
+		io.WriteString(sr.out, "\n")
+		return "", errInterrupted
 	}
 }
 
+// readLines sends the lines of in on the returned channel, which is closed
+// when in is exhausted.
+func readLines(in io.Reader) <-chan string {
+	lines := make(chan string)
+
+	go func() {
+		scanner := bufio.NewScanner(in)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	return lines
+}
+
+// isIncomplete reports whether source ends inside a string or with brackets
+// left open, so that the next line should continue it.
+func isIncomplete(source string) bool {
+	l := lexer.New(source)
+	depth := 0
+
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACE, token.LBRACKET, token.SET_LBRACE:
+			depth += 1
+		case token.RPAREN, token.RBRACE, token.RBRACKET:
+			depth -= 1
+		}
+	}
+
+	return depth > 0 || l.Unterminated()
+}
+
 const MONKEY_FACE = `            __,__
    .--.  .-"     "-.  .--.
   / .. \/  .-. .-.  \/ .. \
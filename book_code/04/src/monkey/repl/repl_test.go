@@ -0,0 +1,188 @@
+package repl
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsIncomplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"let a = 1;", false},
+		{"let f = fn(x) {", true},
+		{"let f = fn(x) {\n  x\n};", false},
+		{"[1, 2,", true},
+		{"#{1,", true},
+		{"puts(", true},
+		{`"abc`, true},
+		{`"abc ${ "x" }`, true},
+		{`"abc ${ {"a": 1}["a"]`, true},
+		{"`raw\nstring", true},
+		{`"a { b"`, false},
+		{"}", false},
+	}
+
+	for _, tt := range tests {
+		if actual := isIncomplete(tt.input); actual != tt.expected {
+			t.Errorf("isIncomplete(%q) wrong. expected=%t, got=%t",
+				tt.input, tt.expected, actual)
+		}
+	}
+}
+
+// scriptedReader plays back lines, with an empty string standing for an
+// interrupt.
+type scriptedReader struct {
+	out   *bytes.Buffer
+	lines []string
+}
+
+func (sr *scriptedReader) ReadLine(prompt string) (string, error) {
+	sr.out.WriteString(prompt)
+	if len(sr.lines) == 0 {
+		return "", io.EOF
+	}
+
+	line := sr.lines[0]
+	sr.lines = sr.lines[1:]
+	if line == "" {
+		sr.out.WriteString("^C\n")
+		return "", errInterrupted
+	}
+	return line, nil
+}
+
+func TestMultiLineInput(t *testing.T) {
+	var out bytes.Buffer
+	reader := &scriptedReader{out: &out, lines: []string{
+		"let add = fn(a, b) {",
+		"  a + b",
+		"};",
+		"add(1,",
+		"2)",
+		"let broken = [1,",
+		"",
+		"broken",
+	}}
+
+	run(reader, newSession(&out, nil))
+
+	expected := ">> .. .. >> .. 3\n" +
+		">> .. ^C\n" +
+		">> ERROR: identifier not found: broken\n" +
+		">> "
+	if out.String() != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=     %q", expected, out.String())
+	}
+}
+
+// runLines runs the REPL on lines and returns its output without prompts.
+func runLines(s *session, lines ...string) string {
+	var out bytes.Buffer
+	s.out = &out
+	run(&scriptedReader{out: &bytes.Buffer{}, lines: lines}, s)
+	return out.String()
+}
+
+func TestCommands(t *testing.T) {
+	tests := []struct {
+		lines    []string
+		expected string
+	}{
+		{
+			[]string{"let b = [1];", "let a = 1;", ":env"},
+			"a: INTEGER = 1\nb: ARRAY = [1]\n",
+		},
+		{[]string{":type 1 + 2"}, "INTEGER\n"},
+		{[]string{`let s = "x";`, ":type s"}, "STRING\n"},
+		{[]string{":type let a = 1;"}, "no value\n"},
+		{[]string{":type"}, "usage: :type EXPR  show the type of the value of EXPR\n"},
+		{
+			[]string{":ast -a"},
+			"Program\n" +
+				"  Statements[0]: ExpressionStatement\n" +
+				"    Expression: PrefixExpression Operator=-\n" +
+				"      Right: Identifier Value=a\n",
+		},
+		{
+			[]string{":tokens let x =\n  5;"},
+			"1:1\tLET\t\"let\"\n" +
+				"1:5\tIDENT\t\"x\"\n" +
+				"1:7\t=\t\"=\"\n" +
+				"2:3\tINT\t\"5\"\n" +
+				"2:4\t;\t\";\"\n",
+		},
+		{[]string{":type fn(x) {", "x", "}"}, "FUNCTION\n"},
+		{[]string{"let a = 1;", ":reset", "a"}, "ERROR: identifier not found: a\n"},
+		{[]string{":nope"}, "unknown command :nope (type :help for a list)\n"},
+	}
+
+	for _, tt := range tests {
+		actual := runLines(newSession(nil, nil), tt.lines...)
+		if actual != tt.expected {
+			t.Errorf("wrong output for %q.\nexpected=%q\ngot=     %q",
+				tt.lines, tt.expected, actual)
+		}
+	}
+}
+
+func TestCommandParserErrors(t *testing.T) {
+	actual := runLines(newSession(nil, nil), ":ast 1 +")
+
+	if !strings.HasSuffix(actual, " parser errors:\n\tno prefix parse function for EOF found\n") {
+		t.Errorf("wrong output. got=%q", actual)
+	}
+}
+
+func TestTimeCommand(t *testing.T) {
+	actual := runLines(newSession(nil, nil), ":time 1 + 2")
+
+	if !strings.HasPrefix(actual, "3\ntook ") {
+		t.Errorf("wrong output. got=%q", actual)
+	}
+}
+
+func TestSaveAndLoadCommands(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey-repl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "session.code")
+
+	saved := runLines(newSession(nil, nil),
+		"let add = fn(a, b) {",
+		"  a + b",
+		"};",
+		"let broken = ;",
+		"let three = add(1, 2);",
+		":save "+path,
+	)
+	if !strings.HasSuffix(saved, "saved 2 inputs to "+path+"\n") {
+		t.Errorf("wrong output for :save. got=%q", saved)
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "let add = fn(a, b) {\n  a + b\n};\nlet three = add(1, 2);\n"
+	if string(content) != expected {
+		t.Errorf("wrong file content.\nexpected=%q\ngot=     %q", expected, string(content))
+	}
+
+	loaded := runLines(newSession(nil, nil), ":load "+path, "three", "add(2, 2)")
+	if loaded != "3\n4\n" {
+		t.Errorf("wrong output after :load. got=%q", loaded)
+	}
+
+	missing := runLines(newSession(nil, nil), ":load "+filepath.Join(dir, "missing.code"))
+	if !strings.Contains(missing, "no such file or directory") {
+		t.Errorf("wrong output for missing file. got=%q", missing)
+	}
+}
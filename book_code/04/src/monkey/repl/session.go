@@ -0,0 +1,229 @@
+package repl
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/token"
+	"sort"
+	"strings"
+	"time"
+)
+
+// session is the state of a REPL: its environment and the inputs that were
+// evaluated in it.
+type session struct {
+	out     io.Writer
+	printer *printer
+	context *evaluator.Context
+	env     *object.Environment
+	inputs  []string // evaluated without parser errors, for :save
+}
+
+func newSession(out io.Writer, ctx *evaluator.Context) *session {
+	return &session{
+		out:     out,
+		printer: &printer{width: DEFAULT_WIDTH},
+		context: ctx,
+		env:     evaluator.NewEnvironment(ctx),
+	}
+}
+
+// parse parses source, printing the parser errors if there are any.
+func (s *session) parse(source string) (*ast.Program, bool) {
+	p := parser.New(lexer.New(source))
+
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		printParserErrors(s.out, p.Errors())
+		return nil, false
+	}
+
+	return program, true
+}
+
+// eval evaluates source in the session and prints the result.
+func (s *session) eval(source string) {
+	program, ok := s.parse(source)
+	if !ok {
+		return
+	}
+	s.inputs = append(s.inputs, source)
+
+	s.print(evaluator.Eval(program, s.env))
+}
+
+func (s *session) print(evaluated object.Object) {
+	if evaluated != nil {
+		s.printer.print(s.out, evaluated)
+	}
+}
+
+type command struct {
+	name  string
+	usage string
+	run   func(s *session, arg string)
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"env", ":env  list the bindings of the session", (*session).commandEnv},
+		{"type", ":type EXPR  show the type of the value of EXPR", (*session).commandType},
+		{"ast", ":ast EXPR  show the syntax tree of EXPR", (*session).commandAST},
+		{"tokens", ":tokens EXPR  show the tokens of EXPR with their positions", (*session).commandTokens},
+		{"load", ":load FILE  evaluate the code in FILE", (*session).commandLoad},
+		{"reset", ":reset  start over with an empty environment", (*session).commandReset},
+		{"time", ":time EXPR  evaluate EXPR and show how long it took", (*session).commandTime},
+		{"save", ":save FILE  write the inputs of the session to FILE", (*session).commandSave},
+		{"help", ":help  list the commands", (*session).commandHelp},
+	}
+}
+
+// runCommand runs a line like ":type 1 + 2".
+func (s *session) runCommand(line string) {
+	name := strings.TrimPrefix(line, ":")
+	arg := ""
+	if i := strings.IndexAny(name, " \t\n"); i >= 0 {
+		name, arg = name[:i], strings.TrimSpace(name[i+1:])
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == name {
+			cmd.run(s, arg)
+			return
+		}
+	}
+
+	fmt.Fprintf(s.out, "unknown command :%s (type :help for a list)\n", name)
+}
+
+// requireArg prints the usage of the named command if arg is empty.
+func (s *session) requireArg(name, arg string) bool {
+	if arg != "" {
+		return true
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == name {
+			fmt.Fprintf(s.out, "usage: %s\n", cmd.usage)
+		}
+	}
+	return false
+}
+
+func (s *session) commandEnv(arg string) {
+	names := s.env.Names()
+	sort.Strings(names)
+
+	for _, name := range names {
+		value, _ := s.env.Get(name)
+		fmt.Fprintf(s.out, "%s: %s = %s\n", name, value.Type(), value.Inspect())
+	}
+}
+
+func (s *session) commandType(arg string) {
+	if !s.requireArg("type", arg) {
+		return
+	}
+	program, ok := s.parse(arg)
+	if !ok {
+		return
+	}
+
+	evaluated := evaluator.Eval(program, s.env)
+	if evaluated == nil {
+		io.WriteString(s.out, "no value\n")
+		return
+	}
+	fmt.Fprintf(s.out, "%s\n", evaluated.Type())
+}
+
+func (s *session) commandAST(arg string) {
+	if !s.requireArg("ast", arg) {
+		return
+	}
+	program, ok := s.parse(arg)
+	if !ok {
+		return
+	}
+
+	io.WriteString(s.out, ast.Dump(program))
+}
+
+func (s *session) commandTokens(arg string) {
+	if !s.requireArg("tokens", arg) {
+		return
+	}
+
+	l := lexer.New(arg)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		fmt.Fprintf(s.out, "%d:%d\t%s\t%q\n", tok.Line, tok.Column, tok.Type, tok.Literal)
+	}
+}
+
+func (s *session) commandLoad(arg string) {
+	if !s.requireArg("load", arg) {
+		return
+	}
+
+	source, err := ioutil.ReadFile(arg)
+	if err != nil {
+		fmt.Fprintf(s.out, "%s\n", err)
+		return
+	}
+
+	s.eval(string(source))
+}
+
+func (s *session) commandReset(arg string) {
+	s.env = evaluator.NewEnvironment(s.context)
+	s.inputs = nil
+}
+
+func (s *session) commandTime(arg string) {
+	if !s.requireArg("time", arg) {
+		return
+	}
+	program, ok := s.parse(arg)
+	if !ok {
+		return
+	}
+	s.inputs = append(s.inputs, arg)
+
+	start := time.Now()
+	evaluated := evaluator.Eval(program, s.env)
+	elapsed := time.Since(start)
+
+	s.print(evaluated)
+	fmt.Fprintf(s.out, "took %s\n", elapsed)
+}
+
+func (s *session) commandSave(arg string) {
+	if !s.requireArg("save", arg) {
+		return
+	}
+
+	content := strings.Join(s.inputs, "\n")
+	if len(s.inputs) > 0 {
+		content += "\n"
+	}
+
+	if err := ioutil.WriteFile(arg, []byte(content), 0644); err != nil {
+		fmt.Fprintf(s.out, "%s\n", err)
+		return
+	}
+	fmt.Fprintf(s.out, "saved %d inputs to %s\n", len(s.inputs), arg)
+}
+
+func (s *session) commandHelp(arg string) {
+	for _, cmd := range commands {
+		fmt.Fprintf(s.out, "%s\n", cmd.usage)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+// +build !linux
+
+package repl
+
+import "errors"
+
+func isTerminal(fd int) bool {
+	return false
+}
+
+func makeRaw(fd int) (func(), error) {
+	return nil, errors.New("raw terminal mode is only supported on Linux")
+}
+
+func terminalWidth(fd int) int {
+	return 0
+}
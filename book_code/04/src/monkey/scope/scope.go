@@ -0,0 +1,259 @@
+// Package scope resolves the identifiers of a program to the bindings they
+// refer to, following the scoping of the evaluator: a program and each
+// function call have an environment of their own, as does each match arm,
+// while if blocks share the environment around them.
+//
+// A name is looked up when the function it is in runs, so a function may
+// refer to a binding made after it, as a recursive function refers to
+// itself. An identifier that is not bound earlier in its scope is resolved
+// to the binding of that name at the end of the scope, if there is one.
+package scope
+
+import (
+	"monkey/ast"
+	"sort"
+)
+
+type Kind int
+
+const (
+	LET       Kind = iota // let x = ...
+	PARAMETER             // fn(x) ...
+	PATTERN               // a name bound by a match arm
+	STRUCT                // struct X { ... }
+)
+
+func (k Kind) String() string {
+	switch k {
+	case LET:
+		return "let"
+	case PARAMETER:
+		return "parameter"
+	case PATTERN:
+		return "pattern"
+	case STRUCT:
+		return "struct"
+	default:
+		return "unknown"
+	}
+}
+
+// Binding is a name bound once in the program.
+type Binding struct {
+	Name     string
+	Kind     Kind
+	Ident    *ast.Identifier   // where the name is bound
+	Value    ast.Expression    // the value of a let binding without destructuring
+	TopLevel bool              // whether it is bound in the program's own scope
+	Uses     []*ast.Identifier // the identifiers referring to it, in source order
+}
+
+// Info is the result of resolving a program.
+type Info struct {
+	Bindings   []*Binding                   // in source order
+	Defs       map[*ast.Identifier]*Binding // the binding made at each binding identifier
+	Uses       map[*ast.Identifier]*Binding // the binding each resolved identifier refers to
+	Unresolved []*ast.Identifier            // identifiers bound nowhere in the program, such as builtins
+}
+
+// BindingAt returns the binding made or referred to by ident, or nil.
+func (info *Info) BindingAt(ident *ast.Identifier) *Binding {
+	if b, ok := info.Defs[ident]; ok {
+		return b
+	}
+	return info.Uses[ident]
+}
+
+type scope struct {
+	parent   *scope
+	names    map[string]*Binding
+	deferred []*ast.Identifier // not bound when they were reached
+}
+
+type resolver struct {
+	info  *Info
+	scope *scope
+}
+
+// Resolve resolves the identifiers in program.
+func Resolve(program *ast.Program) *Info {
+	r := &resolver{info: &Info{
+		Defs: map[*ast.Identifier]*Binding{},
+		Uses: map[*ast.Identifier]*Binding{},
+	}}
+
+	r.open()
+	for _, stmt := range program.Statements {
+		r.statement(stmt)
+	}
+	r.close()
+
+	// deferred uses were added after the ones that follow them
+	for _, b := range r.info.Bindings {
+		uses := b.Uses
+		sort.SliceStable(uses, func(i, j int) bool {
+			return uses[i].Token.Offset < uses[j].Token.Offset
+		})
+	}
+
+	return r.info
+}
+
+func (r *resolver) open() {
+	r.scope = &scope{parent: r.scope, names: map[string]*Binding{}}
+}
+
+// close resolves the identifiers deferred in the current scope to its
+// bindings, or defers them to the scope around it.
+func (r *resolver) close() {
+	s := r.scope
+	r.scope = s.parent
+
+	for _, ident := range s.deferred {
+		if b, ok := s.names[ident.Value]; ok {
+			r.use(b, ident)
+		} else if r.scope != nil {
+			r.scope.deferred = append(r.scope.deferred, ident)
+		} else {
+			r.info.Unresolved = append(r.info.Unresolved, ident)
+		}
+	}
+}
+
+func (r *resolver) bind(ident *ast.Identifier, kind Kind, value ast.Expression) {
+	b := &Binding{
+		Name:     ident.Value,
+		Kind:     kind,
+		Ident:    ident,
+		Value:    value,
+		TopLevel: r.scope.parent == nil,
+	}
+	r.scope.names[ident.Value] = b
+	r.info.Bindings = append(r.info.Bindings, b)
+	r.info.Defs[ident] = b
+}
+
+func (r *resolver) use(b *Binding, ident *ast.Identifier) {
+	b.Uses = append(b.Uses, ident)
+	r.info.Uses[ident] = b
+}
+
+func (r *resolver) reference(ident *ast.Identifier) {
+	for s := r.scope; s != nil; s = s.parent {
+		if b, ok := s.names[ident.Value]; ok {
+			r.use(b, ident)
+			return
+		}
+	}
+	r.scope.deferred = append(r.scope.deferred, ident)
+}
+
+func (r *resolver) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		// the value is evaluated before the name is bound
+		r.expression(stmt.Value)
+		if stmt.Pattern != nil {
+			r.pattern(stmt.Pattern, LET)
+		} else {
+			r.bind(stmt.Name, LET, stmt.Value)
+		}
+
+	case *ast.ReturnStatement:
+		r.expression(stmt.ReturnValue)
+
+	case *ast.StructStatement:
+		r.bind(stmt.Name, STRUCT, nil)
+		for _, method := range stmt.Methods {
+			r.function(method.Function)
+		}
+
+	case *ast.ExpressionStatement:
+		r.expression(stmt.Expression)
+	}
+}
+
+func (r *resolver) block(block *ast.BlockStatement) {
+	for _, stmt := range block.Statements {
+		r.statement(stmt)
+	}
+}
+
+func (r *resolver) function(fn *ast.FunctionLiteral) {
+	r.open()
+	for _, param := range fn.Parameters {
+		r.expression(fn.Defaults[param.Value])
+		r.bind(param, PARAMETER, nil)
+	}
+	if fn.Rest != nil {
+		r.bind(fn.Rest, PARAMETER, nil)
+	}
+	r.block(fn.Body)
+	r.close()
+}
+
+// pattern binds the names in pattern and resolves the identifiers in its
+// literals.
+func (r *resolver) pattern(pattern ast.Pattern, kind Kind) {
+	ast.Inspect(pattern, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.BindingPattern:
+			r.bind(node.Name, kind, nil)
+			return false
+		case *ast.ArrayPattern:
+			for _, el := range node.Elements {
+				r.pattern(el, kind)
+			}
+			if node.Rest != nil {
+				r.bind(node.Rest, kind, nil)
+			}
+			return false
+		case *ast.LiteralPattern:
+			r.expression(node.Value)
+			return false
+		}
+		return true
+	})
+}
+
+func (r *resolver) expression(e ast.Expression) {
+	if e == nil {
+		return
+	}
+
+	ast.Inspect(e, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.Identifier:
+			r.reference(node)
+
+		case *ast.MemberExpression:
+			// the property is a name looked up in the object
+			r.expression(node.Object)
+			return false
+
+		case *ast.FunctionLiteral:
+			r.function(node)
+			return false
+
+		case *ast.IfExpression:
+			r.expression(node.Condition)
+			r.block(node.Consequence)
+			if node.Alternative != nil {
+				r.block(node.Alternative)
+			}
+			return false
+
+		case *ast.MatchExpression:
+			r.expression(node.Subject)
+			for _, arm := range node.Arms {
+				r.open()
+				r.pattern(arm.Pattern, PATTERN)
+				r.expression(arm.Guard)
+				r.expression(arm.Body)
+				r.close()
+			}
+			return false
+		}
+		return true
+	})
+}
@@ -0,0 +1,133 @@
+package scope
+
+import (
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"testing"
+)
+
+func resolve(t *testing.T, input string) *Info {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return Resolve(program)
+}
+
+// uses returns the lines and columns of the uses of the bindings of name, in
+// the order the bindings were made.
+func uses(info *Info, name string) [][][2]int {
+	result := [][][2]int{}
+	for _, b := range info.Bindings {
+		if b.Name != name {
+			continue
+		}
+		positions := [][2]int{}
+		for _, use := range b.Uses {
+			positions = append(positions, [2]int{use.Token.Line, use.Token.Column})
+		}
+		result = append(result, positions)
+	}
+	return result
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		input    string
+		name     string
+		expected [][][2]int
+	}{
+		{"let x = 1; x + x", "x", [][][2]int{{{1, 12}, {1, 16}}}},
+		{"let x = 1; let x = x + 1; x", "x", [][][2]int{{{1, 20}}, {{1, 27}}}},
+		{"let f = fn(n) { f(n) }; f(1)", "f", [][][2]int{{{1, 17}, {1, 25}}}},
+		{"let f = fn(n) { f(n) }; f(1)", "n", [][][2]int{{{1, 19}}}},
+		{"let f = fn() { g() }; let g = fn() { 1 };", "g", [][][2]int{{{1, 16}}}},
+		{"let x = 1; fn(x) { x }", "x", [][][2]int{{}, {{1, 20}}}},
+		{"let a = 1; fn(x, y = a) { y }", "a", [][][2]int{{{1, 22}}}},
+		{"if (true) { let y = 1; } y", "y", [][][2]int{{{1, 26}}}},
+		{"let p = 1; p.p", "p", [][][2]int{{{1, 12}}}},
+		{"let [a, ...rest] = [1]; rest", "rest", [][][2]int{{{1, 25}}}},
+		{"let x = 1; match (x) { [x] => x, _ => x }", "x", [][][2]int{{{1, 19}, {1, 39}}, {{1, 31}}}},
+		{"struct P { x; fn get() { P } }", "P", [][][2]int{{{1, 26}}}},
+	}
+
+	for _, tt := range tests {
+		info := resolve(t, tt.input)
+		actual := uses(info, tt.name)
+
+		if len(actual) != len(tt.expected) {
+			t.Errorf("%q: wrong number of bindings of %s. expected=%v, got=%v",
+				tt.input, tt.name, tt.expected, actual)
+			continue
+		}
+		for i := range tt.expected {
+			if len(actual[i]) != len(tt.expected[i]) {
+				t.Errorf("%q: wrong uses of binding %d of %s. expected=%v, got=%v",
+					tt.input, i, tt.name, tt.expected[i], actual[i])
+				continue
+			}
+			for j := range tt.expected[i] {
+				if actual[i][j] != tt.expected[i][j] {
+					t.Errorf("%q: wrong uses of binding %d of %s. expected=%v, got=%v",
+						tt.input, i, tt.name, tt.expected[i], actual[i])
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestBindingKinds(t *testing.T) {
+	info := resolve(t, `let f = fn(a, ...b) { match (a) { c => c } }; struct S { x }`)
+
+	expected := []struct {
+		name     string
+		kind     Kind
+		topLevel bool
+	}{
+		{"a", PARAMETER, false},
+		{"b", PARAMETER, false},
+		{"c", PATTERN, false},
+		{"f", LET, true},
+		{"S", STRUCT, true},
+	}
+
+	if len(info.Bindings) != len(expected) {
+		t.Fatalf("wrong number of bindings. expected=%d, got=%d", len(expected), len(info.Bindings))
+	}
+	for i, e := range expected {
+		b := info.Bindings[i]
+		if b.Name != e.name || b.Kind != e.kind || b.TopLevel != e.topLevel {
+			t.Errorf("binding %d wrong. expected=%s %s (top level %t), got=%s %s (top level %t)",
+				i, e.kind, e.name, e.topLevel, b.Kind, b.Name, b.TopLevel)
+		}
+	}
+
+	if _, ok := info.Bindings[3].Value.(*ast.FunctionLiteral); !ok {
+		t.Errorf("value of f is not *ast.FunctionLiteral. got=%T", info.Bindings[3].Value)
+	}
+}
+
+func TestUnresolved(t *testing.T) {
+	info := resolve(t, `let x = len("a"); puts(x, y)`)
+
+	names := []string{}
+	for _, ident := range info.Unresolved {
+		names = append(names, ident.Value)
+	}
+	expected := []string{"len", "puts", "y"}
+
+	if len(names) != len(expected) {
+		t.Fatalf("wrong unresolved names. expected=%v, got=%v", expected, names)
+	}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Errorf("wrong unresolved names. expected=%v, got=%v", expected, names)
+			break
+		}
+	}
+}
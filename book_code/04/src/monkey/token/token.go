@@ -5,11 +5,13 @@ type TokenType string
 const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
+	COMMENT = "COMMENT" // "// ..." up to the end of the line
 
 	// Identifiers + literals
-	IDENT  = "IDENT"  // add, foobar, x, y, ...
-	INT    = "INT"    // 1343456
-	STRING = "STRING" // "foobar"
+	IDENT    = "IDENT"    // add, foobar, x, y, ...
+	INT      = "INT"      // 1343456
+	STRING   = "STRING"   // "foobar"
+	TEMPLATE = "TEMPLATE" // "Hello ${name}"
 
 	// Operators
 	ASSIGN   = "="
@@ -25,10 +27,17 @@ const (
 	EQ     = "=="
 	NOT_EQ = "!="
 
+	AND     = "&&"
+	OR      = "||"
+	NULLISH = "??"
+
 	// Delimiters
 	COMMA     = ","
 	SEMICOLON = ";"
 	COLON     = ":"
+	ARROW     = "=>"
+	ELLIPSIS  = "..."
+	DOT       = "."
 
 	LPAREN   = "("
 	RPAREN   = ")"
@@ -37,6 +46,8 @@ const (
 	LBRACKET = "["
 	RBRACKET = "]"
 
+	SET_LBRACE = "#{"
+
 	// Keywords
 	FUNCTION = "FUNCTION"
 	LET      = "LET"
@@ -45,11 +56,18 @@ const (
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	NULL     = "NULL"
+	MATCH    = "MATCH"
+	STRUCT   = "STRUCT"
+	IN       = "IN"
 )
 
 type Token struct {
 	Type    TokenType
 	Literal string
+	Line    int
+	Column  int
+	Offset  int // byte offset of the start in the input
 }
 
 var keywords = map[string]TokenType{
@@ -60,6 +78,19 @@ var keywords = map[string]TokenType{
 	"if":     IF,
 	"else":   ELSE,
 	"return": RETURN,
+	"null":   NULL,
+	"match":  MATCH,
+	"struct": STRUCT,
+	"in":     IN,
+}
+
+// Keywords returns the reserved words of the language.
+func Keywords() []string {
+	words := make([]string, 0, len(keywords))
+	for word := range keywords {
+		words = append(words, word)
+	}
+	return words
 }
 
 func LookupIdent(ident string) TokenType {
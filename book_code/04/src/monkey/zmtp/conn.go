@@ -0,0 +1,313 @@
+// Package zmtp speaks ZMTP 3.0, the wire protocol of ZeroMQ, with the NULL
+// security mechanism. It implements the socket types a Jupyter kernel and
+// its clients use, so the kernel needs no C library.
+package zmtp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+)
+
+type SocketType string
+
+const (
+	ROUTER SocketType = "ROUTER"
+	DEALER SocketType = "DEALER"
+	PUB    SocketType = "PUB"
+	SUB    SocketType = "SUB"
+	REP    SocketType = "REP"
+	REQ    SocketType = "REQ"
+)
+
+// Message is a multipart message, one byte slice per frame.
+type Message [][]byte
+
+const (
+	flagMore    = 0x01
+	flagLong    = 0x02
+	flagCommand = 0x04
+
+	GREETING_SIZE  = 64
+	MAX_FRAME_SIZE = 1 << 30
+)
+
+var errClosed = errors.New("zmtp: socket closed")
+
+// Conn is a connection to a single peer, ready to exchange messages once the
+// greeting and handshake are done.
+type Conn struct {
+	// PeerType and PeerIdentity are what the peer announced in its
+	// handshake.
+	PeerType     SocketType
+	PeerIdentity []byte
+
+	conn    net.Conn
+	reader  *bufio.Reader
+	writeMu sync.Mutex
+}
+
+// Dial connects to address, given as "tcp://host:port" or "host:port", as a
+// socket of type socketType.
+func Dial(address string, socketType SocketType) (*Conn, error) {
+	conn, err := net.Dial("tcp", strings.TrimPrefix(address, "tcp://"))
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := newConn(conn, socketType, nil)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// newConn does the greeting and handshake on conn, announcing socketType and
+// identity.
+func newConn(conn net.Conn, socketType SocketType, identity []byte) (*Conn, error) {
+	c := &Conn{conn: conn, reader: bufio.NewReader(conn)}
+
+	if err := c.greet(); err != nil {
+		return nil, err
+	}
+	if err := c.handshake(socketType, identity); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// greet exchanges greetings: a signature, version 3.0, the NULL mechanism
+// and padding.
+func (c *Conn) greet() error {
+	greeting := make([]byte, GREETING_SIZE)
+	greeting[0] = 0xff
+	greeting[9] = 0x7f
+	greeting[10] = 3
+	greeting[11] = 0
+	copy(greeting[12:32], "NULL")
+
+	if _, err := c.conn.Write(greeting); err != nil {
+		return err
+	}
+
+	peer := make([]byte, GREETING_SIZE)
+	if _, err := io.ReadFull(c.reader, peer); err != nil {
+		return err
+	}
+	if peer[0] != 0xff || peer[9] != 0x7f {
+		return errors.New("zmtp: peer did not send a ZMTP greeting")
+	}
+	if peer[10] < 3 {
+		return fmt.Errorf("zmtp: unsupported version %d.%d", peer[10], peer[11])
+	}
+	if mechanism := string(bytes.TrimRight(peer[12:32], "\x00")); mechanism != "NULL" {
+		return fmt.Errorf("zmtp: unsupported security mechanism %s", mechanism)
+	}
+
+	return nil
+}
+
+// handshake exchanges READY commands carrying the socket types and
+// identities.
+func (c *Conn) handshake(socketType SocketType, identity []byte) error {
+	var ready bytes.Buffer
+	writeProperty(&ready, "Socket-Type", []byte(socketType))
+	if len(identity) > 0 {
+		writeProperty(&ready, "Identity", identity)
+	}
+	if err := c.writeCommand("READY", ready.Bytes()); err != nil {
+		return err
+	}
+
+	name, data, err := c.readCommand()
+	if err != nil {
+		return err
+	}
+	if name == "ERROR" && len(data) > 0 {
+		return fmt.Errorf("zmtp: peer refused the connection: %s", data[1:])
+	}
+	if name != "READY" {
+		return fmt.Errorf("zmtp: expected READY command, got %s", name)
+	}
+
+	properties, err := parseProperties(data)
+	if err != nil {
+		return err
+	}
+	c.PeerType = SocketType(properties["Socket-Type"])
+	c.PeerIdentity = properties["Identity"]
+
+	return nil
+}
+
+func writeProperty(out *bytes.Buffer, name string, value []byte) {
+	out.WriteByte(byte(len(name)))
+	out.WriteString(name)
+	binary.Write(out, binary.BigEndian, uint32(len(value)))
+	out.Write(value)
+}
+
+func parseProperties(data []byte) (map[string][]byte, error) {
+	properties := map[string][]byte{}
+
+	for len(data) > 0 {
+		nameLength := int(data[0])
+		if len(data) < 1+nameLength+4 {
+			return nil, errors.New("zmtp: malformed property")
+		}
+		name := string(data[1 : 1+nameLength])
+		data = data[1+nameLength:]
+
+		valueLength := binary.BigEndian.Uint32(data)
+		data = data[4:]
+		if uint32(len(data)) < valueLength {
+			return nil, errors.New("zmtp: malformed property")
+		}
+		properties[name] = data[:valueLength]
+		data = data[valueLength:]
+	}
+
+	return properties, nil
+}
+
+// Send sends msg as one frame per part.
+func (c *Conn) Send(msg Message) error {
+	var out bytes.Buffer
+	for i, frame := range msg {
+		var flags byte
+		if i < len(msg)-1 {
+			flags |= flagMore
+		}
+		writeFrame(&out, flags, frame)
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err := c.conn.Write(out.Bytes())
+	return err
+}
+
+func writeFrame(out *bytes.Buffer, flags byte, body []byte) {
+	if len(body) > 255 {
+		out.WriteByte(flags | flagLong)
+		binary.Write(out, binary.BigEndian, uint64(len(body)))
+	} else {
+		out.WriteByte(flags)
+		out.WriteByte(byte(len(body)))
+	}
+	out.Write(body)
+}
+
+func (c *Conn) writeCommand(name string, data []byte) error {
+	var body bytes.Buffer
+	body.WriteByte(byte(len(name)))
+	body.WriteString(name)
+	body.Write(data)
+
+	var out bytes.Buffer
+	writeFrame(&out, flagCommand, body.Bytes())
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err := c.conn.Write(out.Bytes())
+	return err
+}
+
+// readFrame reads a frame and returns its flags and body.
+func (c *Conn) readFrame() (byte, []byte, error) {
+	flags, err := c.reader.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var size uint64
+	if flags&flagLong != 0 {
+		if err := binary.Read(c.reader, binary.BigEndian, &size); err != nil {
+			return 0, nil, err
+		}
+	} else {
+		short, err := c.reader.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		size = uint64(short)
+	}
+	if size > MAX_FRAME_SIZE {
+		return 0, nil, fmt.Errorf("zmtp: frame of %d bytes is too large", size)
+	}
+
+	body := make([]byte, size)
+	if _, err := io.ReadFull(c.reader, body); err != nil {
+		return 0, nil, err
+	}
+	return flags, body, nil
+}
+
+func (c *Conn) readCommand() (string, []byte, error) {
+	flags, body, err := c.readFrame()
+	if err != nil {
+		return "", nil, err
+	}
+	if flags&flagCommand == 0 {
+		return "", nil, errors.New("zmtp: expected a command frame")
+	}
+
+	return splitCommand(body)
+}
+
+func splitCommand(body []byte) (string, []byte, error) {
+	if len(body) == 0 || len(body) < 1+int(body[0]) {
+		return "", nil, errors.New("zmtp: malformed command")
+	}
+	return string(body[1 : 1+body[0]]), body[1+body[0]:], nil
+}
+
+// Recv reads the next message. The SUBSCRIBE and CANCEL commands of newer
+// peers are returned as the subscription messages of ZMTP 3.0, a frame
+// starting with 1 or 0 followed by the topic, and PING is answered.
+func (c *Conn) Recv() (Message, error) {
+	msg := Message{}
+
+	for {
+		flags, body, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+
+		if flags&flagCommand != 0 {
+			name, data, err := splitCommand(body)
+			if err != nil {
+				return nil, err
+			}
+			switch name {
+			case "SUBSCRIBE":
+				return Message{append([]byte{1}, data...)}, nil
+			case "CANCEL":
+				return Message{append([]byte{0}, data...)}, nil
+			case "PING":
+				// the context follows a two byte TTL
+				if len(data) >= 2 {
+					c.writeCommand("PONG", data[2:])
+				}
+			}
+			continue
+		}
+
+		msg = append(msg, body)
+		if flags&flagMore == 0 {
+			return msg, nil
+		}
+	}
+}
+
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
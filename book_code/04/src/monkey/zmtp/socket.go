@@ -0,0 +1,278 @@
+package zmtp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+)
+
+// Socket is a bound socket that any number of peers connect to. How messages
+// are routed depends on its type:
+//
+//   - ROUTER prefixes received messages with a frame identifying the peer,
+//     and sends each message to the peer its first frame identifies.
+//   - PUB sends each message to the peers subscribed to a prefix of its
+//     first frame, and receives nothing.
+//   - REP receives requests without their envelope and sends each reply to
+//     the peer of the last request.
+type Socket struct {
+	Type SocketType
+
+	listener net.Listener
+	incoming chan received
+	closed   chan struct{}
+
+	mu     sync.Mutex
+	peers  map[string]*peer // by identity
+	nextID uint32
+
+	replyTo  *peer // for REP, the peer of the last request
+	envelope Message
+}
+
+type peer struct {
+	conn          *Conn
+	identity      []byte
+	subscriptions [][]byte
+}
+
+type received struct {
+	peer *peer
+	msg  Message
+}
+
+// Listen binds a socket of type socketType to address, given as
+// "tcp://host:port" or "host:port". Port 0 picks a free port, which Addr
+// returns.
+func Listen(socketType SocketType, address string) (*Socket, error) {
+	switch socketType {
+	case ROUTER, PUB, REP:
+	default:
+		return nil, errors.New("zmtp: cannot bind a socket of type " + string(socketType))
+	}
+
+	listener, err := net.Listen("tcp", strings.TrimPrefix(address, "tcp://"))
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Socket{
+		Type:     socketType,
+		listener: listener,
+		incoming: make(chan received),
+		closed:   make(chan struct{}),
+		peers:    map[string]*peer{},
+	}
+	go s.accept()
+
+	return s, nil
+}
+
+func (s *Socket) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+func (s *Socket) accept() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.serve(conn)
+	}
+}
+
+// serve does the handshake with a new peer and passes on what it sends until
+// it disconnects.
+func (s *Socket) serve(conn net.Conn) {
+	c, err := newConn(conn, s.Type, nil)
+	if err != nil {
+		conn.Close()
+		return
+	}
+
+	p := &peer{conn: c, identity: c.PeerIdentity}
+	s.mu.Lock()
+	select {
+	case <-s.closed:
+		s.mu.Unlock()
+		c.Close()
+		return
+	default:
+	}
+	if len(p.identity) == 0 || s.peers[string(p.identity)] != nil {
+		// like libzmq, a generated identity starts with a zero byte
+		p.identity = make([]byte, 5)
+		s.nextID += 1
+		binary.BigEndian.PutUint32(p.identity[1:], s.nextID)
+	}
+	s.peers[string(p.identity)] = p
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.peers, string(p.identity))
+		s.mu.Unlock()
+		c.Close()
+	}()
+
+	for {
+		msg, err := c.Recv()
+		if err != nil {
+			return
+		}
+
+		if s.Type == PUB {
+			s.subscribe(p, msg)
+			continue
+		}
+
+		select {
+		case s.incoming <- received{peer: p, msg: msg}:
+		case <-s.closed:
+			return
+		}
+	}
+}
+
+// subscribe handles a subscription message, a frame starting with 1 to
+// subscribe or 0 to unsubscribe followed by the topic.
+func (s *Socket) subscribe(p *peer, msg Message) {
+	if len(msg) != 1 || len(msg[0]) == 0 {
+		return
+	}
+	topic := msg[0][1:]
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch msg[0][0] {
+	case 1:
+		p.subscriptions = append(p.subscriptions, topic)
+	case 0:
+		for i, subscription := range p.subscriptions {
+			if bytes.Equal(subscription, topic) {
+				p.subscriptions = append(p.subscriptions[:i], p.subscriptions[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// Recv returns the next message from any peer. It blocks until one arrives
+// or the socket is closed.
+func (s *Socket) Recv() (Message, error) {
+	for {
+		var r received
+		select {
+		case r = <-s.incoming:
+		case <-s.closed:
+			return nil, errClosed
+		}
+
+		switch s.Type {
+		case ROUTER:
+			return append(Message{r.peer.identity}, r.msg...), nil
+
+		case REP:
+			// the envelope runs up to an empty delimiter frame
+			delimiter := -1
+			for i, frame := range r.msg {
+				if len(frame) == 0 {
+					delimiter = i
+					break
+				}
+			}
+			if delimiter < 0 {
+				continue
+			}
+
+			s.mu.Lock()
+			s.replyTo = r.peer
+			s.envelope = r.msg[:delimiter+1]
+			s.mu.Unlock()
+			return r.msg[delimiter+1:], nil
+		}
+	}
+}
+
+// Send sends msg as the socket type routes it. Messages for peers that are
+// gone are dropped, as ZeroMQ does.
+func (s *Socket) Send(msg Message) error {
+	select {
+	case <-s.closed:
+		return errClosed
+	default:
+	}
+
+	switch s.Type {
+	case ROUTER:
+		if len(msg) == 0 {
+			return errors.New("zmtp: ROUTER message without an identity")
+		}
+		s.mu.Lock()
+		p := s.peers[string(msg[0])]
+		s.mu.Unlock()
+		if p != nil {
+			p.conn.Send(msg[1:])
+		}
+
+	case PUB:
+		var topic []byte
+		if len(msg) > 0 {
+			topic = msg[0]
+		}
+		for _, p := range s.subscribers(topic) {
+			p.conn.Send(msg)
+		}
+
+	case REP:
+		s.mu.Lock()
+		p, envelope := s.replyTo, s.envelope
+		s.replyTo, s.envelope = nil, nil
+		s.mu.Unlock()
+		if p == nil {
+			return errors.New("zmtp: REP reply without a request")
+		}
+		p.conn.Send(append(append(Message{}, envelope...), msg...))
+	}
+
+	return nil
+}
+
+func (s *Socket) subscribers(topic []byte) []*peer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subscribers := []*peer{}
+	for _, p := range s.peers {
+		for _, subscription := range p.subscriptions {
+			if bytes.HasPrefix(topic, subscription) {
+				subscribers = append(subscribers, p)
+				break
+			}
+		}
+	}
+	return subscribers
+}
+
+// Close stops listening and disconnects all peers.
+func (s *Socket) Close() error {
+	s.mu.Lock()
+	select {
+	case <-s.closed:
+		s.mu.Unlock()
+		return nil
+	default:
+	}
+	close(s.closed)
+	for _, p := range s.peers {
+		p.conn.Close()
+	}
+	s.mu.Unlock()
+
+	return s.listener.Close()
+}
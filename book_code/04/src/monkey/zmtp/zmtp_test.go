@@ -0,0 +1,178 @@
+package zmtp
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func listen(t *testing.T, socketType SocketType) *Socket {
+	s, err := Listen(socketType, "tcp://127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen(%s) failed: %s", socketType, err)
+	}
+	return s
+}
+
+func dial(t *testing.T, s *Socket, socketType SocketType) *Conn {
+	c, err := Dial("tcp://"+s.Addr().String(), socketType)
+	if err != nil {
+		t.Fatalf("Dial(%s) failed: %s", socketType, err)
+	}
+	return c
+}
+
+func testMessage(t *testing.T, actual, expected Message) {
+	if len(actual) != len(expected) {
+		t.Fatalf("wrong number of frames. expected=%q, got=%q", expected, actual)
+	}
+	for i := range expected {
+		if !bytes.Equal(actual[i], expected[i]) {
+			t.Errorf("frame %d wrong. expected=%q, got=%q", i, expected[i], actual[i])
+		}
+	}
+}
+
+func TestRouter(t *testing.T) {
+	router := listen(t, ROUTER)
+	defer router.Close()
+
+	first := dial(t, router, DEALER)
+	defer first.Close()
+	second := dial(t, router, DEALER)
+	defer second.Close()
+
+	if first.PeerType != ROUTER {
+		t.Errorf("wrong peer type. got=%s", first.PeerType)
+	}
+
+	long := []byte(strings.Repeat("x", 1000))
+	first.Send(Message{[]byte("from first"), long})
+	msg, err := router.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	firstIdentity := msg[0]
+	testMessage(t, msg[1:], Message{[]byte("from first"), long})
+
+	second.Send(Message{[]byte("from second")})
+	msg, err = router.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(msg[0], firstIdentity) {
+		t.Fatalf("peers got the same identity %q", firstIdentity)
+	}
+
+	router.Send(Message{msg[0], []byte("to second")})
+	router.Send(Message{firstIdentity, []byte("to first"), []byte{}})
+
+	reply, err := second.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testMessage(t, reply, Message{[]byte("to second")})
+
+	reply, err = first.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testMessage(t, reply, Message{[]byte("to first"), []byte{}})
+}
+
+func TestPub(t *testing.T) {
+	pub := listen(t, PUB)
+	defer pub.Close()
+
+	all := dial(t, pub, SUB)
+	defer all.Close()
+	status := dial(t, pub, SUB)
+	defer status.Close()
+
+	all.Send(Message{[]byte{1}})
+	status.Send(Message{append([]byte{1}, "status"...)})
+
+	// subscriptions take effect once the socket has read them
+	deadline := time.Now().Add(5 * time.Second)
+	for len(pub.subscribers([]byte("status"))) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("subscriptions did not arrive")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	pub.Send(Message{[]byte("stream"), []byte("1")})
+	pub.Send(Message{[]byte("status"), []byte("2")})
+
+	msg, err := all.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testMessage(t, msg, Message{[]byte("stream"), []byte("1")})
+	msg, err = all.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testMessage(t, msg, Message{[]byte("status"), []byte("2")})
+
+	msg, err = status.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testMessage(t, msg, Message{[]byte("status"), []byte("2")})
+}
+
+func TestRep(t *testing.T) {
+	rep := listen(t, REP)
+	defer rep.Close()
+
+	req := dial(t, rep, REQ)
+	defer req.Close()
+
+	req.Send(Message{[]byte{}, []byte("ping")})
+	msg, err := rep.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testMessage(t, msg, Message{[]byte("ping")})
+
+	if err := rep.Send(msg); err != nil {
+		t.Fatal(err)
+	}
+	reply, err := req.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testMessage(t, reply, Message{[]byte{}, []byte("ping")})
+
+	if err := rep.Send(msg); err == nil {
+		t.Errorf("expected an error for a reply without a request")
+	}
+}
+
+func TestClose(t *testing.T) {
+	router := listen(t, ROUTER)
+	c := dial(t, router, DEALER)
+	defer c.Close()
+
+	done := make(chan error)
+	go func() {
+		_, err := router.Recv()
+		done <- err
+	}()
+
+	router.Close()
+	if err := <-done; err != errClosed {
+		t.Errorf("wrong error. expected=%v, got=%v", errClosed, err)
+	}
+	if _, err := c.Recv(); err == nil {
+		t.Errorf("expected the connection to be closed")
+	}
+}
+
+func TestListenUnsupportedType(t *testing.T) {
+	if _, err := Listen(DEALER, "tcp://127.0.0.1:0"); err == nil {
+		t.Errorf("expected an error binding a DEALER socket")
+	}
+}
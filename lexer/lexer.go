@@ -54,23 +54,27 @@ func (lexerInstance *Lexer) peekAtNextCodePoint() rune {
 	return codePoint
 }
 
+func (lexerInstance *Lexer) advanceThroughCodePoint() {
+	switch lexerInstance.currentCodePoint {
+	case '\t':
+		lexerInstance.currentColumnNumber += 4 - (lexerInstance.currentColumnNumber % 4)
+	case '\n':
+		lexerInstance.currentLineNumber += 1
+		lexerInstance.currentColumnNumber = 1
+	case '\r':
+	default:
+		lexerInstance.currentColumnNumber += 1
+	}
+
+	lexerInstance.updateCurrentCodePoint()
+}
+
 func (lexerInstance *Lexer) skipWhitespace() {
 	for lexerInstance.currentCodePoint == ' ' ||
 		lexerInstance.currentCodePoint == '\t' ||
 		lexerInstance.currentCodePoint == '\n' ||
 		lexerInstance.currentCodePoint == '\r' {
-		if lexerInstance.currentCodePoint == ' ' {
-			lexerInstance.currentColumnNumber += 1
-		}
-		if lexerInstance.currentCodePoint == '\t' {
-			lexerInstance.currentColumnNumber += 4 - (lexerInstance.currentColumnNumber % 4)
-		}
-		if lexerInstance.currentCodePoint == '\n' {
-			lexerInstance.currentLineNumber += 1
-			lexerInstance.currentColumnNumber = 1
-		}
-
-		lexerInstance.updateCurrentCodePoint()
+		lexerInstance.advanceThroughCodePoint()
 	}
 }
 
@@ -113,6 +117,8 @@ func isPartOfWord(codePoint rune) bool {
 		')':  {},
 		';':  {},
 		'/':  {},
+		'"':  {},
+		'`':  {},
 		0:    {},
 	}
 
@@ -165,6 +171,106 @@ func (lexerInstance *Lexer) handleNumberToken(lineNumber int, columnNumber int)
 	}
 }
 
+// getStringBody reads the code points of a double-quoted string up to, but not
+// including, its closing quote. Interpolations are kept verbatim, including
+// any strings nested inside them, and reported through the second result.
+func (lexerInstance *Lexer) getStringBody() ([]rune, bool) {
+	var body []rune
+	isTemplate := false
+	interpolationDepth := 0
+
+	for {
+		codePoint := lexerInstance.currentCodePoint
+		if codePoint == 0 || (codePoint == '"' && interpolationDepth == 0) {
+			return body, isTemplate
+		}
+
+		body = append(body, codePoint)
+		lexerInstance.advanceThroughCodePoint()
+
+		switch {
+		case codePoint == '\\' && lexerInstance.currentCodePoint != 0:
+			body = append(body, lexerInstance.currentCodePoint)
+			lexerInstance.advanceThroughCodePoint()
+		case codePoint == '$' && lexerInstance.currentCodePoint == '{' && interpolationDepth == 0:
+			isTemplate = true
+			interpolationDepth = 1
+
+			body = append(body, '{')
+			lexerInstance.advanceThroughCodePoint()
+		case codePoint == '{' && interpolationDepth > 0:
+			interpolationDepth += 1
+		case codePoint == '}' && interpolationDepth > 0:
+			interpolationDepth -= 1
+		case codePoint == '"' && interpolationDepth > 0:
+			nestedBody, _ := lexerInstance.getStringBody()
+			body = append(body, nestedBody...)
+
+			if lexerInstance.currentCodePoint == '"' {
+				body = append(body, '"')
+				lexerInstance.advanceThroughCodePoint()
+			}
+		}
+	}
+}
+
+func unescape(body []rune) string {
+	var unescaped []rune
+
+	for i := 0; i < len(body); i++ {
+		if body[i] != '\\' || i+1 == len(body) {
+			unescaped = append(unescaped, body[i])
+			continue
+		}
+
+		i += 1
+		switch body[i] {
+		case 'n':
+			unescaped = append(unescaped, '\n')
+		case 't':
+			unescaped = append(unescaped, '\t')
+		case 'r':
+			unescaped = append(unescaped, '\r')
+		default:
+			unescaped = append(unescaped, body[i])
+		}
+	}
+
+	return string(unescaped)
+}
+
+func (lexerInstance *Lexer) handleStringToken(lineNumber int, columnNumber int) token {
+	lexerInstance.advanceThroughCodePoint()
+
+	body, isTemplate := lexerInstance.getStringBody()
+
+	if lexerInstance.currentCodePoint == '"' {
+		lexerInstance.advanceThroughCodePoint()
+	}
+
+	if isTemplate {
+		return token{template, string(body), lexerInstance.filePath, lineNumber, columnNumber}
+	}
+
+	return token{stringLiteral, unescape(body), lexerInstance.filePath, lineNumber, columnNumber}
+}
+
+func (lexerInstance *Lexer) handleRawStringToken(lineNumber int, columnNumber int) token {
+	lexerInstance.advanceThroughCodePoint()
+
+	var body []rune
+	for lexerInstance.currentCodePoint != '`' && lexerInstance.currentCodePoint != 0 {
+		body = append(body, lexerInstance.currentCodePoint)
+		lexerInstance.advanceThroughCodePoint()
+	}
+
+	if lexerInstance.currentCodePoint == '`' {
+		lexerInstance.advanceThroughCodePoint()
+	}
+
+	return token{stringLiteral, string(body), lexerInstance.filePath, lineNumber, columnNumber}
+}
+
 func (lexerInstance *Lexer) handleSingleCodePoint(codePoint rune, lineNumber int, columnNumber int) token {
 	if codePoint != 0 {
 		lexerInstance.currentColumnNumber += 1
@@ -269,6 +375,14 @@ func (lexerInstance *Lexer) getNextToken() token {
 		return lexerInstance.handleNumberToken(lineNumber, columnNumber)
 	}
 
+	if codePoint == '"' {
+		return lexerInstance.handleStringToken(lineNumber, columnNumber)
+	}
+
+	if codePoint == '`' {
+		return lexerInstance.handleRawStringToken(lineNumber, columnNumber)
+	}
+
 	if codePoint == '!' {
 		return lexerInstance.handleExclamationMarkCodePoint(lineNumber, columnNumber)
 	}
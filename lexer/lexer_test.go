@@ -107,7 +107,15 @@ func TestGetNextToken(t *testing.T) {
 		{inequality, "", filePath, 23, 4},
 		{integer, "9", filePath, 23, 7},
 		{semicolon, "", filePath, 23, 8},
-		{eof, "", filePath, 24, 1},
+		{stringLiteral, "foo bar", filePath, 24, 1},
+		{semicolon, "", filePath, 24, 10},
+		{stringLiteral, "犬 says \"hi\"", filePath, 25, 1},
+		{semicolon, "", filePath, 25, 16},
+		{template, `Hello ${name}, ${ {"a": "}"}["a"] }`, filePath, 26, 1},
+		{semicolon, "", filePath, 26, 38},
+		{stringLiteral, "raw\n\tmulti-line", filePath, 27, 1},
+		{semicolon, "", filePath, 28, 15},
+		{eof, "", filePath, 29, 1},
 	}
 
 	file, err := os.Open(filePath)
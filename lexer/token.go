@@ -11,27 +11,29 @@ const (
 
 	identifier = byte(7)
 
-	integer = byte(8)
+	integer       = byte(8)
+	stringLiteral = byte(9)
+	template      = byte(10)
 
-	assign           = byte(9)
-	asterisk         = byte(10)
-	bang             = byte(11)
-	comma            = byte(12)
-	equality         = byte(13)
-	greaterThan      = byte(14)
-	inequality       = byte(15)
-	leftCurlyBrace   = byte(16)
-	leftParenthesis  = byte(17)
-	lessThan         = byte(18)
-	minus            = byte(19)
-	plus             = byte(20)
-	rightCurlyBrace  = byte(21)
-	rightParenthesis = byte(22)
-	semicolon        = byte(23)
-	slash            = byte(24)
+	assign           = byte(11)
+	asterisk         = byte(12)
+	bang             = byte(13)
+	comma            = byte(14)
+	equality         = byte(15)
+	greaterThan      = byte(16)
+	inequality       = byte(17)
+	leftCurlyBrace   = byte(18)
+	leftParenthesis  = byte(19)
+	lessThan         = byte(20)
+	minus            = byte(21)
+	plus             = byte(22)
+	rightCurlyBrace  = byte(23)
+	rightParenthesis = byte(24)
+	semicolon        = byte(25)
+	slash            = byte(26)
 
-	eof     = byte(25)
-	unknown = byte(26)
+	eof     = byte(27)
+	unknown = byte(28)
 )
 
 type token struct {